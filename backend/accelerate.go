@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+// s3AccelerateClient talks to the s3-accelerate endpoint. It is only set up
+// when aws.use_accelerate_endpoint is enabled for an AWS region.
+var s3AccelerateClient *s3.Client
+
+var accelerations = newBucketFlagCache("transfer acceleration", func(ctx context.Context, bucketName string) (bool, error) {
+	result, err := bucketClient(ctx, bucketName).GetBucketAccelerateConfiguration(ctx, &s3.GetBucketAccelerateConfigurationInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.Status == types.BucketAccelerateStatusEnabled, nil
+})
+
+// transferClient is the client for moving object data in and out of
+// bucketName. Requests to the accelerated endpoint fail for buckets without
+// acceleration, so it is only used once the bucket has it enabled. It signs
+// for the configured region, buckets found elsewhere use their region's client.
+func transferClient(ctx context.Context, bucketName string) *s3.Client {
+	client := bucketClient(ctx, bucketName)
+	if s3AccelerateClient != nil && client == s3Client && accelerations.lookup(ctx, bucketName) {
+		return s3AccelerateClient
+	}
+
+	return client
+}
+
+func getBucketAccelerate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetBucketAccelerateConfiguration(r.Context(), &s3.GetBucketAccelerateConfigurationInput{
+		Bucket: aws.String(bucketName),
+	})
+
+	// S3 compatible providers usually do not implement acceleration
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"supported": false})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_accelerate_failed", err)
+		return
+	}
+
+	enabled := result.Status == types.BucketAccelerateStatusEnabled
+	accelerations.set(bucketName, enabled)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"supported":   true,
+		"status":      result.Status,
+		"enabled":     enabled,
+		"usedByAdmin": enabled && s3AccelerateClient != nil,
+	})
+}
+
+func putBucketAccelerate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	status := types.BucketAccelerateStatusSuspended
+	if data.Enabled {
+		status = types.BucketAccelerateStatusEnabled
+	}
+
+	_, err := bucketClient(r.Context(), bucketName).PutBucketAccelerateConfiguration(r.Context(), &s3.PutBucketAccelerateConfigurationInput{
+		Bucket:                  aws.String(bucketName),
+		AccelerateConfiguration: &types.AccelerateConfiguration{Status: status},
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_accelerate_failed", err)
+		return
+	}
+
+	accelerations.set(bucketName, data.Enabled)
+	audit.Record(r, "accelerate.put", bucketName, "", map[string]interface{}{"status": status})
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"supported":   true,
+		"status":      status,
+		"enabled":     data.Enabled,
+		"usedByAdmin": data.Enabled && s3AccelerateClient != nil,
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/handlers"
+)
+
+type AccessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Query      string    `json:"query,omitempty"`
+	Protocol   string    `json:"protocol"`
+	Status     int       `json:"status"`
+	Size       int       `json:"size"`
+	DurationMS int64     `json:"durationMs"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+}
+
+// lockedWriter serializes log lines of concurrent requests
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
+
+func writeJSONAccessLog(writer io.Writer, params handlers.LogFormatterParams) {
+	entry := AccessLogEntry{
+		Time:       params.TimeStamp.UTC(),
+		RemoteAddr: remoteAddr(params.Request),
+		Method:     params.Request.Method,
+		Path:       params.URL.Path,
+		Query:      params.URL.RawQuery,
+		Protocol:   params.Request.Proto,
+		Status:     params.StatusCode,
+		Size:       params.Size,
+		DurationMS: time.Since(params.TimeStamp).Milliseconds(),
+		Referer:    params.Request.Referer(),
+		UserAgent:  params.Request.UserAgent(),
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	writer.Write(append(line, '\n'))
+}
+
+// withAccessLog wraps the handler according to the access_log config section.
+// Logging is off unless enabled.
+func withAccessLog(h http.Handler) (http.Handler, error) {
+	cfg := appConfig.AccessLog
+	if !cfg.Enabled {
+		return h, nil
+	}
+
+	var out io.Writer
+	switch cfg.Output {
+	case "", "stdout":
+		out = &lockedWriter{w: os.Stdout}
+	case "stderr":
+		out = &lockedWriter{w: os.Stderr}
+	default:
+		file, err := newRotatingFile(cfg.Output, cfg.MaxSizeMB, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	}
+
+	switch cfg.Format {
+	case "", "combined":
+		return handlers.CombinedLoggingHandler(out, h), nil
+	case "common":
+		return handlers.LoggingHandler(out, h), nil
+	case "json":
+		return handlers.CustomLoggingHandler(out, h, writeJSONAccessLog), nil
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", cfg.Format)
+	}
+}
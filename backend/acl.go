@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	allUsersGroup           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	authenticatedUsersGroup = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+type ACLGrant struct {
+	Type        string `json:"type"`
+	ID          string `json:"id,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	Email       string `json:"email,omitempty"`
+	URI         string `json:"uri,omitempty"`
+	Permission  string `json:"permission"`
+}
+
+// Public reports whether the grant gives access to anyone outside the account
+func (g ACLGrant) Public() bool {
+	return g.URI == allUsersGroup || g.URI == authenticatedUsersGroup
+}
+
+type BucketACL struct {
+	Owner struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"displayName,omitempty"`
+	} `json:"owner"`
+	Grants []ACLGrant `json:"grants"`
+	Public bool       `json:"public"`
+}
+
+func (g ACLGrant) validate() *apiError {
+	if !isKnownValue(g.Permission, types.Permission("").Values()) {
+		return newAPIError("acl_permission_invalid", g.Permission)
+	}
+
+	switch types.Type(g.Type) {
+	case types.TypeCanonicalUser:
+		if g.ID == "" {
+			return newAPIError("acl_grantee_incomplete", g.Type)
+		}
+	case types.TypeAmazonCustomerByEmail:
+		if g.Email == "" {
+			return newAPIError("acl_grantee_incomplete", g.Type)
+		}
+	case types.TypeGroup:
+		if g.URI == "" {
+			return newAPIError("acl_grantee_incomplete", g.Type)
+		}
+	default:
+		return newAPIError("acl_grantee_type_invalid", g.Type)
+	}
+
+	return nil
+}
+
+func isKnownValue[T ~string](value string, known []T) bool {
+	for _, k := range known {
+		if string(k) == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g ACLGrant) toS3() types.Grant {
+	grantee := &types.Grantee{Type: types.Type(g.Type)}
+	if g.ID != "" {
+		grantee.ID = aws.String(g.ID)
+	}
+	if g.Email != "" {
+		grantee.EmailAddress = aws.String(g.Email)
+	}
+	if g.URI != "" {
+		grantee.URI = aws.String(g.URI)
+	}
+
+	return types.Grant{Grantee: grantee, Permission: types.Permission(g.Permission)}
+}
+
+func getBucketACL(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetBucketAcl(r.Context(), &s3.GetBucketAclInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_acl_failed", err)
+		return
+	}
+
+	acl := BucketACL{Grants: []ACLGrant{}}
+	if result.Owner != nil {
+		acl.Owner.ID = aws.ToString(result.Owner.ID)
+		acl.Owner.DisplayName = aws.ToString(result.Owner.DisplayName)
+	}
+	for _, grant := range result.Grants {
+		if grant.Grantee == nil {
+			continue
+		}
+
+		g := ACLGrant{
+			Type:        string(grant.Grantee.Type),
+			ID:          aws.ToString(grant.Grantee.ID),
+			DisplayName: aws.ToString(grant.Grantee.DisplayName),
+			Email:       aws.ToString(grant.Grantee.EmailAddress),
+			URI:         aws.ToString(grant.Grantee.URI),
+			Permission:  string(grant.Permission),
+		}
+		acl.Grants = append(acl.Grants, g)
+		acl.Public = acl.Public || g.Public()
+	}
+
+	json.NewEncoder(w).Encode(acl)
+}
+
+// putBucketACL either applies a canned ACL like "private" or replaces all
+// grants. Explicit grants need the owner id, S3 rejects policies without it.
+func putBucketACL(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Canned  string     `json:"canned"`
+		OwnerID string     `json:"ownerId"`
+		Grants  []ACLGrant `json:"grants"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	input := &s3.PutBucketAclInput{Bucket: aws.String(bucketName)}
+
+	switch {
+	case data.Canned != "" && len(data.Grants) > 0:
+		httpError(w, r, http.StatusBadRequest, "acl_canned_and_grants")
+		return
+	case data.Canned != "":
+		if !isKnownValue(data.Canned, types.BucketCannedACL("").Values()) {
+			httpError(w, r, http.StatusBadRequest, "acl_canned_invalid", data.Canned)
+			return
+		}
+		input.ACL = types.BucketCannedACL(data.Canned)
+	default:
+		if data.OwnerID == "" {
+			httpError(w, r, http.StatusBadRequest, "acl_owner_required")
+			return
+		}
+
+		policy := &types.AccessControlPolicy{Owner: &types.Owner{ID: aws.String(data.OwnerID)}}
+		for _, grant := range data.Grants {
+			if apiErr := grant.validate(); apiErr != nil {
+				writeAPIError(w, r, http.StatusBadRequest, apiErr)
+				return
+			}
+			policy.Grants = append(policy.Grants, grant.toS3())
+		}
+		input.AccessControlPolicy = policy
+	}
+
+	_, err := bucketClient(r.Context(), bucketName).PutBucketAcl(r.Context(), input)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_acl_failed", err)
+		return
+	}
+
+	audit.Record(r, "acl.put", bucketName, "", map[string]interface{}{
+		"canned": data.Canned,
+		"grants": len(data.Grants),
+	})
+
+	getBucketACL(w, r)
+}
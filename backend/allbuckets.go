@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type RegionBucket struct {
+	Name         string     `json:"name"`
+	CreationDate *time.Time `json:"creationDate,omitempty"`
+	Region       string     `json:"region"`
+}
+
+type RegionListingError struct {
+	Region string `json:"region"`
+	Error  string `json:"error"`
+}
+
+type AllBuckets struct {
+	Buckets []RegionBucket       `json:"buckets"`
+	Errors  []RegionListingError `json:"errors"`
+}
+
+// listedRegions are the configured region, aws.regions and, with auto
+// discovery, every region a bucket was found in
+func listedRegions() []string {
+	seen := map[string]bool{awsRegion: true}
+	list := []string{awsRegion}
+
+	add := func(region string) {
+		if !seen[region] {
+			seen[region] = true
+			list = append(list, region)
+		}
+	}
+	for _, region := range appConfig.AWS.Regions {
+		add(region)
+	}
+	if appConfig.AWS.AutoDiscover {
+		for _, region := range regions.Discovered() {
+			add(region)
+		}
+	}
+
+	return list
+}
+
+func listRegionBuckets(ctx context.Context, region string) ([]RegionBucket, error) {
+	input := &s3.ListBucketsInput{}
+	// S3 compatible storage has no regions to filter by
+	if appConfig.AWS.Endpoint == "" {
+		input.BucketRegion = aws.String(region)
+	}
+
+	buckets := []RegionBucket{}
+	paginator := s3.NewListBucketsPaginator(regions.Client(region), input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, bucket := range page.Buckets {
+			name := aws.ToString(bucket.Name)
+			regions.remember(name, region)
+			buckets = append(buckets, RegionBucket{Name: name, CreationDate: bucket.CreationDate, Region: region})
+		}
+	}
+
+	return buckets, nil
+}
+
+// listAllBuckets lists the buckets of all regions at once for a global bucket
+// picker. A failing region is reported without failing the others.
+func listAllBuckets(w http.ResponseWriter, r *http.Request) {
+	list := listedRegions()
+	buckets := make([][]RegionBucket, len(list))
+	errs := make([]error, len(list))
+
+	var wg sync.WaitGroup
+	for i, region := range list {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			buckets[i], errs[i] = listRegionBuckets(r.Context(), region)
+		}(i, region)
+	}
+	wg.Wait()
+
+	result := AllBuckets{Buckets: []RegionBucket{}, Errors: []RegionListingError{}}
+	for i, region := range list {
+		if errs[i] != nil {
+			result.Errors = append(result.Errors, RegionListingError{Region: region, Error: errs[i].Error()})
+			continue
+		}
+		result.Buckets = append(result.Buckets, buckets[i]...)
+	}
+
+	sort.Slice(result.Buckets, func(i, j int) bool {
+		return result.Buckets[i].Name < result.Buckets[j].Name
+	})
+
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// 64 MiB parts allow archives of up to 640 GB within the 10000 part limit
+const archivePartSize = 64 << 20
+
+type ArchiveResult struct {
+	BucketName string `json:"bucketName"`
+	Key        string `json:"key"`
+	FileName   string `json:"fileName"`
+}
+
+func archiveLocation(sourceBucket, jobID string) (string, string) {
+	bucketName := appConfig.Archives.Bucket
+	if bucketName == "" {
+		bucketName = sourceBucket
+	}
+
+	return bucketName, strings.TrimSuffix(appConfig.Archives.Prefix, "/") + "/" + jobID + ".zip"
+}
+
+func writeArchive(ctx context.Context, job *jobHandle, zipWriter *zip.Writer, bucketName, prefix string) error {
+	return walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		job.AddTotal(1)
+
+		result, err := bucketClient(ctx, bucketName).GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    obj.Key,
+		})
+		if err != nil {
+			job.Report(*obj.Key, err)
+			return nil
+		}
+		defer result.Body.Close()
+
+		zipFile, err := zipWriter.Create(*obj.Key)
+		if err != nil {
+			return err
+		}
+
+		// A partially written entry corrupts the archive, so this fails the job
+		if _, err := io.Copy(zipFile, result.Body); err != nil {
+			return err
+		}
+		job.Report(*obj.Key, nil)
+
+		return nil
+	})
+}
+
+func prepareArchive(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Prefix string `json:"prefix"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.Prefix == "" {
+		httpError(w, r, http.StatusBadRequest, "archive_prefix_required")
+		return
+	}
+
+	job, err := startBucketJob(bucketName, "archive", data.Prefix, func(ctx context.Context, job *jobHandle) error {
+		archiveBucket, archiveKey := archiveLocation(bucketName, job.ID())
+
+		writer, err := newMultipartWriter(ctx, archiveBucket, archiveKey, &s3.CreateMultipartUploadInput{
+			ContentType: aws.String("application/zip"),
+		})
+		if err != nil {
+			return err
+		}
+		writer.partSize = archivePartSize
+
+		zipWriter := zip.NewWriter(writer)
+		if err := writeArchive(ctx, job, zipWriter, bucketName, data.Prefix); err != nil {
+			writer.Abort()
+			return err
+		}
+		if err := zipWriter.Close(); err != nil {
+			writer.Abort()
+			return err
+		}
+		if err := writer.Complete(); err != nil {
+			writer.Abort()
+			return err
+		}
+
+		job.SetResult(ArchiveResult{
+			BucketName: archiveBucket,
+			Key:        archiveKey,
+			FileName:   path.Clean(data.Prefix) + ".zip",
+		})
+
+		return nil
+	}, appConfig.Archives.Bucket)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	writeJobAccepted(w, job)
+}
+
+func downloadArchive(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	job, ok := jobs.Get(vars["jobId"])
+	if !ok {
+		httpError(w, r, http.StatusNotFound, "job_not_found")
+		return
+	}
+
+	archive, ok := job.Result.(ArchiveResult)
+	if job.Type != "archive" || job.Status != JobCompleted || !ok {
+		httpError(w, r, http.StatusConflict, "archive_not_ready")
+		return
+	}
+
+	streamObject(w, r, &s3.GetObjectInput{
+		Bucket: aws.String(archive.BucketName),
+		Key:    aws.String(archive.Key),
+	}, attachmentDisposition(path.Base(archive.FileName)))
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var audit *auditLog
+
+// Details of an event are small, this only guards against a corrupted file
+const maxAuditLine = 16 << 20
+
+type AuditEvent struct {
+	Time       time.Time              `json:"time"`
+	Action     string                 `json:"action"`
+	RemoteAddr string                 `json:"remoteAddr,omitempty"`
+	Bucket     string                 `json:"bucket,omitempty"`
+	Key        string                 `json:"key,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	// PrevHash and Hash chain the events of audit.log, Hash has to stay the
+	// last field, see auditEventPayload
+	PrevHash string `json:"prevHash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// auditLog appends events as JSON lines to audit.log in the data directory.
+// Every event includes the hash of the one before, so changing or removing
+// an event breaks the chain from there on.
+type auditLog struct {
+	mu       sync.Mutex
+	file     *os.File
+	lastHash string
+	shippers []*auditShipper
+}
+
+func newAuditLog(dataDir string) (*auditLog, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dataDir, "audit.log")
+	lastHash, err := lastAuditHash(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditLog{file: file, lastHash: lastHash}, nil
+}
+
+// lastAuditHash continues the chain of an existing audit.log
+func lastAuditHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	lastHash := ""
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLine)
+	for scanner.Scan() {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil && event.Hash != "" {
+			lastHash = event.Hash
+		}
+	}
+
+	return lastHash, scanner.Err()
+}
+
+// chainHash hashes an event without its own hash, keyed when a signing key
+// is configured so the chain cannot simply be recomputed after a change
+func chainHash(payload []byte) string {
+	var mac hash.Hash
+	if key := appConfig.Audit.SigningKey; key != "" {
+		mac = hmac.New(sha256.New, []byte(key))
+	} else {
+		mac = sha256.New()
+	}
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditEventPayload recovers the bytes an event's hash was computed over.
+// Hash is the last field, so the line without it is exactly what was encoded
+// before the hash was added, no matter how the details would re-encode.
+func auditEventPayload(line []byte, event AuditEvent) ([]byte, bool) {
+	suffix := []byte(`,"hash":"` + event.Hash + `"}`)
+	if event.Hash == "" || !bytes.HasSuffix(line, suffix) {
+		return nil, false
+	}
+
+	payload := append([]byte(nil), line[:len(line)-len(suffix)]...)
+	return append(payload, '}'), true
+}
+
+func (a *auditLog) Record(r *http.Request, action, bucket, key string, details map[string]interface{}) {
+	event := AuditEvent{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Bucket:  bucket,
+		Key:     key,
+		Details: details,
+	}
+	if r != nil {
+		event.RemoteAddr = remoteAddr(r)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	event.PrevHash = a.lastHash
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode audit event %s: %v", action, err)
+		return
+	}
+	event.Hash = chainHash(payload)
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode audit event %s: %v", action, err)
+		return
+	}
+
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		log.Printf("failed to write audit event %s: %v", action, err)
+	} else {
+		a.lastHash = event.Hash
+	}
+
+	for _, shipper := range a.shippers {
+		shipper.Enqueue(event)
+	}
+}
+
+// Prune rewrites audit.log without the events recorded before the given time.
+// Lines that cannot be read are kept, they may still matter to someone.
+func (a *auditLog) Prune(before time.Time) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path := a.file.Name()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var kept bytes.Buffer
+	pruned := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		var event AuditEvent
+		if err := json.Unmarshal(line, &event); err == nil && event.Time.Before(before) {
+			pruned++
+			continue
+		}
+		kept.Write(line)
+	}
+	if pruned == 0 {
+		return 0, nil
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, kept.Bytes(), 0o600); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	a.file.Close()
+	a.file = file
+
+	return pruned, nil
+}
+
+type AuditVerification struct {
+	Valid   bool `json:"valid"`
+	Entries int  `json:"entries"`
+	// Unchained counts events written before the log was chained
+	Unchained int `json:"unchained"`
+	// Anchor is the hash the first chained event refers to, empty unless the
+	// log was pruned
+	Anchor   string `json:"anchor,omitempty"`
+	LastHash string `json:"lastHash,omitempty"`
+	// BrokenAt is the line number of the first event that does not fit the chain
+	BrokenAt int    `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify walks the chain of audit.log and reports the first event that was
+// changed, inserted or removed
+func (a *auditLog) Verify() (AuditVerification, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.Open(a.file.Name())
+	if err != nil {
+		return AuditVerification{}, err
+	}
+	defer file.Close()
+
+	result := AuditVerification{Valid: true}
+	chained := false
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLine)
+	for line := 1; scanner.Scan(); line++ {
+		fail := func(reason string) {
+			result.Valid = false
+			result.BrokenAt = line
+			result.Reason = reason
+		}
+
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			fail("unreadable")
+			break
+		}
+		if !chained && event.Hash == "" {
+			result.Unchained++
+			continue
+		}
+		result.Entries++
+
+		payload, ok := auditEventPayload(scanner.Bytes(), event)
+		if !ok {
+			fail("unchained")
+			break
+		}
+		if !chained {
+			chained = true
+			result.Anchor = event.PrevHash
+		} else if event.PrevHash != result.LastHash {
+			fail("previous hash mismatch")
+			break
+		}
+		if !hmac.Equal([]byte(chainHash(payload)), []byte(event.Hash)) {
+			fail("hash mismatch")
+			break
+		}
+
+		result.LastHash = event.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return AuditVerification{}, err
+	}
+
+	return result, nil
+}
+
+func verifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	result, err := audit.Verify()
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "audit_verify_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
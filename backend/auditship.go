@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// auditSink delivers a batch of audit events to an external system
+type auditSink interface {
+	Name() string
+	Send(ctx context.Context, events []AuditEvent) error
+}
+
+// auditShipper batches events for one sink and retries failed deliveries with
+// exponential backoff. Events that still fail are dropped and logged, the
+// local audit.log always keeps the complete history.
+type auditShipper struct {
+	sink          auditSink
+	queue         chan AuditEvent
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+}
+
+func newAuditShipper(sink auditSink) *auditShipper {
+	cfg := appConfig.Audit
+
+	return &auditShipper{
+		sink:          sink,
+		queue:         make(chan AuditEvent, cfg.QueueSize),
+		batchSize:     max(cfg.BatchSize, 1),
+		flushInterval: time.Duration(max(cfg.FlushIntervalSeconds, 1)) * time.Second,
+		maxRetries:    cfg.MaxRetries,
+	}
+}
+
+func (s *auditShipper) Enqueue(event AuditEvent) {
+	select {
+	case s.queue <- event:
+	default:
+		log.Printf("audit queue for %s is full, dropping event %s", s.sink.Name(), event.Action)
+	}
+}
+
+func (s *auditShipper) run() {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]AuditEvent, 0, s.batchSize)
+	for {
+		select {
+		case event := <-s.queue:
+			batch = append(batch, event)
+			if len(batch) < s.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		s.deliver(batch)
+		batch = make([]AuditEvent, 0, s.batchSize)
+	}
+}
+
+func (s *auditShipper) deliver(batch []AuditEvent) {
+	backoff := time.Second
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := s.sink.Send(ctx, batch)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		if attempt >= s.maxRetries {
+			log.Printf("failed to ship %d audit events to %s, dropping them: %v", len(batch), s.sink.Name(), err)
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// syslogSink sends RFC 5424 messages over udp or tcp. TCP uses octet
+// counting framing (RFC 6587).
+type syslogSink struct {
+	network  string
+	address  string
+	appName  string
+	hostname string
+	facility int
+	conn     net.Conn
+}
+
+func newSyslogSink(address, appName string, facility int) (*syslogSink, error) {
+	target, err := url.Parse(address)
+	if err != nil || target.Host == "" {
+		return nil, fmt.Errorf("invalid syslog address %q, expected e.g. udp://host:514", address)
+	}
+	if target.Scheme != "udp" && target.Scheme != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog network %q", target.Scheme)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	return &syslogSink{
+		network:  target.Scheme,
+		address:  target.Host,
+		appName:  appName,
+		hostname: hostname,
+		facility: facility,
+	}, nil
+}
+
+func (s *syslogSink) Name() string {
+	return "syslog"
+}
+
+func (s *syslogSink) format(event AuditEvent) ([]byte, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	// Severity 6 is informational
+	priority := s.facility*8 + 6
+	msgID := strings.NewReplacer(" ", "_").Replace(event.Action)
+	message := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		priority, event.Time.Format(time.RFC3339Nano), s.hostname, s.appName, os.Getpid(), msgID, payload)
+
+	if s.network == "tcp" {
+		return []byte(fmt.Sprintf("%d %s", len(message), message)), nil
+	}
+
+	return []byte(message), nil
+}
+
+func (s *syslogSink) Send(ctx context.Context, events []AuditEvent) error {
+	if s.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, s.network, s.address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	for _, event := range events {
+		message, err := s.format(event)
+		if err != nil {
+			return err
+		}
+		if _, err := s.conn.Write(message); err != nil {
+			// Reconnect on the next attempt, the server may have restarted
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+// httpSink posts batches to a collector: a plain JSON array, the Elasticsearch
+// bulk API or a Splunk HTTP Event Collector.
+type httpSink struct {
+	url    string
+	format string
+	token  string
+	index  string
+	client *http.Client
+}
+
+func (s *httpSink) Name() string {
+	return "http"
+}
+
+func (s *httpSink) body(events []AuditEvent) ([]byte, string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+
+	switch s.format {
+	case "elastic":
+		for _, event := range events {
+			encoder.Encode(map[string]interface{}{"index": map[string]string{"_index": s.index}})
+			if err := encoder.Encode(event); err != nil {
+				return nil, "", err
+			}
+		}
+		return buf.Bytes(), "application/x-ndjson", nil
+	case "splunk":
+		for _, event := range events {
+			err := encoder.Encode(map[string]interface{}{
+				"time":       float64(event.Time.UnixMilli()) / 1000,
+				"sourcetype": "s3-admin:audit",
+				"index":      s.index,
+				"event":      event,
+			})
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		return buf.Bytes(), "application/json", nil
+	default:
+		if err := encoder.Encode(events); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "application/json", nil
+	}
+}
+
+func (s *httpSink) Send(ctx context.Context, events []AuditEvent) error {
+	body, contentType, err := s.body(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.token != "" {
+		if s.format == "splunk" {
+			req.Header.Set("Authorization", "Splunk "+s.token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+s.token)
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector answered with %s: %s", resp.Status, response)
+	}
+
+	// The bulk API reports failed documents with a successful status code
+	if s.format == "elastic" {
+		var result struct {
+			Errors bool `json:"errors"`
+		}
+		if json.Unmarshal(response, &result) == nil && result.Errors {
+			return fmt.Errorf("bulk request contained failed documents")
+		}
+	}
+
+	return nil
+}
+
+// fileSink writes JSON lines to a rotated file, e.g. for a log shipper that
+// tails a fixed path outside of the data directory
+type fileSink struct {
+	file *rotatingFile
+}
+
+func (s *fileSink) Name() string {
+	return "file"
+}
+
+func (s *fileSink) Send(ctx context.Context, events []AuditEvent) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.file.Write(buf.Bytes())
+
+	return err
+}
+
+func newAuditSinks() ([]auditSink, error) {
+	cfg := appConfig.Audit
+	var sinks []auditSink
+
+	if cfg.Syslog.Address != "" {
+		sink, err := newSyslogSink(cfg.Syslog.Address, cfg.Syslog.AppName, cfg.Syslog.Facility)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if cfg.HTTP.URL != "" {
+		switch cfg.HTTP.Format {
+		case "json", "elastic", "splunk":
+		default:
+			return nil, fmt.Errorf("unknown audit http format %q", cfg.HTTP.Format)
+		}
+		sinks = append(sinks, &httpSink{
+			url:    cfg.HTTP.URL,
+			format: cfg.HTTP.Format,
+			token:  cfg.HTTP.Token,
+			index:  cfg.HTTP.Index,
+			client: &http.Client{Timeout: 30 * time.Second},
+		})
+	}
+
+	if cfg.File.Path != "" {
+		file, err := newRotatingFile(cfg.File.Path, cfg.File.MaxSizeMB, cfg.File.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, &fileSink{file: file})
+	}
+
+	return sinks, nil
+}
+
+// StartShipping forwards all future events to the sinks configured in the
+// audit config section
+func (a *auditLog) StartShipping() error {
+	sinks, err := newAuditSinks()
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, sink := range sinks {
+		shipper := newAuditShipper(sink)
+		a.shippers = append(a.shippers, shipper)
+		go shipper.run()
+	}
+
+	return nil
+}
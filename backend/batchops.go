@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// A batch is checked object by object before it runs, so it is kept to what
+// the UI can select at once
+const maxBatchOperations = 1000
+
+// Objects checked in parallel while previewing a batch
+const batchPreviewConcurrency = 8
+
+const (
+	BatchDelete = "delete"
+	BatchCopy   = "copy"
+	BatchTag    = "tag"
+)
+
+var batchActions = []string{BatchDelete, BatchCopy, BatchTag}
+
+// BatchOperation is one action on one object of the bucket. Copies go to
+// DestinationKey in DestinationBucket, or in the same bucket without one.
+// Tagging replaces all tags of the object.
+type BatchOperation struct {
+	Action            string            `json:"action"`
+	Key               string            `json:"key"`
+	DestinationBucket string            `json:"destinationBucket,omitempty"`
+	DestinationKey    string            `json:"destinationKey,omitempty"`
+	Tags              map[string]string `json:"tags,omitempty"`
+}
+
+type BatchItemResult struct {
+	Index  int    `json:"index"`
+	Action string `json:"action"`
+	Key    string `json:"key"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BatchPreview struct {
+	// Valid is only set when every operation can run
+	Valid bool              `json:"valid"`
+	Items []BatchItemResult `json:"items"`
+}
+
+func decodeBatch(w http.ResponseWriter, r *http.Request) ([]BatchOperation, bool) {
+	var data struct {
+		Operations []BatchOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return nil, false
+	}
+
+	if len(data.Operations) == 0 {
+		httpError(w, r, http.StatusBadRequest, "batch_operations_required")
+		return nil, false
+	}
+	if len(data.Operations) > maxBatchOperations {
+		httpError(w, r, http.StatusBadRequest, "batch_too_many", maxBatchOperations)
+		return nil, false
+	}
+
+	return data.Operations, true
+}
+
+// checkBatchOperation finds what would keep an operation from running,
+// without changing anything
+func checkBatchOperation(ctx context.Context, bucketName string, op BatchOperation) *apiError {
+	if !isKnownValue(op.Action, batchActions) {
+		return newAPIError("batch_action_invalid", op.Action)
+	}
+	if op.Key == "" {
+		return newAPIError("batch_key_required")
+	}
+
+	switch op.Action {
+	case BatchCopy:
+		if op.DestinationKey == "" {
+			return newAPIError("batch_destination_required")
+		}
+		destination := op.DestinationBucket
+		if destination == "" {
+			destination = bucketName
+		}
+		if destination == bucketName && op.DestinationKey == op.Key {
+			return newAPIError("batch_copy_identical")
+		}
+		if freeze, frozen := freezes.Get(destination); frozen {
+			return newAPIError("bucket_frozen", destination, freeze.FrozenBy, freeze.Reason)
+		}
+	case BatchTag:
+		if apiErr := validateTags(op.Tags, maxObjectTags); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	_, err := bucketClient(ctx, bucketName).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucketName),
+		Key:          aws.String(op.Key),
+		RequestPayer: requestPayer(ctx, bucketName),
+	})
+	if err != nil {
+		return newAPIError("batch_object_unavailable", err)
+	}
+
+	return nil
+}
+
+func previewBatch(ctx context.Context, bucketName string, operations []BatchOperation) BatchPreview {
+	preview := BatchPreview{Valid: true, Items: make([]BatchItemResult, len(operations))}
+
+	slots := make(chan struct{}, batchPreviewConcurrency)
+	var wg sync.WaitGroup
+	for i, op := range operations {
+		wg.Add(1)
+		go func(i int, op BatchOperation) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			item := BatchItemResult{Index: i, Action: op.Action, Key: op.Key, OK: true}
+			if apiErr := checkBatchOperation(ctx, bucketName, op); apiErr != nil {
+				item.OK = false
+				item.Error = apiErr.Error()
+			}
+			preview.Items[i] = item
+		}(i, op)
+	}
+	wg.Wait()
+
+	for _, item := range preview.Items {
+		preview.Valid = preview.Valid && item.OK
+	}
+
+	return preview
+}
+
+func runBatchOperation(ctx context.Context, job *jobHandle, bucketName string, op BatchOperation) error {
+	switch op.Action {
+	case BatchDelete:
+		_, err := bucketClient(ctx, bucketName).DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(op.Key),
+		})
+		if err == nil {
+			publishObjectEvent(ObjectDeleted, bucketName, op.Key)
+		}
+		return err
+	case BatchCopy:
+		destination := op.DestinationBucket
+		if destination == "" {
+			destination = bucketName
+		}
+		options := CopyOptions{}
+		options.validate()
+		err := copyObject(ctx, bucketName, op.Key, destination, op.DestinationKey, options, job.AddBytes)
+		if err == nil {
+			publishObjectEvent(ObjectCreated, destination, op.DestinationKey)
+		}
+		return err
+	case BatchTag:
+		_, err := bucketClient(ctx, bucketName).PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+			Bucket:  aws.String(bucketName),
+			Key:     aws.String(op.Key),
+			Tagging: &types.Tagging{TagSet: tagsFromMap(op.Tags)},
+		})
+		return err
+	}
+
+	return newAPIError("batch_action_invalid", op.Action)
+}
+
+// previewBatchOperations checks a batch without running it
+func previewBatchOperations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	operations, ok := decodeBatch(w, r)
+	if !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(previewBatch(r.Context(), vars["bucketName"], operations))
+}
+
+// runBatchOperations checks every operation first and only starts the batch
+// when all of them can run. The job then runs them in order and goes on
+// after failures, which are reported per operation in its result.
+func runBatchOperations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	operations, ok := decodeBatch(w, r)
+	if !ok {
+		return
+	}
+
+	preview := previewBatch(r.Context(), bucketName, operations)
+	if !preview.Valid {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(preview)
+		return
+	}
+
+	var destinations []string
+	for _, op := range operations {
+		if op.Action == BatchCopy && op.DestinationBucket != "" && op.DestinationBucket != bucketName && !slices.Contains(destinations, op.DestinationBucket) {
+			destinations = append(destinations, op.DestinationBucket)
+		}
+	}
+
+	job, err := startBucketJob(bucketName, "batch", "", func(ctx context.Context, job *jobHandle) error {
+		job.SetTotal(len(operations))
+
+		results := make([]BatchItemResult, 0, len(operations))
+		for i, op := range operations {
+			item := BatchItemResult{Index: i, Action: op.Action, Key: op.Key, OK: true}
+			err := runBatchOperation(ctx, job, bucketName, op)
+			if err != nil {
+				item.OK = false
+				item.Error = err.Error()
+			}
+			job.Report(op.Key, err)
+			results = append(results, item)
+
+			if ctx.Err() != nil {
+				job.SetResult(results)
+				return ctx.Err()
+			}
+		}
+		job.SetResult(results)
+
+		return nil
+	}, destinations...)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	counts := map[string]int{}
+	for _, op := range operations {
+		counts[op.Action]++
+	}
+	audit.Record(r, "batch.start", bucketName, "", map[string]interface{}{"jobId": job.ID, "operations": counts})
+
+	writeJobAccepted(w, job)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// Plans are built and checked synchronously before the job starts
+const maxBatchRenames = 10000
+
+var errRenameTooMany = errors.New("too many keys to rename")
+
+type RenamePattern struct {
+	Prefix string `json:"prefix"`
+	// Mode is "regex" to replace matches of Find in the key, or "prefix" to
+	// swap a leading Find for Replace
+	Mode    string `json:"mode"`
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
+
+	regex *regexp.Regexp
+}
+
+type PlannedRename struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Conflict string `json:"conflict,omitempty"`
+}
+
+type RenamePlan struct {
+	Renames   []PlannedRename `json:"renames"`
+	Conflicts int             `json:"conflicts"`
+}
+
+func (p *RenamePattern) Validate() *apiError {
+	if p.Find == "" {
+		return newAPIError("rename_find_required")
+	}
+
+	switch p.Mode {
+	case "regex":
+		regex, err := regexp.Compile(p.Find)
+		if err != nil {
+			return newAPIError("rename_regex_invalid", err)
+		}
+		p.regex = regex
+	case "prefix":
+		// Only keys starting with Find can change, no need to list the others
+		if !strings.HasPrefix(p.Find, p.Prefix) {
+			return newAPIError("rename_prefix_outside", p.Find, p.Prefix)
+		}
+		p.Prefix = p.Find
+	default:
+		return newAPIError("rename_mode_invalid", p.Mode)
+	}
+
+	return nil
+}
+
+func (p *RenamePattern) Apply(key string) string {
+	if p.regex != nil {
+		return p.regex.ReplaceAllString(key, p.Replace)
+	}
+
+	if strings.HasPrefix(key, p.Find) {
+		return p.Replace + strings.TrimPrefix(key, p.Find)
+	}
+
+	return key
+}
+
+// planRenames lists the keys below the pattern prefix and works out their new
+// names. Existing keys are never overwritten, not even by keys which are
+// renamed away in the same batch, as the order of the moves is not defined.
+func planRenames(ctx context.Context, bucketName string, pattern *RenamePattern) (*RenamePlan, error) {
+	plan := &RenamePlan{Renames: []PlannedRename{}}
+	existing := map[string]bool{}
+	targets := map[string]int{}
+
+	err := walkObjects(ctx, bucketName, pattern.Prefix, func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		existing[key] = true
+
+		target := pattern.Apply(key)
+		if target == key {
+			return nil
+		}
+		if len(plan.Renames) == maxBatchRenames {
+			return errRenameTooMany
+		}
+
+		targets[target]++
+		plan.Renames = append(plan.Renames, PlannedRename{From: key, To: target})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range plan.Renames {
+		rename := &plan.Renames[i]
+
+		switch {
+		case rename.To == "" || strings.HasPrefix(rename.To, "/") ||
+			(strings.HasSuffix(rename.To, "/") && !strings.HasSuffix(rename.From, "/")):
+			rename.Conflict = "invalid"
+		case targets[rename.To] > 1:
+			rename.Conflict = "duplicate"
+		case existing[rename.To]:
+			rename.Conflict = "exists"
+		case !strings.HasPrefix(rename.To, pattern.Prefix):
+			// Targets outside of the listing have to be checked one by one
+			exists, err := objectExists(ctx, bucketName, rename.To)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				rename.Conflict = "exists"
+			}
+		}
+
+		if rename.Conflict != "" {
+			plan.Conflicts++
+		}
+	}
+
+	return plan, nil
+}
+
+func decodeRenamePattern(w http.ResponseWriter, r *http.Request) (*RenamePattern, bool) {
+	var pattern RenamePattern
+	if err := json.NewDecoder(r.Body).Decode(&pattern); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return nil, false
+	}
+
+	if apiErr := pattern.Validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return nil, false
+	}
+
+	return &pattern, true
+}
+
+func buildRenamePlan(w http.ResponseWriter, r *http.Request, bucketName string, pattern *RenamePattern) (*RenamePlan, bool) {
+	plan, err := planRenames(r.Context(), bucketName, pattern)
+	if errors.Is(err, errRenameTooMany) {
+		httpError(w, r, http.StatusBadRequest, "rename_too_many", maxBatchRenames)
+		return nil, false
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "list_objects_failed", err)
+		return nil, false
+	}
+
+	return plan, true
+}
+
+func previewBatchRename(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	pattern, ok := decodeRenamePattern(w, r)
+	if !ok {
+		return
+	}
+
+	plan, ok := buildRenamePlan(w, r, vars["bucketName"], pattern)
+	if !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(plan)
+}
+
+// batchRename moves all keys matching the pattern in a job. Nothing is moved
+// while the plan has conflicts, they are answered with the plan instead.
+func batchRename(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	pattern, ok := decodeRenamePattern(w, r)
+	if !ok {
+		return
+	}
+
+	plan, ok := buildRenamePlan(w, r, bucketName, pattern)
+	if !ok {
+		return
+	}
+	if plan.Conflicts > 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(plan)
+		return
+	}
+
+	job, err := startBucketJob(bucketName, "batch-rename", pattern.Prefix, func(ctx context.Context, job *jobHandle) error {
+		job.SetTotal(len(plan.Renames))
+
+		for _, rename := range plan.Renames {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			// Something may have been written to the target since planning
+			exists, err := objectExists(ctx, bucketName, rename.To)
+			if err == nil && exists {
+				err = fmt.Errorf("%s already exists", rename.To)
+			}
+			if err == nil {
+				err = moveObject(ctx, bucketName, rename.From, bucketName, rename.To, renameOptions, job.AddBytes)
+			}
+			job.Done(rename.From, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	audit.Record(r, "objects.rename", bucketName, pattern.Prefix, map[string]interface{}{
+		"jobId":   job.ID,
+		"mode":    pattern.Mode,
+		"find":    pattern.Find,
+		"replace": pattern.Replace,
+		"keys":    len(plan.Renames),
+	})
+
+	writeJobAccepted(w, job)
+}
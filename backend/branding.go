@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxInstanceNameLength = 64
+	maxBannerLength       = 500
+)
+
+var (
+	branding   *documentStore[Branding]
+	colorRegex = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+)
+
+type Banner struct {
+	Text     string `json:"text"`
+	Severity string `json:"severity"`
+}
+
+// Branding tells deployments apart in the SPA, e.g. a red banner on production
+type Branding struct {
+	InstanceName   string  `json:"instanceName"`
+	LogoURL        string  `json:"logoUrl,omitempty"`
+	PrimaryColor   string  `json:"primaryColor,omitempty"`
+	SecondaryColor string  `json:"secondaryColor,omitempty"`
+	ThemeMode      string  `json:"themeMode,omitempty"`
+	Banner         *Banner `json:"banner,omitempty"`
+}
+
+func (b *Branding) Validate() *apiError {
+	if strings.TrimSpace(b.InstanceName) == "" {
+		return newAPIError("branding_name_required")
+	}
+	if len(b.InstanceName) > maxInstanceNameLength {
+		return newAPIError("branding_name_too_long", maxInstanceNameLength)
+	}
+
+	if b.LogoURL != "" {
+		logo, err := url.Parse(b.LogoURL)
+		relative := err == nil && logo.Scheme == "" && logo.Host == "" && strings.HasPrefix(logo.Path, "/")
+		absolute := err == nil && (logo.Scheme == "https" || logo.Scheme == "http") && logo.Host != ""
+		if !relative && !absolute {
+			return newAPIError("branding_logo_invalid")
+		}
+	}
+
+	for _, color := range []string{b.PrimaryColor, b.SecondaryColor} {
+		if color != "" && !colorRegex.MatchString(color) {
+			return newAPIError("branding_color_invalid", color)
+		}
+	}
+
+	switch b.ThemeMode {
+	case "", "light", "dark":
+	default:
+		return newAPIError("branding_theme_invalid", b.ThemeMode)
+	}
+
+	if b.Banner != nil {
+		if strings.TrimSpace(b.Banner.Text) == "" || len(b.Banner.Text) > maxBannerLength {
+			return newAPIError("branding_banner_invalid", maxBannerLength)
+		}
+		switch b.Banner.Severity {
+		case "info", "warning", "error":
+		default:
+			return newAPIError("branding_banner_severity_invalid", b.Banner.Severity)
+		}
+	}
+
+	return nil
+}
+
+// defaultBranding is used until branding is saved through the API
+func defaultBranding() Branding {
+	cfg := appConfig.Branding
+
+	b := Branding{
+		InstanceName: cfg.InstanceName,
+		LogoURL:      cfg.LogoURL,
+		PrimaryColor: cfg.PrimaryColor,
+	}
+	if cfg.BannerText != "" {
+		b.Banner = &Banner{Text: cfg.BannerText, Severity: cfg.BannerSeverity}
+	}
+
+	return b
+}
+
+func newBrandingStore(dataDir string) (*documentStore[Branding], error) {
+	return newDocumentStore(dataDir, "branding.json", defaultBranding())
+}
+
+func getBranding(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(branding.Get())
+}
+
+func putBranding(w http.ResponseWriter, r *http.Request) {
+	var data Branding
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if apiErr := data.Validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	if err := branding.Set(data); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "branding_save_failed", err)
+		return
+	}
+
+	audit.Record(r, "branding.update", "", "", map[string]interface{}{"instanceName": data.InstanceName})
+
+	json.NewEncoder(w).Encode(data)
+}
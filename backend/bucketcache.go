@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const bucketFlagCacheTTL = 5 * time.Minute
+
+type bucketFlagEntry struct {
+	value     bool
+	checkedAt time.Time
+}
+
+// bucketFlagCache remembers a per-bucket setting that changes rarely but is
+// needed on hot paths, e.g. whether reads have to be sent as requester
+type bucketFlagCache struct {
+	name  string
+	fetch func(ctx context.Context, bucketName string) (bool, error)
+
+	mu      sync.Mutex
+	buckets map[string]bucketFlagEntry
+}
+
+func newBucketFlagCache(name string, fetch func(ctx context.Context, bucketName string) (bool, error)) *bucketFlagCache {
+	return &bucketFlagCache{
+		name:    name,
+		fetch:   fetch,
+		buckets: make(map[string]bucketFlagEntry),
+	}
+}
+
+func (c *bucketFlagCache) set(bucketName string, value bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buckets[bucketName] = bucketFlagEntry{value: value, checkedAt: time.Now()}
+}
+
+func (c *bucketFlagCache) lookup(ctx context.Context, bucketName string) bool {
+	c.mu.Lock()
+	entry, ok := c.buckets[bucketName]
+	c.mu.Unlock()
+	if ok && time.Since(entry.checkedAt) < bucketFlagCacheTTL {
+		return entry.value
+	}
+
+	value, err := c.fetch(ctx, bucketName)
+	if err != nil {
+		// Providers without support for the setting answer with an error,
+		// which is cached as well to not ask again on every request
+		log.Printf("failed to get %s of %s: %v", c.name, bucketName, err)
+	}
+	c.set(bucketName, value)
+
+	return value
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+// Counted buckets are shown from the cache for this long before they are
+// counted again
+const bucketStatsTTL = 15 * time.Minute
+
+// BucketStats are the totals of the current object versions of a bucket,
+// per storage class
+type BucketStats struct {
+	Objects        map[string]int64
+	Bytes          map[string]int64
+	LatestModified *time.Time
+	ScannedAt      time.Time
+	Duration       time.Duration
+}
+
+type bucketStatsCache struct {
+	mu    sync.Mutex
+	stats map[string]BucketStats
+}
+
+var bucketStats = &bucketStatsCache{
+	stats: make(map[string]BucketStats),
+}
+
+func (c *bucketStatsCache) set(bucketName string, stats BucketStats) {
+	c.mu.Lock()
+	c.stats[bucketName] = stats
+	c.mu.Unlock()
+
+	events.Publish("stats.ready", newBucketOverview(bucketName, stats))
+}
+
+func (c *bucketStatsCache) get(bucketName string) (BucketStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats, ok := c.stats[bucketName]
+	return stats, ok
+}
+
+// refresh queues a count of the bucket unless one is queued already
+func (c *bucketStatsCache) refresh(bucketName string) {
+	if _, err := statsScans.Enqueue(bucketName, statsPriorityInteractive, "request"); err != nil {
+		log.Printf("failed to queue count of bucket %s: %v", bucketName, err)
+	}
+}
+
+// retain drops buckets that no longer exist
+func (c *bucketStatsCache) retain(bucketNames map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.stats {
+		if !bucketNames[name] {
+			delete(c.stats, name)
+		}
+	}
+}
+
+func (c *bucketStatsCache) snapshot() map[string]BucketStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := make(map[string]BucketStats, len(c.stats))
+	for name, stats := range c.stats {
+		snapshot[name] = stats
+	}
+
+	return snapshot
+}
+
+func scanBucketStats(ctx context.Context, bucketName string) (BucketStats, error) {
+	started := time.Now()
+	stats := BucketStats{Objects: map[string]int64{}, Bytes: map[string]int64{}}
+
+	paginator := s3.NewListObjectsV2Paginator(bucketClient(ctx, bucketName), &s3.ListObjectsV2Input{
+		Bucket:       aws.String(bucketName),
+		RequestPayer: requestPayer(ctx, bucketName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return BucketStats{}, err
+		}
+
+		for _, obj := range page.Contents {
+			storageClass := string(obj.StorageClass)
+			if storageClass == "" {
+				storageClass = "STANDARD"
+			}
+			stats.Objects[storageClass]++
+			stats.Bytes[storageClass] += aws.ToInt64(obj.Size)
+
+			if obj.LastModified != nil && (stats.LatestModified == nil || obj.LastModified.After(*stats.LatestModified)) {
+				stats.LatestModified = obj.LastModified
+			}
+		}
+	}
+
+	stats.ScannedAt = time.Now()
+	stats.Duration = time.Since(started)
+
+	return stats, nil
+}
+
+type StorageClassStats struct {
+	Objects int64 `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+type BucketOverview struct {
+	BucketName     string                       `json:"bucketName"`
+	Status         string                       `json:"status"`
+	Objects        int64                        `json:"objects"`
+	TotalSize      int64                        `json:"totalSize"`
+	AverageSize    int64                        `json:"averageSize"`
+	LatestModified *time.Time                   `json:"latestModified,omitempty"`
+	StorageClasses map[string]StorageClassStats `json:"storageClasses"`
+	ComputedAt     *time.Time                   `json:"computedAt,omitempty"`
+	Refreshing     bool                         `json:"refreshing"`
+}
+
+// getBucketStats answers from the cache and counts the bucket in the
+// background when it is missing or older than bucketStatsTTL. Until the first
+// count is done, the status is pending and the client polls again.
+func getBucketStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	stats, ok := bucketStats.get(bucketName)
+	if !ok || time.Since(stats.ScannedAt) > bucketStatsTTL || r.URL.Query().Get("refresh") == "true" {
+		bucketStats.refresh(bucketName)
+	}
+
+	if !ok {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(BucketOverview{
+			BucketName:     bucketName,
+			Status:         "pending",
+			StorageClasses: map[string]StorageClassStats{},
+			Refreshing:     statsScans.Pending(bucketName),
+		})
+		return
+	}
+
+	overview := newBucketOverview(bucketName, stats)
+	overview.Refreshing = statsScans.Pending(bucketName)
+
+	json.NewEncoder(w).Encode(overview)
+}
+
+func newBucketOverview(bucketName string, stats BucketStats) BucketOverview {
+	overview := BucketOverview{
+		BucketName:     bucketName,
+		Status:         "ready",
+		StorageClasses: map[string]StorageClassStats{},
+	}
+	for storageClass, objects := range stats.Objects {
+		overview.StorageClasses[storageClass] = StorageClassStats{Objects: objects, Bytes: stats.Bytes[storageClass]}
+		overview.Objects += objects
+		overview.TotalSize += stats.Bytes[storageClass]
+	}
+	if overview.Objects > 0 {
+		overview.AverageSize = overview.TotalSize / overview.Objects
+	}
+	overview.LatestModified = stats.LatestModified
+	computedAt := stats.ScannedAt.UTC()
+	overview.ComputedAt = &computedAt
+
+	return overview
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+const defaultChecksumManifest = "SHA256SUMS"
+
+type ChecksumResult struct {
+	BucketName string `json:"bucketName"`
+	Key        string `json:"key"`
+	Objects    int    `json:"objects"`
+}
+
+func hashObject(ctx context.Context, bucketName, key string) (string, int64, error) {
+	result, err := bucketClient(ctx, bucketName).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	defer result.Body.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, result.Body)
+	if err != nil {
+		return "", size, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// writeChecksums hashes every object below prefix into a manifest in the
+// format of sha256sum, with paths relative to the prefix so `sha256sum -c`
+// works from a downloaded copy of the folder
+func writeChecksums(ctx context.Context, job *jobHandle, bucketName, prefix, manifestKey string) (int, error) {
+	var manifest bytes.Buffer
+	objects := 0
+
+	err := walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		// Skip the previous manifest and folder placeholders
+		if key == manifestKey || strings.HasSuffix(key, "/") {
+			return nil
+		}
+
+		job.AddTotal(1)
+		sum, size, err := hashObject(ctx, bucketName, key)
+		job.AddBytes(size)
+		if err != nil {
+			job.Report(key, err)
+			return nil
+		}
+
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, strings.TrimPrefix(key, prefix))
+		objects++
+		job.Report(key, nil)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = bucketClient(ctx, bucketName).PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(manifestKey),
+		Body:        bytes.NewReader(manifest.Bytes()),
+		ContentType: aws.String("text/plain; charset=utf-8"),
+	})
+
+	return objects, err
+}
+
+func createChecksums(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Prefix string `json:"prefix"`
+		// ManifestName is placed below the prefix, SHA256SUMS by default
+		ManifestName string `json:"manifestName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.ManifestName == "" {
+		data.ManifestName = defaultChecksumManifest
+	}
+	if strings.Contains(data.ManifestName, "/") {
+		httpError(w, r, http.StatusBadRequest, "checksum_manifest_name_invalid", data.ManifestName)
+		return
+	}
+
+	prefix := data.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	manifestKey := prefix + data.ManifestName
+
+	job, err := startBucketJob(bucketName, "checksums", prefix, func(ctx context.Context, job *jobHandle) error {
+		objects, err := writeChecksums(ctx, job, bucketName, prefix, manifestKey)
+		if err != nil {
+			return err
+		}
+
+		job.SetResult(ChecksumResult{BucketName: bucketName, Key: manifestKey, Objects: objects})
+		return nil
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	audit.Record(r, "checksums.create", bucketName, manifestKey, map[string]interface{}{"jobId": job.ID})
+
+	writeJobAccepted(w, job)
+}
+
+func downloadChecksums(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	job, ok := jobs.Get(vars["jobId"])
+	if !ok {
+		httpError(w, r, http.StatusNotFound, "job_not_found")
+		return
+	}
+
+	result, ok := job.Result.(ChecksumResult)
+	if job.Type != "checksums" || job.Status != JobCompleted || !ok {
+		httpError(w, r, http.StatusConflict, "checksums_not_ready")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	streamObject(w, r, &s3.GetObjectInput{
+		Bucket: aws.String(result.BucketName),
+		Key:    aws.String(result.Key),
+	}, attachmentDisposition(defaultChecksumManifest))
+}
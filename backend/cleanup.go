@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// cleanup removes finished jobs, long expired share links and inboxes with
+// their transfer requests, access keys past their grace period and old audit
+// events, so the data directory and memory do not grow without bounds
+func cleanup() {
+	cfg := appConfig.Retention
+	now := time.Now()
+
+	if cfg.JobsHours > 0 {
+		if pruned := jobs.Prune(now.Add(-time.Duration(cfg.JobsHours) * time.Hour)); pruned > 0 {
+			log.Printf("cleanup: removed %d finished jobs", pruned)
+		}
+	}
+
+	if cfg.ExpiredSharesDays > 0 {
+		before := now.AddDate(0, 0, -cfg.ExpiredSharesDays)
+
+		pruned, err := shares.DeleteWhere(func(share *Share) bool {
+			return share.ExpiresAt.Before(before)
+		})
+		if err != nil {
+			log.Printf("cleanup: failed to remove expired shares: %v", err)
+		} else if pruned > 0 {
+			log.Printf("cleanup: removed %d expired shares", pruned)
+		}
+
+		pruned, err = inboxes.DeleteWhere(func(inbox *Inbox) bool {
+			return inbox.ExpiresAt.Before(before)
+		})
+		if err != nil {
+			log.Printf("cleanup: failed to remove expired inboxes: %v", err)
+		} else if pruned > 0 {
+			log.Printf("cleanup: removed %d expired inboxes", pruned)
+		}
+
+		// Transfer requests are only useful as long as their inbox exists
+		pruned, err = transfers.DeleteWhere(func(transfer *TransferRequest) bool {
+			_, ok := inboxes.Get(transfer.InboxID)
+			return !ok
+		})
+		if err != nil {
+			log.Printf("cleanup: failed to remove transfer requests: %v", err)
+		} else if pruned > 0 {
+			log.Printf("cleanup: removed %d transfer requests of deleted inboxes", pruned)
+		}
+	}
+
+	if _, err := currentCredentialState(); err != nil {
+		log.Printf("cleanup: failed to drop the previous access key: %v", err)
+	}
+
+	if cfg.AuditDays > 0 {
+		pruned, err := audit.Prune(now.AddDate(0, 0, -cfg.AuditDays))
+		if err != nil {
+			log.Printf("cleanup: failed to prune audit log: %v", err)
+		} else if pruned > 0 {
+			log.Printf("cleanup: removed %d audit events", pruned)
+		}
+	}
+}
+
+func runCleanup() {
+	ticker := time.NewTicker(time.Duration(appConfig.Retention.IntervalMinutes) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		cleanup()
+		<-ticker.C
+	}
+}
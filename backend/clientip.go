@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// trustedProxies holds the parsed server.trusted_proxies ranges
+var trustedProxies []*net.IPNet
+
+// parseCIDRs accepts CIDR ranges as well as single addresses
+func parseCIDRs(values []string) ([]*net.IPNet, *apiError) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, newAPIError("cidr_invalid", value)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			value += "/" + strconv.Itoa(bits)
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, newAPIError("cidr_invalid", value)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only trusted
+// when the request comes from one of the configured proxies, the first address
+// in it that is not a trusted proxy itself is the client.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+
+	if ip == nil || !containsIP(trustedProxies, ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !containsIP(trustedProxies, hop) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// remoteAddr is the client address for logs, resolved through trusted proxies
+func remoteAddr(r *http.Request) string {
+	if ip := clientIP(r); ip != nil {
+		return ip.String()
+	}
+
+	return r.RemoteAddr
+}
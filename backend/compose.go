@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// S3 allows at most 10000 parts per multipart upload
+const maxComposeSources = 10000
+
+func composeObjects(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		SourceKeys     []string `json:"sourceKeys"`
+		DestinationKey string   `json:"destinationKey"`
+		ContentType    string   `json:"contentType"`
+		DeleteSources  bool     `json:"deleteSources"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if len(data.SourceKeys) == 0 || data.DestinationKey == "" {
+		httpError(w, r, http.StatusBadRequest, "compose_keys_required")
+		return
+	}
+	if len(data.SourceKeys) > maxComposeSources {
+		httpError(w, r, http.StatusBadRequest, "compose_too_many_sources", maxComposeSources)
+		return
+	}
+	for _, key := range data.SourceKeys {
+		if key == data.DestinationKey {
+			httpError(w, r, http.StatusBadRequest, "compose_destination_is_source")
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	sizes := make([]int64, len(data.SourceKeys))
+	for i, key := range data.SourceKeys {
+		head, err := bucketClient(ctx, bucketName).HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "object_not_found", fmt.Sprintf("%s: %s", key, err))
+			return
+		}
+		sizes[i] = aws.ToInt64(head.ContentLength)
+	}
+
+	var createInput *s3.CreateMultipartUploadInput
+	if data.ContentType != "" {
+		createInput = &s3.CreateMultipartUploadInput{ContentType: aws.String(data.ContentType)}
+	}
+
+	writer, err := newMultipartWriter(ctx, bucketName, data.DestinationKey, createInput)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "compose_failed", err)
+		return
+	}
+
+	var size int64
+	for i, key := range data.SourceKeys {
+		if err := writer.AddRange(bucketName, key, 0, sizes[i], i == len(data.SourceKeys)-1); err != nil {
+			writer.Abort()
+			httpError(w, r, http.StatusInternalServerError, "compose_failed", err)
+			return
+		}
+		size += sizes[i]
+	}
+
+	if err := writer.Complete(); err != nil {
+		writer.Abort()
+		httpError(w, r, http.StatusInternalServerError, "compose_failed", err)
+		return
+	}
+
+	if data.DeleteSources {
+		var objectsToDelete []types.ObjectIdentifier
+		for _, key := range data.SourceKeys {
+			objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		_, err = bucketClient(ctx, bucketName).DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{Objects: objectsToDelete},
+		})
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "delete_objects_failed", err)
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":  data.DestinationKey,
+		"size": size,
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var downloadCompression *recordStore[DownloadCompression]
+
+// DownloadCompression turns on gzip for text objects downloaded through the
+// backend, for buckets of logs or CSV files browsed over slow links
+type DownloadCompression struct {
+	BucketName string    `json:"bucketName"`
+	Enabled    bool      `json:"enabled"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func newDownloadCompressionStore(dataDir string) (*recordStore[DownloadCompression], error) {
+	return newRecordStore(dataDir, "download-compression.json", func(setting *DownloadCompression) string {
+		return setting.BucketName
+	})
+}
+
+// compressibleTypes are compressed besides text/*
+var compressibleTypes = []string{
+	"application/json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/javascript",
+	"application/x-yaml",
+	"application/yaml",
+	"application/sql",
+	"image/svg+xml",
+}
+
+func isCompressible(contentType, key string) bool {
+	mediaType, _, _ := mime.ParseMediaType(previewContentType(contentType, key))
+	return strings.HasPrefix(mediaType, "text/") || isKnownValue(mediaType, compressibleTypes)
+}
+
+// acceptsGzip checks Accept-Encoding, where gzip;q=0 explicitly refuses it
+func acceptsGzip(r *http.Request) bool {
+	for _, value := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil && parsed == 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// compressDownload reports whether a download is sent gzipped. Ranges refer
+// to the stored bytes and objects stored compressed are sent as they are.
+func compressDownload(r *http.Request, bucketName, key, contentType, contentEncoding string) bool {
+	if r.Header.Get("Range") != "" || contentEncoding != "" || !acceptsGzip(r) {
+		return false
+	}
+
+	setting, ok := downloadCompression.Get(bucketName)
+	return ok && setting.Enabled && isCompressible(contentType, key)
+}
+
+// copyGzipped writes body compressed, the caller has set the headers
+func copyGzipped(w io.Writer, body io.Reader) error {
+	writer := gzip.NewWriter(w)
+	if _, err := io.Copy(writer, body); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func getDownloadCompression(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	setting, ok := downloadCompression.Get(bucketName)
+	if !ok {
+		setting = DownloadCompression{BucketName: bucketName}
+	}
+
+	json.NewEncoder(w).Encode(setting)
+}
+
+func putDownloadCompression(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	setting := DownloadCompression{BucketName: bucketName, Enabled: data.Enabled, UpdatedAt: time.Now().UTC()}
+	if err := downloadCompression.Put(setting); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "download_compression_save_failed", err)
+		return
+	}
+
+	audit.Record(r, "download-compression.put", bucketName, "", map[string]interface{}{"enabled": data.Enabled})
+
+	json.NewEncoder(w).Encode(setting)
+}
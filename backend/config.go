@@ -2,21 +2,210 @@ package main
 
 import (
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
 type AppConfig struct {
 	AWS struct {
-		Region    string `yaml:"region"`
-		AccessKey string `yaml:"access_key"`
-		SecretKey string `yaml:"secret_key"`
-		Endpoint  string `yaml:"endpoint,omitempty"`
+		Region                string   `yaml:"region"`
+		AccessKey             string   `yaml:"access_key"`
+		SecretKey             string   `yaml:"secret_key"`
+		Endpoint              string   `yaml:"endpoint,omitempty"`
+		UseAccelerateEndpoint bool     `yaml:"use_accelerate_endpoint"`
+		AutoDiscover          bool     `yaml:"auto_discover"`
+		Regions               []string `yaml:"regions"`
 	} `yaml:"aws"`
+	Server struct {
+		PublicURL      string   `yaml:"public_url"`
+		BasePath       string   `yaml:"base_path"`
+		StaticDir      string   `yaml:"static_dir"`
+		TrustedProxies []string `yaml:"trusted_proxies"`
+		AllowedCIDRs   []string `yaml:"allowed_cidrs"`
+		DeniedCIDRs    []string `yaml:"denied_cidrs"`
+	} `yaml:"server"`
+	SMTP struct {
+		Host     string `yaml:"host"`
+		Port     int    `yaml:"port"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+		From     string `yaml:"from"`
+	} `yaml:"smtp"`
+	Storage struct {
+		DataDir string `yaml:"data_dir"`
+	} `yaml:"storage"`
+	Uploads struct {
+		Dedup bool `yaml:"dedup"`
+		// FetchPrivateNetworks lets URL fetches reach loopback and private
+		// addresses
+		FetchPrivateNetworks bool `yaml:"fetch_private_networks"`
+	} `yaml:"uploads"`
+	Inboxes struct {
+		ScanCommand []string `yaml:"scan_command"`
+	} `yaml:"inboxes"`
+	Copy struct {
+		PartSizeMB  int `yaml:"part_size_mb"`
+		Concurrency int `yaml:"concurrency"`
+	} `yaml:"copy"`
+	Archives struct {
+		Bucket string `yaml:"bucket"`
+		Prefix string `yaml:"prefix"`
+	} `yaml:"archives"`
+	Features struct {
+		Versioning bool `yaml:"versioning"`
+		MinioAdmin bool `yaml:"minio_admin"`
+		Jobs       bool `yaml:"jobs"`
+		Trash      bool `yaml:"trash"`
+	} `yaml:"features"`
+	AccessLog struct {
+		Enabled    bool   `yaml:"enabled"`
+		Output     string `yaml:"output"`
+		Format     string `yaml:"format"`
+		MaxSizeMB  int    `yaml:"max_size_mb"`
+		MaxBackups int    `yaml:"max_backups"`
+	} `yaml:"access_log"`
+	Branding struct {
+		InstanceName   string `yaml:"instance_name"`
+		LogoURL        string `yaml:"logo_url"`
+		PrimaryColor   string `yaml:"primary_color"`
+		BannerText     string `yaml:"banner_text"`
+		BannerSeverity string `yaml:"banner_severity"`
+	} `yaml:"branding"`
+	Audit struct {
+		SigningKey           string `yaml:"signing_key"`
+		BatchSize            int    `yaml:"batch_size"`
+		FlushIntervalSeconds int    `yaml:"flush_interval_seconds"`
+		MaxRetries           int    `yaml:"max_retries"`
+		QueueSize            int    `yaml:"queue_size"`
+		Syslog               struct {
+			Address  string `yaml:"address"`
+			AppName  string `yaml:"app_name"`
+			Facility int    `yaml:"facility"`
+		} `yaml:"syslog"`
+		HTTP struct {
+			URL    string `yaml:"url"`
+			Format string `yaml:"format"`
+			Token  string `yaml:"token"`
+			Index  string `yaml:"index"`
+		} `yaml:"http"`
+		File struct {
+			Path       string `yaml:"path"`
+			MaxSizeMB  int    `yaml:"max_size_mb"`
+			MaxBackups int    `yaml:"max_backups"`
+		} `yaml:"file"`
+	} `yaml:"audit"`
+	Preview struct {
+		MaxSizeMB int `yaml:"max_size_mb"`
+	} `yaml:"preview"`
+	Editor struct {
+		MaxSizeKB int `yaml:"max_size_kb"`
+	} `yaml:"editor"`
+	Thumbnails struct {
+		MaxSize     int    `yaml:"max_size"`
+		MaxSourceMB int    `yaml:"max_source_mb"`
+		Storage     string `yaml:"storage"`
+		Prefix      string `yaml:"prefix"`
+	} `yaml:"thumbnails"`
+	Health struct {
+		ProbeIntervalSeconds int `yaml:"probe_interval_seconds"`
+		ProbeTimeoutSeconds  int `yaml:"probe_timeout_seconds"`
+		RetentionHours       int `yaml:"retention_hours"`
+	} `yaml:"health"`
+	BucketStats struct {
+		MaxConcurrentScans int `yaml:"max_concurrent_scans"`
+	} `yaml:"bucket_stats"`
+	Metrics struct {
+		Enabled                    bool   `yaml:"enabled"`
+		Token                      string `yaml:"token"`
+		BucketStatsIntervalMinutes int    `yaml:"bucket_stats_interval_minutes"`
+	} `yaml:"metrics"`
+	PublicIndexes struct {
+		RequestsPerMinute int `yaml:"requests_per_minute"`
+	} `yaml:"public_indexes"`
+	Pricing struct {
+		Currency string                        `yaml:"currency"`
+		Regions  map[string]map[string]float64 `yaml:"regions"`
+	} `yaml:"pricing"`
+	Debug struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"debug"`
+	Credentials struct {
+		GraceHours int `yaml:"grace_hours"`
+	} `yaml:"credentials"`
+	Growth struct {
+		Enabled       bool `yaml:"enabled"`
+		IntervalHours int  `yaml:"interval_hours"`
+		RetentionDays int  `yaml:"retention_days"`
+		Prefixes      []struct {
+			Bucket string `yaml:"bucket"`
+			Prefix string `yaml:"prefix"`
+		} `yaml:"prefixes"`
+	} `yaml:"growth"`
+	Jobs struct {
+		TimeoutMinutes int            `yaml:"timeout_minutes"`
+		Timeouts       map[string]int `yaml:"timeouts"`
+	} `yaml:"jobs"`
+	Schedules []ConfiguredSchedule `yaml:"schedules"`
+	Watch     struct {
+		DiffIntervalSeconds int `yaml:"diff_interval_seconds"`
+	} `yaml:"watch"`
+	Listing struct {
+		CacheTTLSeconds int `yaml:"cache_ttl_seconds"`
+		MaxEntries      int `yaml:"max_entries"`
+	} `yaml:"listing"`
+	Retention struct {
+		IntervalMinutes   int `yaml:"interval_minutes"`
+		JobsHours         int `yaml:"jobs_hours"`
+		ExpiredSharesDays int `yaml:"expired_shares_days"`
+		AuditDays         int `yaml:"audit_days"`
+	} `yaml:"retention"`
 }
 
 func NewConfig(path string) (*AppConfig, error) {
 	appConfig := &AppConfig{}
+	appConfig.Storage.DataDir = "data"
+	appConfig.SMTP.Port = 587
+	appConfig.Archives.Prefix = ".s3-admin/archives"
+	appConfig.Copy.PartSizeMB = 512
+	appConfig.Copy.Concurrency = 4
+	appConfig.Features.Versioning = true
+	appConfig.Features.MinioAdmin = true
+	appConfig.Features.Jobs = true
+	appConfig.Features.Trash = true
+	appConfig.AccessLog.Output = "stdout"
+	appConfig.AccessLog.Format = "combined"
+	appConfig.AccessLog.MaxSizeMB = 100
+	appConfig.AccessLog.MaxBackups = 5
+	appConfig.Branding.InstanceName = "S3 Admin"
+	appConfig.Branding.BannerSeverity = "warning"
+	appConfig.Audit.BatchSize = 100
+	appConfig.Audit.FlushIntervalSeconds = 5
+	appConfig.Audit.MaxRetries = 5
+	appConfig.Audit.QueueSize = 10000
+	appConfig.Audit.Syslog.AppName = "s3-admin"
+	appConfig.Audit.Syslog.Facility = 16
+	appConfig.Audit.HTTP.Format = "json"
+	appConfig.Audit.HTTP.Index = "s3-admin-audit"
+	appConfig.Audit.File.MaxSizeMB = 100
+	appConfig.Audit.File.MaxBackups = 10
+	appConfig.Preview.MaxSizeMB = 50
+	appConfig.Editor.MaxSizeKB = 1024
+	appConfig.Thumbnails.MaxSize = 256
+	appConfig.Thumbnails.MaxSourceMB = 20
+	appConfig.Thumbnails.Storage = "local"
+	appConfig.Thumbnails.Prefix = ".thumbnails"
+	appConfig.Health.ProbeTimeoutSeconds = 10
+	appConfig.Health.RetentionHours = 168
+	appConfig.BucketStats.MaxConcurrentScans = 2
+	appConfig.Metrics.BucketStatsIntervalMinutes = 60
+	appConfig.PublicIndexes.RequestsPerMinute = 60
+	appConfig.Pricing.Currency = "USD"
+	appConfig.Credentials.GraceHours = 24
+	appConfig.Growth.IntervalHours = 24
+	appConfig.Growth.RetentionDays = 730
+	appConfig.Listing.MaxEntries = 1000
+	appConfig.Retention.JobsHours = 168
 
 	configFile, err := os.ReadFile(path)
 	if err == nil {
@@ -37,6 +226,44 @@ func NewConfig(path string) (*AppConfig, error) {
 	if os.Getenv("AWS_ENDPOINT") != "" {
 		appConfig.AWS.Endpoint = os.Getenv("AWS_ENDPOINT")
 	}
+	if os.Getenv("SMTP_PASSWORD") != "" {
+		appConfig.SMTP.Password = os.Getenv("SMTP_PASSWORD")
+	}
+	if os.Getenv("UPLOAD_DEDUP") != "" {
+		appConfig.Uploads.Dedup = os.Getenv("UPLOAD_DEDUP") == "true"
+	}
+	if os.Getenv("DATA_DIR") != "" {
+		appConfig.Storage.DataDir = os.Getenv("DATA_DIR")
+	}
+	if os.Getenv("ACCESS_LOG") != "" {
+		appConfig.AccessLog.Enabled = true
+		appConfig.AccessLog.Output = os.Getenv("ACCESS_LOG")
+	}
+	if os.Getenv("AUDIT_SIGNING_KEY") != "" {
+		appConfig.Audit.SigningKey = os.Getenv("AUDIT_SIGNING_KEY")
+	}
+	if os.Getenv("AUDIT_HTTP_TOKEN") != "" {
+		appConfig.Audit.HTTP.Token = os.Getenv("AUDIT_HTTP_TOKEN")
+	}
+	if os.Getenv("METRICS_TOKEN") != "" {
+		appConfig.Metrics.Token = os.Getenv("METRICS_TOKEN")
+	}
+	if os.Getenv("BASE_PATH") != "" {
+		appConfig.Server.BasePath = os.Getenv("BASE_PATH")
+	}
+
+	appConfig.Server.BasePath = normalizeBasePath(appConfig.Server.BasePath)
 
 	return appConfig, nil
 }
+
+// normalizeBasePath turns "s3-admin/" and similar spellings into "/s3-admin",
+// the root path is represented by an empty string
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return ""
+	}
+
+	return "/" + basePath
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// serverSideCopyUnsupported remembers bucket pairs for which the provider
+// refused CopyObject, so later copies go straight to the streamed fallback
+var serverSideCopyUnsupported sync.Map
+
+func copyPairKey(srcBucket, dstBucket string) string {
+	return srcBucket + "\x00" + dstBucket
+}
+
+// isCopyUnsupported reports whether the provider does not implement
+// server-side copies at all
+func isCopyUnsupported(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "NotImplemented", "XNotImplemented":
+		return true
+	}
+
+	return false
+}
+
+// isCopyDenied reports whether a server-side copy of one object was denied,
+// e.g. by a KMS key, an ACL or a policy. Reading and writing the object
+// separately may still be allowed.
+func isCopyDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDenied"
+}
+
+// copyProgress is told about every chunk of bytes that has been copied, it may be nil
+type copyProgress func(bytes int64)
+
+func (p copyProgress) add(bytes int64) {
+	if p != nil {
+		p(bytes)
+	}
+}
+
+// copyObject copies server-side where the provider supports it and falls
+// back to streaming the object through the backend otherwise. Objects above
+// the CopyObject limit are copied in parts.
+func copyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, options CopyOptions, progress copyProgress) error {
+	head, err := bucketClient(ctx, srcBucket).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := copyObjectData(ctx, head, options, srcBucket, srcKey, dstBucket, dstKey, progress); err != nil {
+		return err
+	}
+
+	if options.ACL == copyPreserve {
+		return copyObjectACL(ctx, srcBucket, srcKey, dstBucket, dstKey)
+	}
+
+	return nil
+}
+
+func copyObjectData(ctx context.Context, head *s3.HeadObjectOutput, options CopyOptions, srcBucket, srcKey, dstBucket, dstKey string, progress copyProgress) error {
+	size := aws.ToInt64(head.ContentLength)
+
+	pair := copyPairKey(srcBucket, dstBucket)
+	_, stream := serverSideCopyUnsupported.Load(pair)
+	if !stream && size <= maxCopyObjectSize {
+		// CopyObject takes the tags along by itself
+		target, err := options.resolve(ctx, head, srcBucket, srcKey, false)
+		if err != nil {
+			return err
+		}
+
+		input := &s3.CopyObjectInput{
+			Bucket:     aws.String(dstBucket),
+			Key:        aws.String(dstKey),
+			CopySource: aws.String(copySource(srcBucket, srcKey)),
+		}
+		target.applyTo(input, options)
+
+		_, err = bucketClient(ctx, dstBucket).CopyObject(ctx, input)
+		if err == nil {
+			progress.add(size)
+			return nil
+		}
+		switch {
+		case isCopyUnsupported(err):
+			serverSideCopyUnsupported.Store(pair, true)
+		case isCopyDenied(err):
+			// Only this object is streamed, later copies still try server-side
+		default:
+			return err
+		}
+		stream = true
+	}
+
+	target, err := options.resolve(ctx, head, srcBucket, srcKey, true)
+	if err != nil {
+		return err
+	}
+
+	if !stream {
+		err := multipartCopyObject(ctx, head, target, srcBucket, srcKey, dstBucket, dstKey, progress)
+		if !isCopyUnsupported(err) {
+			return err
+		}
+		// Providers without UploadPartCopy get large objects streamed as well
+		serverSideCopyUnsupported.Store(pair, true)
+	}
+
+	return streamCopyObject(ctx, target, size, srcBucket, srcKey, dstBucket, dstKey, progress)
+}
+
+// multipartCopyObject copies objects larger than 5 GiB with concurrent UploadPartCopy calls
+func multipartCopyObject(ctx context.Context, head *s3.HeadObjectOutput, target copyTarget, srcBucket, srcKey, dstBucket, dstKey string, progress copyProgress) error {
+	size := aws.ToInt64(head.ContentLength)
+
+	// Grow the parts if the configured size would exceed the 10000 part limit
+	partSize := max(int64(appConfig.Copy.PartSizeMB)<<20, (size+maxUploadParts-1)/maxUploadParts, minPartSize)
+	partSize = min(partSize, maxCopyPartSize)
+	partCount := int((size + partSize - 1) / partSize)
+
+	input := target.multipartInput()
+	input.Bucket = aws.String(dstBucket)
+	input.Key = aws.String(dstKey)
+
+	created, err := bucketClient(ctx, dstBucket).CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make([]types.CompletedPart, partCount)
+	errs := make(chan error, partCount)
+	slots := make(chan struct{}, max(appConfig.Copy.Concurrency, 1))
+	var wg sync.WaitGroup
+
+	for i := 0; i < partCount; i++ {
+		start := int64(i) * partSize
+		end := min(start+partSize, size)
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, start, end int64) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result, err := bucketClient(ctx, dstBucket).UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+				Bucket:          aws.String(dstBucket),
+				Key:             aws.String(dstKey),
+				UploadId:        created.UploadId,
+				PartNumber:      aws.Int32(int32(i + 1)),
+				CopySource:      aws.String(copySource(srcBucket, srcKey)),
+				CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end-1)),
+			})
+			if err != nil {
+				errs <- err
+				cancel()
+				return
+			}
+
+			parts[i] = types.CompletedPart{
+				ETag:       result.CopyPartResult.ETag,
+				PartNumber: aws.Int32(int32(i + 1)),
+			}
+			progress.add(end - start)
+		}(i, start, end)
+	}
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		bucketClient(context.Background(), dstBucket).AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(dstBucket),
+			Key:      aws.String(dstKey),
+			UploadId: created.UploadId,
+		})
+		return err
+	}
+
+	_, err = bucketClient(ctx, dstBucket).CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(dstBucket),
+		Key:             aws.String(dstKey),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+
+	return err
+}
+
+// streamCopyObject reads the object and uploads it again, with parts large
+// enough for size to fit into the part limit
+func streamCopyObject(ctx context.Context, target copyTarget, size int64, srcBucket, srcKey, dstBucket, dstKey string, progress copyProgress) error {
+	result, err := bucketClient(ctx, srcBucket).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+	})
+	if err != nil {
+		return err
+	}
+	defer result.Body.Close()
+
+	writer, err := newMultipartWriter(ctx, dstBucket, dstKey, target.multipartInput())
+	if err != nil {
+		return err
+	}
+	writer.partSize = int(max(minPartSize, (size+maxUploadParts-1)/maxUploadParts))
+
+	copied, err := writer.ReadFrom(result.Body)
+	if err != nil {
+		writer.Abort()
+		return err
+	}
+	if err := writer.Complete(); err != nil {
+		writer.Abort()
+		return err
+	}
+	progress.add(copied)
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	copyPreserve = "preserve"
+	copyReplace  = "replace"
+	copyMerge    = "merge"
+	// copyDefault leaves a setting to the destination bucket, like CopyObject does
+	copyDefault = "default"
+)
+
+// CopyOptions controls what a copy takes along from its source. The zero
+// value keeps metadata and tags and leaves ACL and storage class to the
+// destination bucket, which is what a plain CopyObject does on AWS.
+type CopyOptions struct {
+	// Metadata is preserve, replace or merge with MetadataValues
+	Metadata       string         `json:"metadata"`
+	MetadataValues ObjectMetadata `json:"metadataValues"`
+	// Tags is preserve, replace or merge with TagValues
+	Tags      string            `json:"tags"`
+	TagValues map[string]string `json:"tagValues"`
+	// ACL is default or preserve
+	ACL string `json:"acl"`
+	// StorageClass is default, preserve or a storage class for all copies
+	StorageClass string `json:"storageClass"`
+}
+
+func (o *CopyOptions) validate() *apiError {
+	if o.Metadata == "" {
+		o.Metadata = copyPreserve
+	}
+	if o.Tags == "" {
+		o.Tags = copyPreserve
+	}
+	if o.ACL == "" {
+		o.ACL = copyDefault
+	}
+	if o.StorageClass == "" {
+		o.StorageClass = copyDefault
+	}
+
+	modes := []string{copyPreserve, copyReplace, copyMerge}
+	if !isKnownValue(o.Metadata, modes) {
+		return newAPIError("copy_option_invalid", o.Metadata, "metadata")
+	}
+	if !isKnownValue(o.Tags, modes) {
+		return newAPIError("copy_option_invalid", o.Tags, "tags")
+	}
+	if !isKnownValue(o.ACL, []string{copyDefault, copyPreserve}) {
+		return newAPIError("copy_option_invalid", o.ACL, "acl")
+	}
+	if o.StorageClass != copyDefault && o.StorageClass != copyPreserve &&
+		!isKnownValue(o.StorageClass, types.StorageClass("").Values()) {
+		return newAPIError("copy_option_invalid", o.StorageClass, "storageClass")
+	}
+
+	if o.Tags != copyPreserve {
+		if apiErr := validateTags(o.TagValues, maxObjectTags); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	// MinIO has no object ACLs, other providers report it per object
+	capabilities.detect(context.Background(), appConfig.AWS.Endpoint)
+	if o.ACL == copyPreserve && capabilities.provider == providerMinIO {
+		return newAPIError("copy_acl_unsupported")
+	}
+
+	return nil
+}
+
+// copyTarget is what the copy of one object gets, resolved from its source
+type copyTarget struct {
+	metadata     ObjectMetadata
+	tags         map[string]string
+	storageClass types.StorageClass
+}
+
+// resolve works out the copy's metadata, tags and storage class. The source's
+// tags are only fetched when they are merged or have to be set explicitly,
+// which sourceTags says.
+func (o CopyOptions) resolve(ctx context.Context, head *s3.HeadObjectOutput, srcBucket, srcKey string, sourceTags bool) (copyTarget, error) {
+	target := copyTarget{metadata: headObjectMetadata(head)}
+
+	switch o.Metadata {
+	case copyReplace:
+		target.metadata = o.MetadataValues
+	case copyMerge:
+		values := o.MetadataValues
+		metadata := maps.Clone(target.metadata.Metadata)
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		maps.Copy(metadata, values.Metadata)
+		values.Metadata = metadata
+		target.metadata = target.metadata.overlay(values)
+	}
+
+	if o.Tags == copyReplace {
+		target.tags = o.TagValues
+	} else if o.Tags == copyMerge || sourceTags {
+		result, err := bucketClient(ctx, srcBucket).GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+			Bucket: aws.String(srcBucket),
+			Key:    aws.String(srcKey),
+		})
+		// Providers without tagging have no tags to preserve
+		var apiErr smithy.APIError
+		if err != nil && !(errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented") {
+			return copyTarget{}, err
+		}
+		if result != nil {
+			target.tags = tagsToMap(result.TagSet)
+		}
+
+		if o.Tags == copyMerge {
+			if target.tags == nil {
+				target.tags = map[string]string{}
+			}
+			maps.Copy(target.tags, o.TagValues)
+			if apiErr := validateTags(target.tags, maxObjectTags); apiErr != nil {
+				return copyTarget{}, apiErr
+			}
+		}
+	}
+
+	switch o.StorageClass {
+	case copyPreserve:
+		target.storageClass = head.StorageClass
+	case copyDefault:
+	default:
+		target.storageClass = types.StorageClass(o.StorageClass)
+	}
+
+	return target, nil
+}
+
+// tagging encodes the tags the way S3 expects them in request headers
+func (t copyTarget) tagging() *string {
+	if len(t.tags) == 0 {
+		return nil
+	}
+
+	values := url.Values{}
+	for key, value := range t.tags {
+		values.Set(key, value)
+	}
+
+	return aws.String(values.Encode())
+}
+
+// applyTo sets up a server-side copy. CopyObject keeps metadata and tags by
+// itself, they are only sent along when they change.
+func (t copyTarget) applyTo(input *s3.CopyObjectInput, options CopyOptions) {
+	if options.Metadata != copyPreserve {
+		input.MetadataDirective = types.MetadataDirectiveReplace
+		input.ContentType = t.metadata.ContentType
+		input.CacheControl = t.metadata.CacheControl
+		input.ContentDisposition = t.metadata.ContentDisposition
+		input.ContentEncoding = t.metadata.ContentEncoding
+		input.ContentLanguage = t.metadata.ContentLanguage
+		input.Metadata = t.metadata.Metadata
+	}
+	if options.Tags != copyPreserve {
+		input.TaggingDirective = types.TaggingDirectiveReplace
+		input.Tagging = t.tagging()
+	}
+	input.StorageClass = t.storageClass
+}
+
+// multipartInput sets up copies in parts, which start without anything
+func (t copyTarget) multipartInput() *s3.CreateMultipartUploadInput {
+	return &s3.CreateMultipartUploadInput{
+		ContentType:        t.metadata.ContentType,
+		CacheControl:       t.metadata.CacheControl,
+		ContentDisposition: t.metadata.ContentDisposition,
+		ContentEncoding:    t.metadata.ContentEncoding,
+		ContentLanguage:    t.metadata.ContentLanguage,
+		Metadata:           t.metadata.Metadata,
+		Tagging:            t.tagging(),
+		StorageClass:       t.storageClass,
+	}
+}
+
+// copyObjectACL gives the copy the grants of its source. The grants still
+// name the source's owner, which is what keeps its access on AWS.
+func copyObjectACL(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	acl, err := bucketClient(ctx, srcBucket).GetObjectAcl(ctx, &s3.GetObjectAclInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = bucketClient(ctx, dstBucket).PutObjectAcl(ctx, &s3.PutObjectAclInput{
+		Bucket: aws.String(dstBucket),
+		Key:    aws.String(dstKey),
+		AccessControlPolicy: &types.AccessControlPolicy{
+			Grants: acl.Grants,
+			Owner:  acl.Owner,
+		},
+	})
+
+	return err
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+// S3 accepts at most 100 rules per CORS configuration
+const maxCORSRules = 100
+
+var corsMethods = []string{"GET", "PUT", "POST", "DELETE", "HEAD"}
+
+type CORSRule struct {
+	ID             string   `json:"id,omitempty"`
+	AllowedOrigins []string `json:"allowedOrigins"`
+	AllowedMethods []string `json:"allowedMethods"`
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+	ExposeHeaders  []string `json:"exposeHeaders,omitempty"`
+	MaxAgeSeconds  int32    `json:"maxAgeSeconds,omitempty"`
+}
+
+func (c *CORSRule) Validate() *apiError {
+	if len(c.AllowedOrigins) == 0 {
+		return newAPIError("cors_origins_required")
+	}
+	for _, origin := range c.AllowedOrigins {
+		// Origins may contain at most one wildcard
+		if origin == "" || strings.Count(origin, "*") > 1 {
+			return newAPIError("cors_origin_invalid", origin)
+		}
+	}
+
+	if len(c.AllowedMethods) == 0 {
+		return newAPIError("cors_methods_required")
+	}
+	for i, method := range c.AllowedMethods {
+		c.AllowedMethods[i] = strings.ToUpper(method)
+		if !isKnownValue(c.AllowedMethods[i], corsMethods) {
+			return newAPIError("cors_method_invalid", method)
+		}
+	}
+
+	for _, header := range c.AllowedHeaders {
+		if header == "" || strings.Count(header, "*") > 1 {
+			return newAPIError("cors_header_invalid", header)
+		}
+	}
+	if c.MaxAgeSeconds < 0 {
+		return newAPIError("cors_max_age_invalid")
+	}
+
+	return nil
+}
+
+func (c *CORSRule) toS3() types.CORSRule {
+	rule := types.CORSRule{
+		AllowedOrigins: c.AllowedOrigins,
+		AllowedMethods: c.AllowedMethods,
+		AllowedHeaders: c.AllowedHeaders,
+		ExposeHeaders:  c.ExposeHeaders,
+	}
+	if c.ID != "" {
+		rule.ID = aws.String(c.ID)
+	}
+	if c.MaxAgeSeconds > 0 {
+		rule.MaxAgeSeconds = aws.Int32(c.MaxAgeSeconds)
+	}
+
+	return rule
+}
+
+func getBucketCORS(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetBucketCors(r.Context(), &s3.GetBucketCorsInput{
+		Bucket: aws.String(bucketName),
+	})
+
+	// Buckets without rules answer with an error instead of an empty configuration
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchCORSConfiguration" {
+		json.NewEncoder(w).Encode([]CORSRule{})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_cors_failed", err)
+		return
+	}
+
+	rules := make([]CORSRule, 0, len(result.CORSRules))
+	for _, rule := range result.CORSRules {
+		rules = append(rules, CORSRule{
+			ID:             aws.ToString(rule.ID),
+			AllowedOrigins: rule.AllowedOrigins,
+			AllowedMethods: rule.AllowedMethods,
+			AllowedHeaders: rule.AllowedHeaders,
+			ExposeHeaders:  rule.ExposeHeaders,
+			MaxAgeSeconds:  aws.ToInt32(rule.MaxAgeSeconds),
+		})
+	}
+
+	json.NewEncoder(w).Encode(rules)
+}
+
+func putBucketCORS(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var rules []CORSRule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if len(rules) > maxCORSRules {
+		httpError(w, r, http.StatusBadRequest, "cors_too_many_rules", maxCORSRules)
+		return
+	}
+	for i := range rules {
+		if apiErr := rules[i].Validate(); apiErr != nil {
+			writeAPIError(w, r, http.StatusBadRequest, apiErr)
+			return
+		}
+	}
+
+	// An empty configuration is rejected by PutBucketCors, clearing means deleting
+	if len(rules) == 0 {
+		deleteBucketCORS(w, r)
+		return
+	}
+
+	configuration := &types.CORSConfiguration{}
+	for i := range rules {
+		configuration.CORSRules = append(configuration.CORSRules, rules[i].toS3())
+	}
+
+	_, err := bucketClient(r.Context(), bucketName).PutBucketCors(r.Context(), &s3.PutBucketCorsInput{
+		Bucket:            aws.String(bucketName),
+		CORSConfiguration: configuration,
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_cors_failed", err)
+		return
+	}
+
+	audit.Record(r, "cors.put", bucketName, "", map[string]interface{}{"rules": len(rules)})
+
+	json.NewEncoder(w).Encode(rules)
+}
+
+func deleteBucketCORS(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	_, err := bucketClient(r.Context(), bucketName).DeleteBucketCors(r.Context(), &s3.DeleteBucketCorsInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "delete_cors_failed", err)
+		return
+	}
+
+	audit.Record(r, "cors.delete", bucketName, "", nil)
+
+	w.WriteHeader(http.StatusOK)
+}
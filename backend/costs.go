@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+type StorageClassCost struct {
+	Bytes       int64   `json:"bytes"`
+	PricePerGB  float64 `json:"pricePerGB"`
+	MonthlyCost float64 `json:"monthlyCost"`
+}
+
+type CostEstimate struct {
+	BucketName     string                      `json:"bucketName"`
+	Prefix         string                      `json:"prefix,omitempty"`
+	Status         string                      `json:"status"`
+	Region         string                      `json:"region"`
+	Currency       string                      `json:"currency"`
+	Bytes          int64                       `json:"bytes"`
+	MonthlyCost    float64                     `json:"monthlyCost"`
+	StorageClasses map[string]StorageClassCost `json:"storageClasses"`
+	ComputedAt     *time.Time                  `json:"computedAt,omitempty"`
+}
+
+func (e *CostEstimate) add(storageClass string, bytes int64) {
+	cost := e.StorageClasses[storageClass]
+	cost.Bytes += bytes
+	cost.PricePerGB = storagePrice(e.Region, storageClass)
+	cost.MonthlyCost = monthlyStorageCost(e.Region, storageClass, cost.Bytes)
+	e.StorageClasses[storageClass] = cost
+}
+
+// getCostEstimate prices the current object versions of a bucket or prefix
+// with the table of the bucket's region. Whole buckets come from the counts
+// behind the bucket stats and may be pending like those, prefixes are listed
+// while the client waits.
+func getCostEstimate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	ctx := r.Context()
+
+	prefix := r.URL.Query().Get("prefix")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	estimate := CostEstimate{
+		BucketName:     bucketName,
+		Prefix:         prefix,
+		Status:         "ready",
+		Region:         regions.Locate(ctx, bucketName),
+		Currency:       appConfig.Pricing.Currency,
+		StorageClasses: map[string]StorageClassCost{},
+	}
+
+	if prefix == "" {
+		stats, ok := bucketStats.get(bucketName)
+		if !ok || time.Since(stats.ScannedAt) > bucketStatsTTL {
+			bucketStats.refresh(bucketName)
+		}
+		if !ok {
+			estimate.Status = "pending"
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(estimate)
+			return
+		}
+
+		for storageClass, bytes := range stats.Bytes {
+			estimate.add(storageClass, bytes)
+		}
+		computedAt := stats.ScannedAt.UTC()
+		estimate.ComputedAt = &computedAt
+	} else {
+		err := walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+			storageClass := string(obj.StorageClass)
+			if storageClass == "" {
+				storageClass = "STANDARD"
+			}
+			estimate.add(storageClass, aws.ToInt64(obj.Size))
+			return nil
+		})
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "list_objects_failed", err)
+			return
+		}
+		computedAt := time.Now().UTC()
+		estimate.ComputedAt = &computedAt
+	}
+
+	for _, cost := range estimate.StorageClasses {
+		estimate.Bytes += cost.Bytes
+		estimate.MonthlyCost += cost.MonthlyCost
+	}
+
+	json.NewEncoder(w).Encode(estimate)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// AccessKeyPair is a static access key of the configured account
+type AccessKeyPair struct {
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+}
+
+// CredentialState is the outcome of the last rotation. It replaces the keys
+// from the config only as long as the config still holds the key that was
+// rotated away from, so editing the config afterwards takes precedence.
+type CredentialState struct {
+	RotatedFrom       string         `json:"rotatedFrom"`
+	Active            AccessKeyPair  `json:"active"`
+	Previous          *AccessKeyPair `json:"previous,omitempty"`
+	PreviousExpiresAt *time.Time     `json:"previousExpiresAt,omitempty"`
+	RotatedAt         time.Time      `json:"rotatedAt"`
+}
+
+type CredentialStatus struct {
+	AccessKeyID         string     `json:"accessKeyId"`
+	Source              string     `json:"source"`
+	RotatedAt           *time.Time `json:"rotatedAt,omitempty"`
+	PreviousAccessKeyID string     `json:"previousAccessKeyId,omitempty"`
+	PreviousExpiresAt   *time.Time `json:"previousExpiresAt,omitempty"`
+}
+
+type KeyValidation struct {
+	AccessKeyID string `json:"accessKeyId"`
+	Valid       bool   `json:"valid"`
+	Buckets     int    `json:"buckets"`
+	Error       string `json:"error,omitempty"`
+	buckets     map[string]bool
+}
+
+var credentialState *documentStore[CredentialState]
+
+// credentialsCache is the cache the SDK keeps in front of the key pair, it
+// has to forget the old pair after a swap
+var credentialsCache *aws.CredentialsCache
+
+var activeKeys struct {
+	mu   sync.RWMutex
+	pair AccessKeyPair
+}
+
+func newCredentialStore(dataDir string) (*documentStore[CredentialState], error) {
+	return newDocumentStore(dataDir, "credentials.json", CredentialState{})
+}
+
+// loadActiveCredentials picks the key pair to start with
+func loadActiveCredentials() {
+	pair := AccessKeyPair{AccessKeyID: appConfig.AWS.AccessKey, SecretAccessKey: appConfig.AWS.SecretKey}
+
+	state := credentialState.Get()
+	if state.Active.AccessKeyID != "" {
+		if state.RotatedFrom == appConfig.AWS.AccessKey {
+			pair = state.Active
+		} else {
+			log.Printf("ignoring rotated access key %s, the configured key changed since", state.Active.AccessKeyID)
+		}
+	}
+
+	activeKeys.mu.Lock()
+	activeKeys.pair = pair
+	activeKeys.mu.Unlock()
+}
+
+func activeCredentials() AccessKeyPair {
+	activeKeys.mu.RLock()
+	defer activeKeys.mu.RUnlock()
+
+	return activeKeys.pair
+}
+
+func swapCredentials(pair AccessKeyPair) {
+	activeKeys.mu.Lock()
+	activeKeys.pair = pair
+	activeKeys.mu.Unlock()
+
+	if credentialsCache != nil {
+		credentialsCache.Invalidate()
+	}
+}
+
+// currentCredentialState drops the previous pair once its grace period is over
+func currentCredentialState() (CredentialState, error) {
+	state := credentialState.Get()
+	if state.Previous != nil && state.PreviousExpiresAt != nil && time.Now().After(*state.PreviousExpiresAt) {
+		state.Previous = nil
+		state.PreviousExpiresAt = nil
+		if err := credentialState.Set(state); err != nil {
+			return state, err
+		}
+	}
+
+	return state, nil
+}
+
+// validateKeyPair lists the buckets with pair, which needs no permissions
+// beyond what the admin needs anyway
+func validateKeyPair(ctx context.Context, pair AccessKeyPair) KeyValidation {
+	validation := KeyValidation{AccessKeyID: pair.AccessKeyID, buckets: map[string]bool{}}
+
+	client := s3.New(s3Client.Options(), func(o *s3.Options) {
+		o.Credentials = aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: pair.AccessKeyID, SecretAccessKey: pair.SecretAccessKey}, nil
+		})
+	})
+	result, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		validation.Error = err.Error()
+		return validation
+	}
+
+	validation.Valid = true
+	validation.Buckets = len(result.Buckets)
+	for _, bucket := range result.Buckets {
+		validation.buckets[aws.ToString(bucket.Name)] = true
+	}
+
+	return validation
+}
+
+func getCredentialStatus(w http.ResponseWriter, r *http.Request) {
+	state, err := currentCredentialState()
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "credentials_state_failed", err)
+		return
+	}
+
+	status := CredentialStatus{AccessKeyID: activeCredentials().AccessKeyID, Source: "config"}
+	if state.Active.AccessKeyID != "" && state.Active.AccessKeyID == status.AccessKeyID && state.RotatedFrom == appConfig.AWS.AccessKey {
+		status.Source = "rotation"
+		status.RotatedAt = &state.RotatedAt
+		if state.Previous != nil {
+			status.PreviousAccessKeyID = state.Previous.AccessKeyID
+			status.PreviousExpiresAt = state.PreviousExpiresAt
+		}
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
+
+// rotateCredentials checks the new key pair next to the current one and
+// switches all clients over once the new pair sees at least the same
+// buckets. The current pair is kept for a rollback during the grace period.
+func rotateCredentials(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AccessKeyID     string `json:"accessKeyId"`
+		SecretAccessKey string `json:"secretAccessKey"`
+		GraceHours      *int   `json:"graceHours"`
+		// DryRun only compares the two pairs
+		DryRun bool `json:"dryRun"`
+		// Force switches even if the new pair sees fewer buckets
+		Force bool `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.AccessKeyID == "" || data.SecretAccessKey == "" {
+		httpError(w, r, http.StatusBadRequest, "credentials_required")
+		return
+	}
+	graceHours := appConfig.Credentials.GraceHours
+	if data.GraceHours != nil {
+		if *data.GraceHours < 0 {
+			httpError(w, r, http.StatusBadRequest, "credentials_grace_invalid", *data.GraceHours)
+			return
+		}
+		graceHours = *data.GraceHours
+	}
+
+	current := activeCredentials()
+	if data.AccessKeyID == current.AccessKeyID {
+		httpError(w, r, http.StatusBadRequest, "credentials_unchanged")
+		return
+	}
+	next := AccessKeyPair{AccessKeyID: data.AccessKeyID, SecretAccessKey: data.SecretAccessKey}
+
+	var old, validation KeyValidation
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		old = validateKeyPair(r.Context(), current)
+	}()
+	go func() {
+		defer wg.Done()
+		validation = validateKeyPair(r.Context(), next)
+	}()
+	wg.Wait()
+
+	missing := []string{}
+	for name := range old.buckets {
+		if !validation.buckets[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+
+	result := map[string]interface{}{"current": old, "new": validation, "missingBuckets": missing}
+	if data.DryRun {
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+	if !validation.Valid {
+		httpError(w, r, http.StatusBadRequest, "credentials_invalid", validation.Error)
+		return
+	}
+	if len(missing) > 0 && !data.Force {
+		httpError(w, r, http.StatusConflict, "credentials_missing_buckets", len(missing))
+		return
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(time.Duration(graceHours) * time.Hour)
+	state := CredentialState{
+		RotatedFrom:       appConfig.AWS.AccessKey,
+		Active:            next,
+		Previous:          &current,
+		PreviousExpiresAt: &expiresAt,
+		RotatedAt:         now,
+	}
+	if graceHours == 0 {
+		state.Previous = nil
+		state.PreviousExpiresAt = nil
+	}
+	if err := credentialState.Set(state); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "credentials_state_failed", err)
+		return
+	}
+	swapCredentials(next)
+
+	audit.Record(r, "credentials.rotate", "", "", map[string]interface{}{
+		"from":           current.AccessKeyID,
+		"to":             next.AccessKeyID,
+		"graceHours":     graceHours,
+		"missingBuckets": missing,
+	})
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// rollbackCredentials switches back to the previous pair while it is still
+// within its grace period
+func rollbackCredentials(w http.ResponseWriter, r *http.Request) {
+	state, err := currentCredentialState()
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "credentials_state_failed", err)
+		return
+	}
+	if state.Previous == nil || state.Active.AccessKeyID != activeCredentials().AccessKeyID {
+		httpError(w, r, http.StatusConflict, "credentials_no_previous")
+		return
+	}
+
+	previous := *state.Previous
+	validation := validateKeyPair(r.Context(), previous)
+	if !validation.Valid {
+		httpError(w, r, http.StatusConflict, "credentials_invalid", validation.Error)
+		return
+	}
+
+	// The pair from the config is used again as it is
+	if previous.AccessKeyID == state.RotatedFrom {
+		state = CredentialState{}
+	} else {
+		state = CredentialState{RotatedFrom: state.RotatedFrom, Active: previous, RotatedAt: time.Now().UTC()}
+	}
+	if err := credentialState.Set(state); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "credentials_state_failed", err)
+		return
+	}
+	rolledBack := activeCredentials()
+	swapCredentials(previous)
+
+	audit.Record(r, "credentials.rollback", "", "", map[string]interface{}{
+		"from": rolledBack.AccessKeyID,
+		"to":   previous.AccessKeyID,
+	})
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"accessKeyId": previous.AccessKeyID, "validation": validation})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const debugHeader = "X-S3-Debug"
+
+// Error bodies carry the details of signature mismatches, e.g. the string
+// to sign, larger ones are cut off
+const maxDebugErrorBody = 16 << 10
+
+// Responses are wrapped only up to this size, larger results are dropped
+const maxDebugResult = 1 << 20
+
+var signaturePattern = regexp.MustCompile(`Signature=[0-9a-f]+`)
+
+// Headers that grant access on their own and never appear in a capture
+var secretHeaders = []string{"X-Amz-Security-Token", "X-Amz-Server-Side-Encryption-Customer-Key", "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key"}
+
+// S3Exchange is one attempt of an S3 call as it went over the wire
+type S3Exchange struct {
+	Operation       string              `json:"operation"`
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`
+	RequestHeaders  map[string][]string `json:"requestHeaders"`
+	Status          int                 `json:"status,omitempty"`
+	ResponseHeaders map[string][]string `json:"responseHeaders,omitempty"`
+	ResponseBody    string              `json:"responseBody,omitempty"`
+	Error           string              `json:"error,omitempty"`
+	DurationMs      int64               `json:"durationMs"`
+}
+
+type debugCapture struct {
+	mu        sync.Mutex
+	exchanges []S3Exchange
+}
+
+func (c *debugCapture) add(exchange S3Exchange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.exchanges = append(c.exchanges, exchange)
+}
+
+func (c *debugCapture) list() []S3Exchange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]S3Exchange{}, c.exchanges...)
+}
+
+type debugCaptureKey struct{}
+
+func sanitizeHeaders(header http.Header) map[string][]string {
+	sanitized := make(map[string][]string, len(header))
+	for name, values := range header {
+		sanitized[name] = append([]string(nil), values...)
+	}
+	for _, name := range secretHeaders {
+		if _, ok := sanitized[name]; ok {
+			sanitized[name] = []string{"REDACTED"}
+		}
+	}
+	if values, ok := sanitized["Authorization"]; ok {
+		for i, value := range values {
+			// Credential scope and signed headers are what is needed to
+			// compare with the provider's view, the signature is not
+			values[i] = signaturePattern.ReplaceAllString(value, "Signature=REDACTED")
+		}
+	}
+
+	return sanitized
+}
+
+// captureS3Requests records every attempt of S3 calls made with the context
+// of a request in debug mode. It runs last before the request is sent, so it
+// sees the signed request and the raw response.
+func captureS3Requests(stack *middleware.Stack) error {
+	return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("S3AdminDebugCapture", func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+		capture, _ := ctx.Value(debugCaptureKey{}).(*debugCapture)
+		request, ok := in.Request.(*smithyhttp.Request)
+		if capture == nil || !ok {
+			return next.HandleDeserialize(ctx, in)
+		}
+
+		query := request.URL.Query()
+		if query.Has("X-Amz-Signature") {
+			query.Set("X-Amz-Signature", "REDACTED")
+		}
+		url := *request.URL
+		url.RawQuery = query.Encode()
+
+		exchange := S3Exchange{
+			Operation:      awsmiddleware.GetOperationName(ctx),
+			Method:         request.Method,
+			URL:            url.String(),
+			RequestHeaders: sanitizeHeaders(request.Header),
+		}
+
+		started := time.Now()
+		out, metadata, err := next.HandleDeserialize(ctx, in)
+		exchange.DurationMs = time.Since(started).Milliseconds()
+
+		if response, ok := out.RawResponse.(*smithyhttp.Response); ok && response != nil {
+			exchange.Status = response.StatusCode
+			exchange.ResponseHeaders = sanitizeHeaders(response.Header)
+
+			if response.StatusCode >= 300 && response.Body != nil {
+				body, _ := io.ReadAll(io.LimitReader(response.Body, maxDebugErrorBody))
+				exchange.ResponseBody = string(body)
+				// The SDK still has to parse the error from the body
+				response.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), response.Body), response.Body}
+			}
+		}
+		if err != nil {
+			exchange.Error = err.Error()
+		}
+		capture.add(exchange)
+
+		return out, metadata, err
+	}), middleware.After)
+}
+
+// debugRecorder keeps the handler's response to wrap it with the capture
+type debugRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	size   int
+}
+
+func (d *debugRecorder) Header() http.Header {
+	return d.header
+}
+
+func (d *debugRecorder) WriteHeader(status int) {
+	if d.status == 0 {
+		d.status = status
+	}
+}
+
+func (d *debugRecorder) Write(data []byte) (int, error) {
+	d.WriteHeader(http.StatusOK)
+	d.size += len(data)
+	if d.body.Len() < maxDebugResult {
+		d.body.Write(data[:min(len(data), maxDebugResult-d.body.Len())])
+	}
+	return len(data), nil
+}
+
+type debugResponse struct {
+	Status      int                 `json:"status"`
+	ContentType string              `json:"contentType,omitempty"`
+	Result      json.RawMessage     `json:"result,omitempty"`
+	ResultBytes int                 `json:"resultBytes"`
+	S3Requests  []S3Exchange        `json:"s3Requests"`
+	Headers     map[string][]string `json:"headers,omitempty"`
+}
+
+// s3DebugMiddleware answers requests with ?debug=true or an X-S3-Debug: true
+// header with the S3 calls they made next to the actual result. JSON results
+// are embedded as they are, other bodies like downloads are only counted.
+func s3DebugMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Captures would show anonymous users what is behind a public route
+		if !appConfig.Debug.Enabled || isPublicRoute(r) || (r.URL.Query().Get("debug") != "true" && r.Header.Get(debugHeader) != "true") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		capture := &debugCapture{}
+		recorder := &debugRecorder{header: http.Header{}}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), debugCaptureKey{}, capture)))
+
+		response := debugResponse{
+			Status:      recorder.status,
+			ContentType: recorder.header.Get("Content-Type"),
+			ResultBytes: recorder.size,
+			S3Requests:  capture.list(),
+			Headers:     recorder.header,
+		}
+		if response.Status == 0 {
+			response.Status = http.StatusOK
+		}
+		body := bytes.TrimSpace(recorder.body.Bytes())
+		if recorder.size == recorder.body.Len() && json.Valid(body) {
+			response.Result = body
+		}
+
+		// The wrapped status is in the body, the debug response itself succeeded
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(response)
+	})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// hashMetadataKey is stored as x-amz-meta-sha256 on every object uploaded in dedup mode
+const hashMetadataKey = "sha256"
+
+var dedupIndex *hashIndex
+
+// hashIndex remembers which key holds content with a given hash per bucket.
+// It is only a hint, candidates are verified against the object's metadata.
+type hashIndex struct {
+	mu      sync.Mutex
+	store   *fileStore
+	buckets map[string]map[string]string
+}
+
+func newHashIndex(dataDir string) (*hashIndex, error) {
+	store, err := newFileStore(dataDir, "dedup.json")
+	if err != nil {
+		return nil, err
+	}
+
+	index := &hashIndex{
+		store:   store,
+		buckets: make(map[string]map[string]string),
+	}
+	if err := store.Load(&index.buckets); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+func (i *hashIndex) Lookup(bucketName, hash string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key, ok := i.buckets[bucketName][hash]
+
+	return key, ok
+}
+
+func (i *hashIndex) Record(bucketName, hash, key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.buckets[bucketName] == nil {
+		i.buckets[bucketName] = make(map[string]string)
+	}
+	i.buckets[bucketName][hash] = key
+
+	return i.store.Save(i.buckets)
+}
+
+func hashContent(content io.ReadSeeker) (string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// dedupUpload stores content under key, using a server-side copy instead of
+// uploading the bytes when an object with the same hash already exists in the bucket
+func dedupUpload(ctx context.Context, bucketName, key string, content io.ReadSeeker, encryption objectEncryption) (bool, error) {
+	hash, err := hashContent(content)
+	if err != nil {
+		return false, err
+	}
+
+	if candidate, ok := dedupIndex.Lookup(bucketName, hash); ok {
+		head, err := bucketClient(ctx, bucketName).HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(candidate),
+		})
+		if err == nil && head.Metadata[hashMetadataKey] == hash {
+			if candidate == key {
+				return true, nil
+			}
+
+			input := &s3.CopyObjectInput{
+				Bucket:            aws.String(bucketName),
+				Key:               aws.String(key),
+				CopySource:        aws.String(copySource(bucketName, candidate)),
+				MetadataDirective: types.MetadataDirectiveCopy,
+			}
+			encryption.applyCopy(input)
+
+			_, err = bucketClient(ctx, bucketName).CopyObject(ctx, input)
+			if err != nil {
+				return false, err
+			}
+
+			return true, dedupIndex.Record(bucketName, hash, key)
+		}
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(key),
+		Body:     content,
+		Metadata: map[string]string{hashMetadataKey: hash},
+	}
+	encryption.applyPut(input)
+
+	_, err = bucketClient(ctx, bucketName).PutObject(ctx, input)
+	if err != nil {
+		return false, err
+	}
+
+	return false, dedupIndex.Record(bucketName, hash, key)
+}
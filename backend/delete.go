@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// DeleteObjects accepts at most 1000 keys per request
+const deleteBatchSize = 1000
+
+type DeleteError struct {
+	Key     string `json:"key"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// deleteKeys removes the given keys in batches and collects per-key failures.
+// Only errors affecting a whole batch are returned as error.
+func deleteKeys(ctx context.Context, bucketName string, keys []string) ([]string, []DeleteError, error) {
+	var deleted []string
+	var failed []DeleteError
+
+	for start := 0; start < len(keys); start += deleteBatchSize {
+		end := min(start+deleteBatchSize, len(keys))
+
+		var objectsToDelete []types.ObjectIdentifier
+		for _, key := range keys[start:end] {
+			objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		result, err := bucketClient(ctx, bucketName).DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{Objects: objectsToDelete},
+		})
+		if err != nil {
+			return deleted, failed, err
+		}
+
+		for _, obj := range result.Deleted {
+			deleted = append(deleted, aws.ToString(obj.Key))
+		}
+		for _, e := range result.Errors {
+			failed = append(failed, DeleteError{
+				Key:     aws.ToString(e.Key),
+				Code:    aws.ToString(e.Code),
+				Message: aws.ToString(e.Message),
+			})
+		}
+	}
+
+	return deleted, failed, nil
+}
+
+// deleteFolderInBatches deletes everything below prefix a listing page at a
+// time. Deleted objects are only counted, failures are reported per key.
+func deleteFolderInBatches(ctx context.Context, job *jobHandle, bucketName, prefix string) error {
+	var batch []string
+
+	flush := func() error {
+		job.AddTotal(len(batch))
+		deleted, failed, err := deleteKeys(ctx, bucketName, batch)
+		if err != nil {
+			return err
+		}
+
+		job.Advance(len(deleted))
+		for _, e := range failed {
+			job.Report(e.Key, fmt.Errorf("%s: %s", e.Code, e.Message))
+		}
+		batch = batch[:0]
+
+		return nil
+	}
+
+	err := walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		batch = append(batch, aws.ToString(obj.Key))
+		if len(batch) == deleteBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return flush()
+	}
+
+	return nil
+}
+
+func batchDeleteObjects(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if len(data.Keys) == 0 {
+		httpError(w, r, http.StatusBadRequest, "keys_required")
+		return
+	}
+
+	ctx, done := operations.Begin(r.Context(), bucketName, "delete", "", "")
+	defer done()
+
+	deleted, failed, err := deleteKeys(ctx, bucketName, data.Keys)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "delete_objects_failed", err)
+		return
+	}
+	for _, key := range deleted {
+		publishObjectEvent(ObjectDeleted, bucketName, key)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deleted": deleted,
+		"errors":  failed,
+	})
+}
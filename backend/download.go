@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// applyConditionalHeaders forwards the browser's cache validators to S3.
+// Weak ETags are the ones of compressed downloads, S3 only knows the stored
+// bytes they were made from.
+func applyConditionalHeaders(r *http.Request, input *s3.GetObjectInput) {
+	if value := r.Header.Get("If-None-Match"); value != "" {
+		etags := strings.Split(value, ",")
+		for i, etag := range etags {
+			etags[i] = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+		}
+		input.IfNoneMatch = aws.String(strings.Join(etags, ","))
+	} else if value := r.Header.Get("If-Modified-Since"); value != "" {
+		// If-None-Match takes precedence, as in RFC 9110
+		if since, err := http.ParseTime(value); err == nil {
+			input.IfModifiedSince = aws.Time(since)
+		}
+	}
+}
+
+// writeNotModified answers with 304 when S3 found the client's copy current
+func writeNotModified(w http.ResponseWriter, r *http.Request, err error) bool {
+	var responseErr *smithyhttp.ResponseError
+	if !errors.As(err, &responseErr) || responseErr.HTTPStatusCode() != http.StatusNotModified {
+		return false
+	}
+
+	if response := responseErr.Response; response != nil && response.Response != nil {
+		etag := response.Header.Get("ETag")
+		if etag != "" && strings.Contains(r.Header.Get("If-None-Match"), "W/") {
+			etag = "W/" + etag
+		}
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		if lastModified := response.Header.Get("Last-Modified"); lastModified != "" {
+			w.Header().Set("Last-Modified", lastModified)
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// streamObject proxies an object to the client, forwarding a Range header to
+// S3 and answering with 206 Partial Content when only a part was requested.
+// Conditional requests are answered with 304 without transferring the object.
+func streamObject(w http.ResponseWriter, r *http.Request, input *s3.GetObjectInput, disposition string) {
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		input.Range = aws.String(rangeHeader)
+	}
+	applyConditionalHeaders(r, input)
+	if input.RequestPayer == "" {
+		input.RequestPayer = requestPayer(r.Context(), aws.ToString(input.Bucket))
+	}
+
+	result, err := transferClient(r.Context(), aws.ToString(input.Bucket)).GetObject(r.Context(), input)
+	if writeNotModified(w, r, err) {
+		return
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		w.Header().Set("Content-Range", "bytes */*")
+		httpError(w, r, http.StatusRequestedRangeNotSatisfiable, "download_failed", err)
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "download_failed", err)
+		return
+	}
+	defer result.Body.Close()
+
+	header := w.Header()
+	bucketName, key := aws.ToString(input.Bucket), aws.ToString(input.Key)
+	if compressDownload(r, bucketName, key, aws.ToString(result.ContentType), aws.ToString(result.ContentEncoding)) {
+		if disposition != "" {
+			header.Set("Content-Disposition", disposition)
+		}
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/octet-stream")
+		}
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		// The compressed bytes are not the stored ones
+		if result.ETag != nil {
+			header.Set("ETag", "W/"+*result.ETag)
+		}
+		if result.LastModified != nil {
+			header.Set("Last-Modified", result.LastModified.UTC().Format(http.TimeFormat))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		copyGzipped(w, result.Body)
+		return
+	}
+
+	header.Set("Accept-Ranges", "bytes")
+	if disposition != "" {
+		header.Set("Content-Disposition", disposition)
+	}
+	// Callers may have chosen a more specific type already
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	if result.ContentLength != nil {
+		header.Set("Content-Length", strconv.FormatInt(*result.ContentLength, 10))
+	}
+	if result.ETag != nil {
+		header.Set("ETag", *result.ETag)
+	}
+	if result.LastModified != nil {
+		header.Set("Last-Modified", result.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	status := http.StatusOK
+	if result.ContentRange != nil {
+		header.Set("Content-Range", *result.ContentRange)
+		status = http.StatusPartialContent
+	}
+
+	w.WriteHeader(status)
+	io.Copy(w, result.Body)
+}
+
+func attachmentDisposition(filename string) string {
+	return fmt.Sprintf("attachment; filename=%q", filename)
+}
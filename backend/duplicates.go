@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// Reports list the groups wasting the most space first, up to this many
+const maxDuplicateGroups = 1000
+
+type DuplicateGroup struct {
+	ETag        string   `json:"etag"`
+	Size        int64    `json:"size"`
+	Keys        []string `json:"keys"`
+	WastedBytes int64    `json:"wastedBytes"`
+}
+
+type DuplicateReport struct {
+	BucketName       string           `json:"bucketName"`
+	Prefix           string           `json:"prefix"`
+	Groups           []DuplicateGroup `json:"groups"`
+	TotalGroups      int              `json:"totalGroups"`
+	DuplicateObjects int              `json:"duplicateObjects"`
+	WastedBytes      int64            `json:"wastedBytes"`
+}
+
+type duplicateSignature struct {
+	etag string
+	size int64
+}
+
+// findDuplicates groups objects by ETag and size. Identical content uploaded
+// in parts of different sizes gets different ETags, so this finds likely
+// duplicates rather than all of them. Empty objects are not reported.
+func findDuplicates(ctx context.Context, job *jobHandle, bucketName, prefix string) (*DuplicateReport, error) {
+	first := map[duplicateSignature]string{}
+	groups := map[duplicateSignature]*DuplicateGroup{}
+
+	err := walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		job.Advance(1)
+
+		size := aws.ToInt64(obj.Size)
+		if size == 0 {
+			return nil
+		}
+
+		key := aws.ToString(obj.Key)
+		signature := duplicateSignature{etag: aws.ToString(obj.ETag), size: size}
+		if group, ok := groups[signature]; ok {
+			group.Keys = append(group.Keys, key)
+			group.WastedBytes += size
+			return nil
+		}
+		if firstKey, ok := first[signature]; ok {
+			groups[signature] = &DuplicateGroup{ETag: signature.etag, Size: size, Keys: []string{firstKey, key}, WastedBytes: size}
+			delete(first, signature)
+			return nil
+		}
+		first[signature] = key
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DuplicateReport{
+		BucketName:  bucketName,
+		Prefix:      prefix,
+		Groups:      make([]DuplicateGroup, 0, len(groups)),
+		TotalGroups: len(groups),
+	}
+	for _, group := range groups {
+		report.Groups = append(report.Groups, *group)
+		report.DuplicateObjects += len(group.Keys) - 1
+		report.WastedBytes += group.WastedBytes
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i].WastedBytes > report.Groups[j].WastedBytes
+	})
+	if len(report.Groups) > maxDuplicateGroups {
+		report.Groups = report.Groups[:maxDuplicateGroups]
+	}
+
+	return report, nil
+}
+
+func createDuplicateReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Prefix string `json:"prefix"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	job, err := startBucketJob(bucketName, "duplicates", data.Prefix, func(ctx context.Context, job *jobHandle) error {
+		report, err := findDuplicates(ctx, job, bucketName, data.Prefix)
+		if err != nil {
+			return err
+		}
+		job.SetResult(report)
+		return nil
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	writeJobAccepted(w, job)
+}
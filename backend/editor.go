@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+type TextContent struct {
+	Content     string `json:"content"`
+	ContentType string `json:"contentType"`
+	ETag        string `json:"etag"`
+}
+
+// getObjectContent returns a small object as text for editing in the browser
+func getObjectContent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+	maxSize := int64(appConfig.Editor.MaxSizeKB) << 10
+
+	ctx := r.Context()
+	result, err := bucketClient(ctx, bucketName).GetObject(ctx, &s3.GetObjectInput{
+		Bucket:       aws.String(bucketName),
+		Key:          aws.String(objectKey),
+		RequestPayer: requestPayer(ctx, bucketName),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		httpError(w, r, http.StatusNotFound, "object_not_found", err)
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "download_failed", err)
+		return
+	}
+	defer result.Body.Close()
+
+	if aws.ToInt64(result.ContentLength) > maxSize {
+		httpError(w, r, http.StatusRequestEntityTooLarge, "edit_too_large", appConfig.Editor.MaxSizeKB)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(result.Body, maxSize))
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "download_failed", err)
+		return
+	}
+
+	// Compressed objects are valid UTF-8 only by accident
+	if aws.ToString(result.ContentEncoding) != "" || !utf8.Valid(data) {
+		httpError(w, r, http.StatusUnsupportedMediaType, "edit_not_text")
+		return
+	}
+
+	// A byte order mark would turn up as an invisible character in the editor
+	json.NewEncoder(w).Encode(TextContent{
+		Content:     strings.TrimPrefix(string(data), "\ufeff"),
+		ContentType: aws.ToString(result.ContentType),
+		ETag:        aws.ToString(result.ETag),
+	})
+}
+
+// putObjectContent saves edited text, keeping the content type, metadata and
+// storage settings of the object. Sending the etag from loading it makes the
+// save fail instead of overwriting a change made in the meantime.
+func putObjectContent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	var data struct {
+		Content string `json:"content"`
+		ETag    string `json:"etag"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if len(data.Content) > appConfig.Editor.MaxSizeKB<<10 {
+		httpError(w, r, http.StatusRequestEntityTooLarge, "edit_too_large", appConfig.Editor.MaxSizeKB)
+		return
+	}
+
+	ctx := r.Context()
+	client := bucketClient(ctx, bucketName)
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		httpError(w, r, http.StatusNotFound, "object_not_found", err)
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "upload_failed", err)
+		return
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:             aws.String(bucketName),
+		Key:                aws.String(objectKey),
+		Body:               strings.NewReader(data.Content),
+		ContentType:        head.ContentType,
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentLanguage:    head.ContentLanguage,
+		Metadata:           head.Metadata,
+	}
+	if head.StorageClass != "" {
+		input.StorageClass = head.StorageClass
+	}
+	if head.ServerSideEncryption == types.ServerSideEncryptionAwsKms {
+		input.ServerSideEncryption = head.ServerSideEncryption
+		input.SSEKMSKeyId = head.SSEKMSKeyId
+	}
+	if data.ETag != "" {
+		input.IfMatch = aws.String(data.ETag)
+	}
+
+	result, err := client.PutObject(ctx, input)
+	if isPreconditionFailed(err) {
+		httpError(w, r, http.StatusConflict, "edit_conflict")
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "upload_failed", err)
+		return
+	}
+
+	audit.Record(r, "object.edit", bucketName, objectKey, map[string]interface{}{"size": len(data.Content)})
+	publishObjectEvent(ObjectUpdated, bucketName, objectKey)
+
+	json.NewEncoder(w).Encode(TextContent{
+		Content:     data.Content,
+		ContentType: aws.ToString(head.ContentType),
+		ETag:        aws.ToString(result.ETag),
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// objectEncryption holds the server-side encryption requested for a write.
+// The zero value leaves encryption to the bucket default.
+type objectEncryption struct {
+	Algorithm types.ServerSideEncryption
+	KMSKeyID  string
+}
+
+func parseEncryption(algorithm, kmsKeyID string) (objectEncryption, *apiError) {
+	if algorithm == "" {
+		if kmsKeyID != "" {
+			return objectEncryption{}, newAPIError("encryption_kms_key_without_kms")
+		}
+		return objectEncryption{}, nil
+	}
+
+	if !isKnownValue(algorithm, types.ServerSideEncryption("").Values()) {
+		return objectEncryption{}, newAPIError("encryption_algorithm_invalid", algorithm)
+	}
+
+	encryption := objectEncryption{Algorithm: types.ServerSideEncryption(algorithm)}
+	if kmsKeyID != "" {
+		if encryption.Algorithm == types.ServerSideEncryptionAes256 {
+			return objectEncryption{}, newAPIError("encryption_kms_key_without_kms")
+		}
+		encryption.KMSKeyID = kmsKeyID
+	}
+
+	return encryption, nil
+}
+
+func (e objectEncryption) applyPut(input *s3.PutObjectInput) {
+	input.ServerSideEncryption = e.Algorithm
+	if e.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(e.KMSKeyID)
+	}
+}
+
+func (e objectEncryption) applyCopy(input *s3.CopyObjectInput) {
+	input.ServerSideEncryption = e.Algorithm
+	if e.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(e.KMSKeyID)
+	}
+}
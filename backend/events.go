@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Comments keep idle connections open through proxies that close quiet ones
+const eventKeepAliveInterval = 30 * time.Second
+
+// Clients that fall this far behind lose events instead of holding up others
+const eventBufferSize = 64
+
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// eventHub fans events out to all connected /api/events clients
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]bool
+}
+
+var events = &eventHub{subscribers: make(map[chan Event]bool)}
+
+func (h *eventHub) Subscribe() chan Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Event, eventBufferSize)
+	h.subscribers[ch] = true
+
+	return ch
+}
+
+func (h *eventHub) Unsubscribe(ch chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subscribers, ch)
+}
+
+// Publish never blocks, a client that does not keep up misses the event
+func (h *eventHub) Publish(eventType string, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- Event{Type: eventType, Data: data}:
+		default:
+		}
+	}
+}
+
+// streamEvents sends job progress, finished jobs and finished bucket counts
+// as server-sent events. types limits the stream to event types starting
+// with one of the given comma-separated prefixes, e.g. "job,stats".
+func streamEvents(w http.ResponseWriter, r *http.Request) {
+	var types []string
+	if value := r.URL.Query().Get("types"); value != "" {
+		types = strings.Split(value, ",")
+	}
+	wanted := func(eventType string) bool {
+		if len(types) == 0 {
+			return true
+		}
+		for _, prefix := range types {
+			if strings.HasPrefix(eventType, strings.TrimSpace(prefix)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ch := events.Subscribe()
+	defer events.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps reverse proxies like nginx from buffering the events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				return
+			}
+		case event := <-ch:
+			if !wanted(event.Type) {
+				continue
+			}
+			if err := writeEvent(w, event.Type, event.Data); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	providerAWS     = "aws"
+	providerMinIO   = "minio"
+	providerGeneric = "generic"
+)
+
+type Features struct {
+	Provider   string `json:"provider"`
+	Region     string `json:"region"`
+	Versioning bool   `json:"versioning"`
+	MinioAdmin bool   `json:"minioAdmin"`
+	Jobs       bool   `json:"jobs"`
+	Trash      bool   `json:"trash"`
+	Shares     bool   `json:"shares"`
+}
+
+type providerCapabilities struct {
+	once       sync.Once
+	provider   string
+	versioning bool
+}
+
+var capabilities providerCapabilities
+
+const capabilityDetectionTimeout = 10 * time.Second
+
+// detect runs once, so the first request only lends its values to the
+// detection and does not cancel it when the client goes away
+func (c *providerCapabilities) detect(ctx context.Context, endpoint string) {
+	c.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), capabilityDetectionTimeout)
+		defer cancel()
+
+		c.provider = detectProvider(endpoint)
+		c.versioning = detectVersioning(ctx, c.provider)
+	})
+}
+
+func detectProvider(endpoint string) string {
+	if endpoint == "" {
+		return providerAWS
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return providerGeneric
+	}
+	defer resp.Body.Close()
+
+	if strings.Contains(strings.ToLower(resp.Header.Get("Server")), "minio") {
+		return providerMinIO
+	}
+	if strings.Contains(resp.Header.Get("Server"), "AmazonS3") {
+		return providerAWS
+	}
+
+	return providerGeneric
+}
+
+func detectVersioning(ctx context.Context, provider string) bool {
+	if provider == providerAWS || provider == providerMinIO {
+		return true
+	}
+
+	// For other providers, ask for the versioning state of any bucket and
+	// see whether the call is understood at all
+	result, err := s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil || len(result.Buckets) == 0 {
+		return false
+	}
+
+	_, err = s3Client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
+		Bucket: result.Buckets[0].Name,
+	})
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented" {
+		return false
+	}
+
+	return err == nil
+}
+
+func getFeatures(w http.ResponseWriter, r *http.Request) {
+	capabilities.detect(r.Context(), appConfig.AWS.Endpoint)
+
+	versioning := appConfig.Features.Versioning && capabilities.versioning
+	// Deleted objects can only be restored from their older versions
+	trash := appConfig.Features.Trash && versioning
+
+	json.NewEncoder(w).Encode(Features{
+		Provider:   capabilities.provider,
+		Region:     awsRegion,
+		Versioning: versioning,
+		MinioAdmin: appConfig.Features.MinioAdmin && capabilities.provider == providerMinIO,
+		Jobs:       appConfig.Features.Jobs,
+		Trash:      trash,
+		Shares:     true,
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+var errFetchAddressBlocked = errors.New("address is in a private network")
+
+// fetchClient dials every address itself so redirects to internal services
+// are caught as well, unless private networks are allowed in the config
+var fetchClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: checkFetchAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	},
+}
+
+func checkFetchAddress(network, address string, _ syscall.RawConn) error {
+	if appConfig.Uploads.FetchPrivateNetworks {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return errFetchAddressBlocked
+	}
+
+	return nil
+}
+
+// countingReader reports every read to the job so the progress shows the
+// bytes fetched so far
+type countingReader struct {
+	io.Reader
+	job *jobHandle
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.job.AddBytes(int64(n))
+
+	return n, err
+}
+
+// storeFetched uploads the body in one request when it fits into a single
+// part and streams it as multipart upload otherwise. The part size follows
+// the content length, or grows while streaming when the length is unknown.
+// Unless overwrite is set, an object written to the key in the meantime
+// makes the upload fail.
+func storeFetched(ctx context.Context, bucketName, key string, resp *http.Response, body io.Reader, overwrite bool) error {
+	var ifNoneMatch *string
+	if !overwrite {
+		ifNoneMatch = aws.String("*")
+	}
+	contentType := resp.Header.Get("Content-Type")
+
+	var head bytes.Buffer
+	if _, err := io.CopyN(&head, body, minPartSize); err == io.EOF {
+		input := &s3.PutObjectInput{
+			Bucket:      aws.String(bucketName),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(head.Bytes()),
+			IfNoneMatch: ifNoneMatch,
+		}
+		if contentType != "" {
+			input.ContentType = aws.String(contentType)
+		}
+		_, err := transferClient(ctx, bucketName).PutObject(ctx, input)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	input := &s3.CreateMultipartUploadInput{}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	writer, err := newMultipartWriter(ctx, bucketName, key, input)
+	if err != nil {
+		return err
+	}
+	writer.ifNoneMatch = ifNoneMatch
+	if resp.ContentLength > 0 {
+		writer.partSize = int(max(minPartSize, (resp.ContentLength+maxUploadParts-1)/maxUploadParts))
+	} else {
+		writer.growParts = true
+	}
+	if _, err := writer.ReadFrom(io.MultiReader(&head, body)); err != nil {
+		writer.Abort()
+		return err
+	}
+	if err := writer.Complete(); err != nil {
+		writer.Abort()
+		return err
+	}
+
+	return nil
+}
+
+// fetchObject starts a job downloading a URL straight into the bucket. The
+// key defaults to the last segment of the URL path.
+func fetchObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		URL      string `json:"url"`
+		Key      string `json:"key"`
+		Conflict string `json:"conflict"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	source, err := url.Parse(data.URL)
+	if err != nil || (source.Scheme != "http" && source.Scheme != "https") || source.Host == "" {
+		httpError(w, r, http.StatusBadRequest, "fetch_url_invalid", data.URL)
+		return
+	}
+
+	key := strings.TrimPrefix(data.Key, "/")
+	if key == "" {
+		key = path.Base(source.Path)
+	}
+	if key == "" || key == "." || key == "/" || strings.HasSuffix(key, "/") {
+		httpError(w, r, http.StatusBadRequest, "fetch_key_required")
+		return
+	}
+
+	if data.Conflict == "" {
+		data.Conflict = conflictOverwrite
+	}
+	if !isKnownValue(data.Conflict, conflictPolicies) {
+		httpError(w, r, http.StatusBadRequest, "conflict_policy_invalid", data.Conflict)
+		return
+	}
+
+	job, err := startBucketJob(bucketName, "fetch", key, func(ctx context.Context, job *jobHandle) error {
+		target, err := resolveUploadKey(ctx, bucketName, key, data.Conflict)
+		if errors.Is(err, errObjectExists) {
+			return newAPIError("object_exists", key)
+		}
+		if err != nil {
+			return err
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := fetchClient.Do(request)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return newAPIError("fetch_status_failed", resp.Status)
+		}
+		job.SetTotal(1)
+
+		err = storeFetched(ctx, bucketName, target, resp, countingReader{resp.Body, job}, data.Conflict == conflictOverwrite)
+		if isPreconditionFailed(err) {
+			err = newAPIError("object_exists", target)
+		}
+		job.Report(target, err)
+		if err != nil {
+			return err
+		}
+
+		publishObjectEvent(ObjectCreated, bucketName, target)
+		job.SetResult(map[string]interface{}{"key": target})
+
+		return nil
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	audit.Record(r, "object.fetch", bucketName, key, map[string]interface{}{"jobId": job.ID, "url": source.Redacted()})
+
+	writeJobAccepted(w, job)
+}
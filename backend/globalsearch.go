@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+// Buckets searched at the same time
+const globalSearchConcurrency = 8
+
+type SearchMatch struct {
+	BucketName   string     `json:"bucketName"`
+	Key          string     `json:"key"`
+	Size         int64      `json:"size"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+}
+
+type searchError struct {
+	BucketName string `json:"bucketName"`
+	Error      string `json:"error"`
+}
+
+// searchBucket sends every matching object of one bucket to matches until
+// the bucket is done or ctx is cancelled
+func searchBucket(ctx context.Context, client *s3.Client, bucketName, query string, filter objectFilter, matches chan<- SearchMatch) error {
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if !strings.Contains(strings.ToLower(key), query) || !filter.Matches(obj) {
+				continue
+			}
+
+			select {
+			case matches <- SearchMatch{BucketName: bucketName, Key: key, Size: aws.ToInt64(obj.Size), LastModified: obj.LastModified}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	return nil
+}
+
+// searchRegion looks for objects in every bucket of a region at once and
+// streams the matches as server-sent events, for users who do not know which
+// bucket holds a file
+func searchRegion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	region := vars["name"]
+	query := r.URL.Query()
+
+	if !slices.Contains(listedRegions(), region) {
+		httpError(w, r, http.StatusNotFound, "region_not_found", region)
+		return
+	}
+
+	filter, apiErr := parseObjectFilter(query)
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+	text := strings.ToLower(query.Get("q"))
+	if text == "" && len(filter.patterns) == 0 && len(filter.extensions) == 0 {
+		httpError(w, r, http.StatusBadRequest, "search_query_required")
+		return
+	}
+
+	limit, apiErr := parseSearchLimit(query.Get("limit"))
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	buckets, err := listRegionBuckets(ctx, region)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "list_buckets_failed", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	matches := make(chan SearchMatch)
+	failures := make(chan searchError)
+	client := regions.Client(region)
+
+	go func() {
+		slots := make(chan struct{}, globalSearchConcurrency)
+		var wg sync.WaitGroup
+		for _, bucket := range buckets {
+			wg.Add(1)
+			go func(bucketName string) {
+				defer wg.Done()
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				defer func() { <-slots }()
+
+				if err := searchBucket(ctx, client, bucketName, text, filter, matches); err != nil && ctx.Err() == nil {
+					select {
+					case failures <- searchError{BucketName: bucketName, Error: err.Error()}:
+					case <-ctx.Done():
+					}
+				}
+			}(bucket.Name)
+		}
+		wg.Wait()
+		close(matches)
+	}()
+
+	found := 0
+	for {
+		select {
+		case failure := <-failures:
+			if writeEvent(w, "error", failure) != nil {
+				return
+			}
+		case match, ok := <-matches:
+			if !ok {
+				writeEvent(w, "done", map[string]interface{}{"matches": found, "truncated": false, "buckets": len(buckets)})
+				return
+			}
+			if writeEvent(w, "match", match) != nil {
+				return
+			}
+			found++
+			if found == limit {
+				writeEvent(w, "done", map[string]interface{}{"matches": found, "truncated": true, "buckets": len(buckets)})
+				return
+			}
+		}
+	}
+}
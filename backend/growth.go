@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+var growthHistory *documentStore[[]SizeSnapshot]
+
+// SizeSnapshot is the size of a bucket, or of a tracked prefix in it, at one
+// point in time
+type SizeSnapshot struct {
+	Time       time.Time `json:"time"`
+	BucketName string    `json:"bucketName"`
+	Prefix     string    `json:"prefix,omitempty"`
+	Objects    int64     `json:"objects"`
+	Bytes      int64     `json:"bytes"`
+}
+
+type GrowthPoint struct {
+	Time    time.Time `json:"time"`
+	Objects int64     `json:"objects"`
+	Bytes   int64     `json:"bytes"`
+}
+
+type GrowthSeries struct {
+	BucketName string        `json:"bucketName"`
+	Prefix     string        `json:"prefix,omitempty"`
+	Points     []GrowthPoint `json:"points"`
+}
+
+func newGrowthStore(dataDir string) (*documentStore[[]SizeSnapshot], error) {
+	return newDocumentStore(dataDir, "growth.json", []SizeSnapshot{})
+}
+
+func normalizeGrowthPrefix(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+func isTrackedPrefix(bucketName, prefix string) bool {
+	for _, tracked := range appConfig.Growth.Prefixes {
+		if tracked.Bucket == bucketName && normalizeGrowthPrefix(tracked.Prefix) == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+func snapshotPrefix(ctx context.Context, bucketName, prefix string) (SizeSnapshot, error) {
+	snapshot := SizeSnapshot{BucketName: bucketName, Prefix: prefix}
+
+	err := walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		snapshot.Objects++
+		snapshot.Bytes += aws.ToInt64(obj.Size)
+		return nil
+	})
+
+	return snapshot, err
+}
+
+// takeSizeSnapshots counts every bucket and the tracked prefixes. Buckets are
+// counted through the stats scans, which also refreshes the bucket stats.
+func takeSizeSnapshots(ctx context.Context) ([]SizeSnapshot, error) {
+	result, err := s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, err
+	}
+	if appConfig.AWS.AutoDiscover {
+		annotateBucketRegions(ctx, result.Buckets)
+	}
+
+	now := time.Now().UTC()
+	var snapshots []SizeSnapshot
+	for _, bucket := range result.Buckets {
+		name := aws.ToString(bucket.Name)
+
+		stats, err := statsScans.Scan(ctx, name, statsPriorityBackground, "growth")
+		if err != nil {
+			log.Printf("growth: failed to scan bucket %s: %v", name, err)
+			continue
+		}
+
+		snapshot := SizeSnapshot{Time: now, BucketName: name}
+		for storageClass, objects := range stats.Objects {
+			snapshot.Objects += objects
+			snapshot.Bytes += stats.Bytes[storageClass]
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	for _, tracked := range appConfig.Growth.Prefixes {
+		snapshot, err := snapshotPrefix(ctx, tracked.Bucket, normalizeGrowthPrefix(tracked.Prefix))
+		if err != nil {
+			log.Printf("growth: failed to scan %s/%s: %v", tracked.Bucket, tracked.Prefix, err)
+			continue
+		}
+		snapshot.Time = now
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// recordSizeSnapshots appends snapshots and drops those older than the retention
+func recordSizeSnapshots(snapshots []SizeSnapshot, now time.Time) error {
+	cutoff := now.AddDate(0, 0, -appConfig.Growth.RetentionDays)
+
+	history := growthHistory.Get()
+	kept := make([]SizeSnapshot, 0, len(history)+len(snapshots))
+	for _, existing := range history {
+		if existing.Time.After(cutoff) {
+			kept = append(kept, existing)
+		}
+	}
+
+	return growthHistory.Set(append(kept, snapshots...))
+}
+
+// trackGrowth takes snapshots until the process exits
+func trackGrowth() {
+	ticker := time.NewTicker(time.Duration(appConfig.Growth.IntervalHours) * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		snapshots, err := takeSizeSnapshots(context.Background())
+		if err != nil {
+			log.Printf("growth: failed to list buckets: %v", err)
+		} else if err := recordSizeSnapshots(snapshots, time.Now()); err != nil {
+			log.Printf("failed to save size snapshots: %v", err)
+		}
+
+		<-ticker.C
+	}
+}
+
+// getBucketGrowth returns the recorded sizes of a bucket, or of a prefix
+// configured in growth.prefixes, oldest first
+func getBucketGrowth(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	query := r.URL.Query()
+
+	prefix := normalizeGrowthPrefix(query.Get("prefix"))
+	if prefix != "" && !isTrackedPrefix(bucketName, prefix) {
+		httpError(w, r, http.StatusNotFound, "growth_prefix_not_tracked", prefix)
+		return
+	}
+
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		parsed, ok := parseFilterTime(value)
+		if !ok {
+			httpError(w, r, http.StatusBadRequest, "filter_time_invalid", "since", value)
+			return
+		}
+		since = parsed
+	}
+
+	series := GrowthSeries{BucketName: bucketName, Prefix: prefix, Points: []GrowthPoint{}}
+	for _, snapshot := range growthHistory.Get() {
+		if snapshot.BucketName == bucketName && snapshot.Prefix == prefix && snapshot.Time.After(since) {
+			series.Points = append(series.Points, GrowthPoint{Time: snapshot.Time, Objects: snapshot.Objects, Bytes: snapshot.Bytes})
+		}
+	}
+
+	json.NewEncoder(w).Encode(series)
+}
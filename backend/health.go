@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+var healthHistory *documentStore[[]HealthSample]
+
+// HealthSample is the outcome of one probe of the S3 endpoint
+type HealthSample struct {
+	Time      time.Time `json:"time"`
+	Available bool      `json:"available"`
+	LatencyMs int64     `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type RegionHealth struct {
+	Name     string         `json:"name"`
+	Endpoint string         `json:"endpoint,omitempty"`
+	Uptime   float64        `json:"uptime"`
+	Latest   *HealthSample  `json:"latest,omitempty"`
+	Samples  []HealthSample `json:"samples,omitempty"`
+}
+
+func newHealthStore(dataDir string) (*documentStore[[]HealthSample], error) {
+	return newDocumentStore(dataDir, "health.json", []HealthSample{})
+}
+
+func probeEndpoint() HealthSample {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(max(appConfig.Health.ProbeTimeoutSeconds, 1))*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
+
+	sample := HealthSample{
+		Time:      start.UTC(),
+		Available: err == nil,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		sample.Error = err.Error()
+	}
+
+	return sample
+}
+
+// recordHealthSample appends a sample and drops those older than the retention
+func recordHealthSample(sample HealthSample) error {
+	cutoff := sample.Time.Add(-time.Duration(appConfig.Health.RetentionHours) * time.Hour)
+
+	history := healthHistory.Get()
+	kept := make([]HealthSample, 0, len(history)+1)
+	for _, existing := range history {
+		if existing.Time.After(cutoff) {
+			kept = append(kept, existing)
+		}
+	}
+
+	return healthHistory.Set(append(kept, sample))
+}
+
+// monitorHealth probes the endpoint until the process exits
+func monitorHealth() {
+	ticker := time.NewTicker(time.Duration(appConfig.Health.ProbeIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		sample := probeEndpoint()
+		if !sample.Available {
+			log.Printf("health probe of %s failed: %s", awsRegion, sample.Error)
+		}
+		if err := recordHealthSample(sample); err != nil {
+			log.Printf("failed to save health sample: %v", err)
+		}
+
+		<-ticker.C
+	}
+}
+
+func regionHealth(samples []HealthSample) RegionHealth {
+	health := RegionHealth{Name: awsRegion, Endpoint: appConfig.AWS.Endpoint}
+	if len(samples) == 0 {
+		return health
+	}
+
+	available := 0
+	for _, sample := range samples {
+		if sample.Available {
+			available++
+		}
+	}
+	health.Uptime = float64(available) / float64(len(samples)) * 100
+	health.Latest = &samples[len(samples)-1]
+
+	return health
+}
+
+// listRegions gives an overview for the dashboard. Only the configured region
+// is probed, further and discovered regions are listed without health data.
+func listRegions(w http.ResponseWriter, r *http.Request) {
+	list := []RegionHealth{regionHealth(healthHistory.Get())}
+	for _, region := range listedRegions()[1:] {
+		list = append(list, RegionHealth{Name: region})
+	}
+
+	json.NewEncoder(w).Encode(list)
+}
+
+func getRegionHealthHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if vars["name"] != awsRegion {
+		httpError(w, r, http.StatusNotFound, "region_not_found", vars["name"])
+		return
+	}
+
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "since_invalid", value)
+			return
+		}
+		since = parsed
+	}
+
+	samples := []HealthSample{}
+	for _, sample := range healthHistory.Get() {
+		if sample.Time.After(since) {
+			samples = append(samples, sample)
+		}
+	}
+
+	health := regionHealth(samples)
+	health.Samples = samples
+
+	json.NewEncoder(w).Encode(health)
+}
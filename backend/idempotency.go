@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	idempotencyTTL    = 24 * time.Hour
+	maxIdempotencyKey = 255
+	// Bodies up to this size are hashed in memory, larger ones are spooled
+	// to a temporary file so the handler can still read them
+	idempotencyMemoryBody = 1 << 20
+)
+
+type idempotentResponse struct {
+	method   string
+	path     string
+	bodyHash string
+	done     chan struct{}
+	status   int
+	header   http.Header
+	body     []byte
+	stored   bool
+	expires  time.Time
+}
+
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotentResponse
+}
+
+var idempotency = &idempotencyCache{entries: make(map[string]*idempotentResponse)}
+
+// responseRecorder passes a response through to the client while keeping a
+// copy so it can be replayed for a repeated request.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// hashBody reads the request body to hash it and puts a copy in its place.
+// The returned function removes a spooled copy once the handler is done.
+func hashBody(r *http.Request) (string, func(), error) {
+	hash := sha256.New()
+	body := io.TeeReader(r.Body, hash)
+
+	var buffer bytes.Buffer
+	n, err := io.CopyN(&buffer, body, idempotencyMemoryBody+1)
+	if err != nil && err != io.EOF {
+		return "", nil, err
+	}
+	if n <= idempotencyMemoryBody {
+		r.Body = io.NopCloser(&buffer)
+		return hex.EncodeToString(hash.Sum(nil)), func() {}, nil
+	}
+
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	r.Body = io.NopCloser(file)
+
+	return hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+}
+
+// claim returns the entry for key. If the key is new, the returned entry is
+// owned by the caller, who must finish it.
+func (c *idempotencyCache) claim(key, method, path, bodyHash string) (*idempotentResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if entry.stored && now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	if entry, ok := c.entries[key]; ok {
+		return entry, false
+	}
+
+	entry := &idempotentResponse{method: method, path: path, bodyHash: bodyHash, done: make(chan struct{})}
+	c.entries[key] = entry
+	return entry, true
+}
+
+func (c *idempotencyCache) finish(key string, entry *idempotentResponse, rec *responseRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Server errors are not remembered so the client can retry them.
+	if rec.status == 0 || rec.status >= 500 {
+		delete(c.entries, key)
+	} else {
+		entry.status = rec.status
+		entry.header = rec.Header().Clone()
+		entry.body = rec.body.Bytes()
+		entry.stored = true
+		entry.expires = time.Now().Add(idempotencyTTL)
+	}
+	close(entry.done)
+}
+
+// idempotent makes a mutating handler safe to retry. Requests carrying an
+// Idempotency-Key header are executed once; repeats with the same key get
+// the original response replayed instead of running the handler again.
+// Reusing a key for a different request is rejected.
+func idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			httpError(w, r, http.StatusBadRequest, "idempotency_key_invalid", maxIdempotencyKey)
+			return
+		}
+
+		bodyHash, cleanup, err := hashBody(r)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+			return
+		}
+		defer cleanup()
+
+		entry, owner := idempotency.claim(key, r.Method, r.URL.Path, bodyHash)
+		if !owner {
+			if entry.method != r.Method || entry.path != r.URL.Path {
+				httpError(w, r, http.StatusUnprocessableEntity, "idempotency_key_reused")
+				return
+			}
+			if entry.bodyHash != bodyHash {
+				httpError(w, r, http.StatusUnprocessableEntity, "idempotency_key_mismatch")
+				return
+			}
+
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				return
+			}
+			if !entry.stored {
+				// The original request failed and was forgotten, the
+				// client should retry it.
+				httpError(w, r, http.StatusConflict, "idempotency_key_in_progress")
+				return
+			}
+
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		defer idempotency.finish(key, entry, rec)
+		next(rec, r)
+	}
+}
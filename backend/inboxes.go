@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+var inboxes *recordStore[Inbox]
+
+var errInboxFull = errors.New("inbox is full")
+
+type Inbox struct {
+	ID          string    `json:"id"`
+	Token       string    `json:"token"`
+	BucketName  string    `json:"bucketName"`
+	Prefix      string    `json:"prefix"`
+	Note        string    `json:"note,omitempty"`
+	CreatedBy   string    `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	MaxFileSize int64     `json:"maxFileSize"`
+	MaxFiles    int       `json:"maxFiles"`
+	UploadCount int       `json:"uploadCount"`
+}
+
+func (i *Inbox) Expired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+// PublicInbox is what an external uploader gets to see
+type PublicInbox struct {
+	Note           string    `json:"note,omitempty"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+	MaxFileSize    int64     `json:"maxFileSize"`
+	RemainingFiles int       `json:"remainingFiles"`
+}
+
+func newInboxStore(dataDir string) (*recordStore[Inbox], error) {
+	return newRecordStore(dataDir, "inboxes.json", func(inbox *Inbox) string {
+		return inbox.ID
+	})
+}
+
+// scanUpload pipes the upload through the configured scan command, a
+// non-zero exit status rejects the file
+func scanUpload(ctx context.Context, file multipart.File) error {
+	command := appConfig.Inboxes.ScanCommand
+	if len(command) == 0 {
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = file
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+		return seekErr
+	}
+	if err != nil {
+		return errors.New(strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
+
+func newInbox(bucketName, prefix, note, createdBy string, expires time.Duration, maxFileSize int64, maxFiles int) (Inbox, error) {
+	id, err := randomToken(8)
+	if err != nil {
+		return Inbox{}, err
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return Inbox{}, err
+	}
+
+	now := time.Now().UTC()
+	inbox := Inbox{
+		ID:          id,
+		Token:       token,
+		BucketName:  bucketName,
+		Prefix:      strings.Trim(prefix, "/"),
+		Note:        note,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(expires),
+		MaxFileSize: maxFileSize,
+		MaxFiles:    maxFiles,
+	}
+
+	return inbox, inboxes.Put(inbox)
+}
+
+func listInboxes(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(inboxes.List(func(a, b *Inbox) bool {
+		return a.CreatedAt.After(b.CreatedAt)
+	}))
+}
+
+func createInbox(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		BucketName  string `json:"bucketName"`
+		Prefix      string `json:"prefix"`
+		Note        string `json:"note"`
+		CreatedBy   string `json:"createdBy"`
+		Expires     int    `json:"expires"`
+		MaxFileSize int64  `json:"maxFileSize"`
+		MaxFiles    int    `json:"maxFiles"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.BucketName == "" {
+		httpError(w, r, http.StatusBadRequest, "bucket_name_required")
+		return
+	}
+	if data.Expires <= 0 || data.MaxFileSize <= 0 || data.MaxFiles <= 0 {
+		httpError(w, r, http.StatusBadRequest, "inbox_limits_required")
+		return
+	}
+
+	inbox, err := newInbox(data.BucketName, data.Prefix, data.Note, data.CreatedBy, time.Duration(data.Expires)*time.Second, data.MaxFileSize, data.MaxFiles)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "inbox_create_failed", err)
+		return
+	}
+
+	audit.Record(r, "inbox.create", inbox.BucketName, inbox.Prefix, map[string]interface{}{
+		"inboxId":   inbox.ID,
+		"createdBy": inbox.CreatedBy,
+		"expiresAt": inbox.ExpiresAt,
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(inbox)
+}
+
+func deleteInbox(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	found, err := inboxes.Delete(vars["inboxId"])
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "inbox_delete_failed", err)
+		return
+	}
+	if !found {
+		httpError(w, r, http.StatusNotFound, "inbox_not_found")
+		return
+	}
+
+	audit.Record(r, "inbox.delete", "", "", map[string]interface{}{"inboxId": vars["inboxId"]})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func findInboxByToken(token string) (Inbox, bool) {
+	return inboxes.Find(func(inbox *Inbox) bool {
+		return inbox.Token == token
+	})
+}
+
+func getPublicInbox(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	inbox, found := findInboxByToken(vars["token"])
+	if !found {
+		httpError(w, r, http.StatusNotFound, "inbox_not_found")
+		return
+	}
+	if inbox.Expired() {
+		httpError(w, r, http.StatusGone, "inbox_expired")
+		return
+	}
+
+	json.NewEncoder(w).Encode(PublicInbox{
+		Note:           inbox.Note,
+		ExpiresAt:      inbox.ExpiresAt,
+		MaxFileSize:    inbox.MaxFileSize,
+		RemainingFiles: inbox.MaxFiles - inbox.UploadCount,
+	})
+}
+
+func uploadToInbox(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	inbox, found := findInboxByToken(vars["token"])
+	if !found {
+		httpError(w, r, http.StatusNotFound, "inbox_not_found")
+		return
+	}
+	if inbox.Expired() {
+		httpError(w, r, http.StatusGone, "inbox_expired")
+		return
+	}
+	if !checkNotFrozen(w, r, inbox.BucketName) {
+		return
+	}
+
+	// Leave some room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, inbox.MaxFileSize+(1<<20))
+	r.ParseMultipartForm(10 << 20) // 10 MB
+
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "file_missing")
+		return
+	}
+	defer file.Close()
+
+	if handler.Size > inbox.MaxFileSize {
+		httpError(w, r, http.StatusRequestEntityTooLarge, "inbox_file_too_large", inbox.MaxFileSize)
+		return
+	}
+
+	name := path.Base(path.Clean("/" + handler.Filename))
+	if name == "/" || name == "." {
+		httpError(w, r, http.StatusBadRequest, "file_missing")
+		return
+	}
+
+	key := name
+	if inbox.Prefix != "" {
+		key = inbox.Prefix + "/" + name
+	}
+
+	ctx := r.Context()
+
+	if err := scanUpload(ctx, file); err != nil {
+		audit.Record(r, "inbox.upload.rejected", inbox.BucketName, key, map[string]interface{}{
+			"inboxId": inbox.ID,
+			"reason":  err.Error(),
+		})
+		httpError(w, r, http.StatusUnprocessableEntity, "inbox_scan_rejected", err)
+		return
+	}
+
+	exists, err := objectExists(ctx, inbox.BucketName, key)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "upload_failed", err)
+		return
+	}
+	if exists {
+		httpError(w, r, http.StatusConflict, "inbox_file_exists", name)
+		return
+	}
+
+	// Reserve a slot first so parallel uploads cannot exceed the limit
+	_, _, err = inboxes.Update(inbox.ID, func(inbox *Inbox) error {
+		if inbox.UploadCount >= inbox.MaxFiles {
+			return errInboxFull
+		}
+		inbox.UploadCount++
+		return nil
+	})
+	if errors.Is(err, errInboxFull) {
+		httpError(w, r, http.StatusForbidden, "inbox_full")
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "upload_failed", err)
+		return
+	}
+
+	_, err = bucketClient(ctx, inbox.BucketName).PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(inbox.BucketName),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+	if err != nil {
+		inboxes.Update(inbox.ID, func(inbox *Inbox) error {
+			inbox.UploadCount--
+			return nil
+		})
+		httpError(w, r, http.StatusInternalServerError, "upload_failed", err)
+		return
+	}
+
+	audit.Record(r, "inbox.upload", inbox.BucketName, key, map[string]interface{}{
+		"inboxId": inbox.ID,
+		"size":    handler.Size,
+	})
+
+	publishObjectEvent(ObjectCreated, inbox.BucketName, key)
+	// Inbox uploads are not under /api, where the middleware drops listings
+	invalidateListings(inbox.BucketName)
+	notifyTransferUpload(inbox, key, handler.Size)
+
+	w.WriteHeader(http.StatusCreated)
+}
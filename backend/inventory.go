@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+var inventoryColumns = []string{"key", "size", "lastModified", "storageClass", "etag"}
+
+// exportInventory streams one CSV row per object below prefix. Unlike the
+// metadata export it only needs the listing, so it is cheap for large buckets.
+func exportInventory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	prefix := r.URL.Query().Get("prefix")
+
+	fileName := bucketName
+	if prefix != "" {
+		fileName = path.Clean(prefix)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", attachmentDisposition(path.Base(fileName)+"-inventory.csv"))
+
+	writer := csv.NewWriter(w)
+	writer.Write(inventoryColumns)
+
+	err := walkObjects(r.Context(), bucketName, prefix, func(obj types.Object) error {
+		storageClass := string(obj.StorageClass)
+		if storageClass == "" {
+			storageClass = "STANDARD"
+		}
+
+		writer.Write([]string{
+			aws.ToString(obj.Key),
+			strconv.FormatInt(aws.ToInt64(obj.Size), 10),
+			aws.ToTime(obj.LastModified).UTC().Format(time.RFC3339),
+			storageClass,
+			strings.Trim(aws.ToString(obj.ETag), `"`),
+		})
+		return writer.Error()
+	})
+	writer.Flush()
+
+	// The status line is already sent, all we can do is cut the file short
+	if err != nil {
+		log.Printf("inventory export of %s/%s aborted: %v", bucketName, prefix, err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// allowedNetworks and deniedNetworks hold the parsed server.allowed_cidrs and
+// server.denied_cidrs ranges
+var (
+	allowedNetworks []*net.IPNet
+	deniedNetworks  []*net.IPNet
+)
+
+// isPublicRoute reports whether the request targets the share and inbox
+// routes meant for external recipients
+func isPublicRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	template, _ := route.GetPathTemplate()
+
+	return strings.Contains(template, "/api/public/")
+}
+
+// ipFilterMiddleware rejects clients from denied ranges and, once an allow list
+// is configured, all clients outside of it. Public routes only honour the deny
+// list, shares and inboxes restrict their recipients themselves.
+func ipFilterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(allowedNetworks) == 0 && len(deniedNetworks) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip := clientIP(r)
+		denied := ip == nil || containsIP(deniedNetworks, ip)
+		if !denied && len(allowedNetworks) > 0 && !isPublicRoute(r) {
+			denied = !containsIP(allowedNetworks, ip)
+		}
+
+		if denied {
+			httpError(w, r, http.StatusForbidden, "client_ip_denied")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const jobEventInterval = 500 * time.Millisecond
+
+// JobProgress is sent as server-sent event while a job runs. Failed items are
+// sent once, in the event after they were reported.
+type JobProgress struct {
+	Job
+	NewErrors []JobItemResult `json:"newErrors"`
+}
+
+// writeEvent sends one server-sent event and flushes it to the client
+func writeEvent(w http.ResponseWriter, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+
+	return http.NewResponseController(w).Flush()
+}
+
+// streamJobEvents sends the progress of a job until it finishes, so long
+// running jobs like deleting large folders can show a live counter
+func streamJobEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["jobId"]
+
+	if _, ok := jobs.Get(jobID); !ok {
+		httpError(w, r, http.StatusNotFound, "job_not_found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps reverse proxies like nginx from buffering the events
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(jobEventInterval)
+	defer ticker.Stop()
+
+	sent := 0
+	for {
+		job, _ := jobs.Get(jobID)
+
+		progress := JobProgress{Job: job, NewErrors: []JobItemResult{}}
+		for _, result := range job.Results[sent:] {
+			if result.Error != "" {
+				progress.NewErrors = append(progress.NewErrors, result)
+			}
+		}
+		sent = len(job.Results)
+		progress.Results = nil
+
+		event := "progress"
+		if job.Finished() {
+			event = "done"
+		}
+		if err := writeEvent(w, event, progress); err != nil || event == "done" {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+type JobItemResult struct {
+	Key   string `json:"key"`
+	Error string `json:"error,omitempty"`
+}
+
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Status     JobStatus       `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Total      int             `json:"total"`
+	Processed  int             `json:"processed"`
+	Failed     int             `json:"failed"`
+	Bytes      int64           `json:"bytes"`
+	Results    []JobItemResult `json:"results,omitempty"`
+	Result     interface{}     `json:"result,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	// Deadline is set when jobs of this type have a timeout configured
+	Deadline *time.Time `json:"deadline,omitempty"`
+
+	published time.Time
+}
+
+func (j Job) Finished() bool {
+	return j.FinishedAt != nil
+}
+
+// jobRun is the body of a job. Per-item outcomes are reported through the
+// handle, a returned error marks the whole job as failed.
+type jobRun func(ctx context.Context, job *jobHandle) error
+
+type jobManager struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc
+}
+
+var jobs = &jobManager{jobs: make(map[string]*Job), cancels: make(map[string]context.CancelFunc)}
+
+// jobTimeout is how long jobs of the type may run, 0 means without limit
+func jobTimeout(jobType string) time.Duration {
+	minutes, ok := appConfig.Jobs.Timeouts[jobType]
+	if !ok {
+		minutes = appConfig.Jobs.TimeoutMinutes
+	}
+
+	return time.Duration(max(minutes, 0)) * time.Minute
+}
+
+type jobHandle struct {
+	manager *jobManager
+	id      string
+}
+
+func (m *jobManager) Start(jobType string, run jobRun) (Job, error) {
+	id, err := randomToken(8)
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := &Job{
+		ID:        id,
+		Type:      jobType,
+		Status:    JobPending,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	timeout := jobTimeout(jobType)
+	if timeout > 0 {
+		deadline := job.CreatedAt.Add(timeout)
+		job.Deadline = &deadline
+		ctx, cancel = context.WithDeadline(context.Background(), deadline)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	m.mu.Lock()
+	m.jobs[id] = job
+	m.cancels[id] = cancel
+	snapshot := *job
+	m.mu.Unlock()
+
+	go m.run(ctx, id, timeout, run)
+
+	return snapshot, nil
+}
+
+func (m *jobManager) run(ctx context.Context, id string, timeout time.Duration, run jobRun) {
+	m.update(id, func(job *Job) {
+		now := time.Now().UTC()
+		job.Status = JobRunning
+		job.StartedAt = &now
+	})
+
+	err := run(ctx, &jobHandle{manager: m, id: id})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cancels[id]()
+	delete(m.cancels, id)
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+	job.Status = JobCompleted
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+
+		// Errors of an aborted job are mostly about the cancelled context,
+		// the reason for the abort is more useful
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			job.Error = newAPIError("job_timed_out", timeout.String()).Error()
+		case errors.Is(ctx.Err(), context.Canceled):
+			job.Status = JobCancelled
+			job.Error = ""
+		}
+	}
+	m.publish("job.done", job)
+}
+
+// update changes a job and publishes its progress, at most once per
+// jobEventInterval
+func (m *jobManager) update(id string, fn func(job *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return
+	}
+	fn(job)
+
+	if now := time.Now(); now.Sub(job.published) >= jobEventInterval {
+		job.published = now
+		m.publish("job.progress", job)
+	}
+}
+
+func (m *jobManager) publish(eventType string, job *Job) {
+	snapshot := *job
+	snapshot.Results = nil
+	events.Publish(eventType, snapshot)
+}
+
+func (m *jobManager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+
+	snapshot := *job
+	snapshot.Results = append([]JobItemResult(nil), job.Results...)
+
+	return snapshot, true
+}
+
+func (m *jobManager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		snapshot := *job
+		// Listing only gives an overview, per-item results are fetched per job
+		snapshot.Results = nil
+		list = append(list, snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+
+	return list
+}
+
+// Cancel aborts a pending or running job through its context. The job is
+// marked as cancelled once its run returned.
+func (m *jobManager) Cancel(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	if cancel, running := m.cancels[id]; running {
+		cancel()
+	}
+
+	snapshot := *job
+	snapshot.Results = nil
+
+	return snapshot, true
+}
+
+// Prune forgets jobs that finished before the given time
+func (m *jobManager) Prune(before time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pruned := 0
+	for id, job := range m.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(before) {
+			delete(m.jobs, id)
+			pruned++
+		}
+	}
+
+	return pruned
+}
+
+func (h *jobHandle) SetTotal(total int) {
+	h.manager.update(h.id, func(job *Job) {
+		job.Total = total
+	})
+}
+
+func (h *jobHandle) AddTotal(count int) {
+	h.manager.update(h.id, func(job *Job) {
+		job.Total += count
+	})
+}
+
+// SetResult attaches the final outcome of the job, e.g. the location of a generated file
+func (h *jobHandle) SetResult(result interface{}) {
+	h.manager.update(h.id, func(job *Job) {
+		job.Result = result
+	})
+}
+
+func (h *jobHandle) ID() string {
+	return h.id
+}
+
+// AddBytes tracks the amount of data a job has transferred so far
+func (h *jobHandle) AddBytes(bytes int64) {
+	h.manager.update(h.id, func(job *Job) {
+		job.Bytes += bytes
+	})
+}
+
+// Advance counts processed items without recording a result for each of them,
+// for scans over large numbers of objects
+func (h *jobHandle) Advance(count int) {
+	h.manager.update(h.id, func(job *Job) {
+		job.Processed += count
+	})
+}
+
+// Done counts one processed item and only keeps a result when it failed, so
+// jobs over large prefixes do not hold every key in memory
+func (h *jobHandle) Done(key string, err error) {
+	if err != nil {
+		h.Report(key, err)
+		return
+	}
+	h.Advance(1)
+}
+
+func (h *jobHandle) Report(key string, err error) {
+	h.manager.update(h.id, func(job *Job) {
+		job.Processed++
+		result := JobItemResult{Key: key}
+		if err != nil {
+			job.Failed++
+			result.Error = err.Error()
+		}
+		job.Results = append(job.Results, result)
+	})
+}
+
+func listJobs(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(jobs.List())
+}
+
+func getJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	job, ok := jobs.Get(vars["jobId"])
+	if !ok {
+		httpError(w, r, http.StatusNotFound, "job_not_found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+// cancelJob aborts a job. Stats scans are queued outside of the jobs and are
+// accepted here as well, so clients can cancel anything they started.
+func cancelJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["jobId"]
+
+	job, ok := jobs.Cancel(jobID)
+	if !ok {
+		if !statsScans.Cancel(jobID) {
+			httpError(w, r, http.StatusNotFound, "job_not_found")
+			return
+		}
+		audit.Record(r, "stats_scan.cancel", "", "", map[string]interface{}{"scanId": jobID})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if job.Finished() {
+		httpError(w, r, http.StatusConflict, "job_already_finished", job.Status)
+		return
+	}
+
+	audit.Record(r, "job.cancel", "", "", map[string]interface{}{"jobId": jobID, "type": job.Type})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJobAccepted(w http.ResponseWriter, job Job) {
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
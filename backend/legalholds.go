@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+var legalHoldRequests *recordStore[LegalHoldRequest]
+
+type LegalHoldState string
+
+const (
+	LegalHoldPending  LegalHoldState = "pending"
+	LegalHoldApproved LegalHoldState = "approved"
+	LegalHoldRejected LegalHoldState = "rejected"
+)
+
+// maxLegalHoldKeys bounds explicit key lists, larger selections use a prefix
+const maxLegalHoldKeys = 10000
+
+var errLegalHoldDecided = errors.New("legal hold request already decided")
+
+// LegalHoldRequest applies or removes legal holds on a selection of objects
+// once a second person approved it. The selection is either an explicit list
+// of keys, e.g. a search result, or all objects below a prefix matching the
+// given wildcard patterns and search filters. A saved search fills in its
+// prefix and filters when the request is made, relative times in them are
+// resolved when the request runs.
+type LegalHoldRequest struct {
+	ID          string         `json:"id"`
+	BucketName  string         `json:"bucketName"`
+	Status      string         `json:"status"`
+	Keys        []string       `json:"keys,omitempty"`
+	Prefix      string         `json:"prefix,omitempty"`
+	Patterns    []string       `json:"patterns,omitempty"`
+	Filters     *SearchFilters `json:"filters,omitempty"`
+	SearchID    string         `json:"searchId,omitempty"`
+	Reason      string         `json:"reason"`
+	RequestedBy string         `json:"requestedBy"`
+	RequestedAt time.Time      `json:"requestedAt"`
+	State       LegalHoldState `json:"state"`
+	DecidedBy   string         `json:"decidedBy,omitempty"`
+	DecidedAt   *time.Time     `json:"decidedAt,omitempty"`
+	JobID       string         `json:"jobId,omitempty"`
+}
+
+func newLegalHoldStore(dataDir string) (*recordStore[LegalHoldRequest], error) {
+	return newRecordStore(dataDir, "legal-holds.json", func(request *LegalHoldRequest) string {
+		return request.ID
+	})
+}
+
+func (l *LegalHoldRequest) Matches(key string) bool {
+	return len(l.Patterns) == 0 || matchesAny(l.Patterns, key, false)
+}
+
+func runLegalHoldRequest(ctx context.Context, job *jobHandle, request LegalHoldRequest) error {
+	status := types.ObjectLockLegalHoldStatus(request.Status)
+
+	if len(request.Keys) > 0 {
+		job.SetTotal(len(request.Keys))
+		for _, key := range request.Keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			job.Done(key, setLegalHold(ctx, request.BucketName, key, nil, status))
+		}
+		return nil
+	}
+
+	var filter objectFilter
+	if request.Filters != nil {
+		var apiErr *apiError
+		if filter, apiErr = parseObjectFilter(request.Filters.values()); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	return walkObjects(ctx, request.BucketName, request.Prefix, func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		if !request.Matches(key) || !filter.Matches(obj) {
+			return nil
+		}
+
+		job.AddTotal(1)
+		job.Done(key, setLegalHold(ctx, request.BucketName, key, nil, status))
+
+		return nil
+	})
+}
+
+func listLegalHoldRequests(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	requests := legalHoldRequests.List(func(a, b *LegalHoldRequest) bool {
+		return a.RequestedAt.After(b.RequestedAt)
+	})
+
+	filtered := make([]LegalHoldRequest, 0, len(requests))
+	for _, request := range requests {
+		if request.BucketName == bucketName {
+			filtered = append(filtered, request)
+		}
+	}
+
+	json.NewEncoder(w).Encode(filtered)
+}
+
+func createLegalHoldRequest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Status      string         `json:"status"`
+		Keys        []string       `json:"keys"`
+		Prefix      string         `json:"prefix"`
+		Patterns    []string       `json:"patterns"`
+		Filters     *SearchFilters `json:"filters"`
+		SearchID    string         `json:"searchId"`
+		Reason      string         `json:"reason"`
+		RequestedBy string         `json:"requestedBy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if !isKnownValue(data.Status, types.ObjectLockLegalHoldStatus("").Values()) {
+		httpError(w, r, http.StatusBadRequest, "legal_hold_status_invalid", data.Status)
+		return
+	}
+	if strings.TrimSpace(data.RequestedBy) == "" || strings.TrimSpace(data.Reason) == "" {
+		httpError(w, r, http.StatusBadRequest, "legal_hold_request_fields_required")
+		return
+	}
+	if data.SearchID != "" {
+		search, found := savedSearches.Get(data.SearchID)
+		if !found || search.BucketName != bucketName {
+			httpError(w, r, http.StatusBadRequest, "saved_search_not_found")
+			return
+		}
+		if data.Prefix != "" || data.Filters != nil {
+			httpError(w, r, http.StatusBadRequest, "legal_hold_selection_invalid")
+			return
+		}
+		data.Prefix = search.Prefix
+		data.Filters = &search.Filters
+	}
+	if data.Filters != nil {
+		if _, apiErr := parseObjectFilter(data.Filters.values()); apiErr != nil {
+			writeAPIError(w, r, http.StatusBadRequest, apiErr)
+			return
+		}
+		if data.Filters.isEmpty() {
+			data.Filters = nil
+		}
+	}
+	// Either a list of keys or a prefix, an empty selection would cover the whole bucket
+	if (len(data.Keys) > 0) == (data.Prefix != "" || len(data.Patterns) > 0 || data.Filters != nil) {
+		httpError(w, r, http.StatusBadRequest, "legal_hold_selection_invalid")
+		return
+	}
+	if len(data.Keys) > maxLegalHoldKeys {
+		httpError(w, r, http.StatusBadRequest, "legal_hold_too_many_keys", maxLegalHoldKeys)
+		return
+	}
+
+	id, err := randomToken(8)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "legal_hold_request_save_failed", err)
+		return
+	}
+
+	request := LegalHoldRequest{
+		ID:          id,
+		BucketName:  bucketName,
+		Status:      data.Status,
+		Keys:        data.Keys,
+		Prefix:      data.Prefix,
+		Patterns:    data.Patterns,
+		Filters:     data.Filters,
+		SearchID:    data.SearchID,
+		Reason:      data.Reason,
+		RequestedBy: data.RequestedBy,
+		RequestedAt: time.Now().UTC(),
+		State:       LegalHoldPending,
+	}
+	if err := legalHoldRequests.Put(request); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "legal_hold_request_save_failed", err)
+		return
+	}
+
+	audit.Record(r, "legal-hold.request", bucketName, data.Prefix, map[string]interface{}{
+		"requestId":   id,
+		"status":      data.Status,
+		"keys":        len(data.Keys),
+		"patterns":    data.Patterns,
+		"searchId":    data.SearchID,
+		"requestedBy": data.RequestedBy,
+		"reason":      data.Reason,
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(request)
+}
+
+// decideLegalHoldRequest moves a pending request to state. The person deciding
+// must not be the one who asked for it.
+func decideLegalHoldRequest(w http.ResponseWriter, r *http.Request, state LegalHoldState) (LegalHoldRequest, bool) {
+	vars := mux.Vars(r)
+
+	var data struct {
+		DecidedBy string `json:"decidedBy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return LegalHoldRequest{}, false
+	}
+	if strings.TrimSpace(data.DecidedBy) == "" {
+		httpError(w, r, http.StatusBadRequest, "legal_hold_decider_required")
+		return LegalHoldRequest{}, false
+	}
+
+	request, found := legalHoldRequests.Get(vars["requestId"])
+	if !found || request.BucketName != vars["bucketName"] {
+		httpError(w, r, http.StatusNotFound, "legal_hold_request_not_found")
+		return LegalHoldRequest{}, false
+	}
+	if strings.EqualFold(request.RequestedBy, data.DecidedBy) {
+		httpError(w, r, http.StatusForbidden, "legal_hold_self_approval")
+		return LegalHoldRequest{}, false
+	}
+
+	request, _, err := legalHoldRequests.Update(request.ID, func(request *LegalHoldRequest) error {
+		if request.State != LegalHoldPending {
+			return errLegalHoldDecided
+		}
+		now := time.Now().UTC()
+		request.State = state
+		request.DecidedBy = data.DecidedBy
+		request.DecidedAt = &now
+		return nil
+	})
+	if errors.Is(err, errLegalHoldDecided) {
+		httpError(w, r, http.StatusConflict, "legal_hold_request_decided")
+		return LegalHoldRequest{}, false
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "legal_hold_request_save_failed", err)
+		return LegalHoldRequest{}, false
+	}
+
+	audit.Record(r, "legal-hold."+string(state), request.BucketName, request.Prefix, map[string]interface{}{
+		"requestId": request.ID,
+		"decidedBy": request.DecidedBy,
+	})
+
+	return request, true
+}
+
+func approveLegalHoldRequest(w http.ResponseWriter, r *http.Request) {
+	request, ok := decideLegalHoldRequest(w, r, LegalHoldApproved)
+	if !ok {
+		return
+	}
+
+	job, err := startBucketJob(request.BucketName, "legal-hold", request.Prefix, func(ctx context.Context, job *jobHandle) error {
+		return runLegalHoldRequest(ctx, job, request)
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	_, _, err = legalHoldRequests.Update(request.ID, func(request *LegalHoldRequest) error {
+		request.JobID = job.ID
+		return nil
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "legal_hold_request_save_failed", err)
+		return
+	}
+
+	writeJobAccepted(w, job)
+}
+
+func rejectLegalHoldRequest(w http.ResponseWriter, r *http.Request) {
+	request, ok := decideLegalHoldRequest(w, r, LegalHoldRejected)
+	if !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(request)
+}
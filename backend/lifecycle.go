@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+// S3 accepts at most 1000 rules per lifecycle configuration
+const maxLifecycleRules = 1000
+
+type LifecycleFilter struct {
+	Prefix                string            `json:"prefix"`
+	ObjectSizeGreaterThan int64             `json:"objectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    int64             `json:"objectSizeLessThan,omitempty"`
+	Tags                  map[string]string `json:"tags,omitempty"`
+}
+
+type LifecycleExpiration struct {
+	Days                      int32      `json:"days,omitempty"`
+	Date                      *time.Time `json:"date,omitempty"`
+	ExpiredObjectDeleteMarker bool       `json:"expiredObjectDeleteMarker,omitempty"`
+}
+
+type LifecycleTransition struct {
+	Days         int32      `json:"days,omitempty"`
+	Date         *time.Time `json:"date,omitempty"`
+	StorageClass string     `json:"storageClass"`
+}
+
+type LifecycleNoncurrentExpiration struct {
+	NoncurrentDays          int32 `json:"noncurrentDays"`
+	NewerNoncurrentVersions int32 `json:"newerNoncurrentVersions,omitempty"`
+}
+
+type LifecycleNoncurrentTransition struct {
+	NoncurrentDays int32  `json:"noncurrentDays"`
+	StorageClass   string `json:"storageClass"`
+}
+
+type LifecycleRule struct {
+	ID                                 string                          `json:"id"`
+	Enabled                            bool                            `json:"enabled"`
+	Filter                             LifecycleFilter                 `json:"filter"`
+	Expiration                         *LifecycleExpiration            `json:"expiration,omitempty"`
+	Transitions                        []LifecycleTransition           `json:"transitions,omitempty"`
+	NoncurrentVersionExpiration        *LifecycleNoncurrentExpiration  `json:"noncurrentVersionExpiration,omitempty"`
+	NoncurrentVersionTransitions       []LifecycleNoncurrentTransition `json:"noncurrentVersionTransitions,omitempty"`
+	AbortIncompleteMultipartUploadDays int32                           `json:"abortIncompleteMultipartUploadDays,omitempty"`
+}
+
+func (r *LifecycleRule) Validate() *apiError {
+	if r.Expiration == nil && len(r.Transitions) == 0 && r.NoncurrentVersionExpiration == nil &&
+		len(r.NoncurrentVersionTransitions) == 0 && r.AbortIncompleteMultipartUploadDays == 0 {
+		return newAPIError("lifecycle_rule_no_action")
+	}
+	if r.Expiration != nil && r.Expiration.Days <= 0 && r.Expiration.Date == nil && !r.Expiration.ExpiredObjectDeleteMarker {
+		return newAPIError("lifecycle_expiration_invalid")
+	}
+	if r.NoncurrentVersionExpiration != nil && r.NoncurrentVersionExpiration.NoncurrentDays <= 0 {
+		return newAPIError("lifecycle_noncurrent_days_invalid")
+	}
+	if r.AbortIncompleteMultipartUploadDays < 0 {
+		return newAPIError("lifecycle_abort_days_invalid")
+	}
+	if apiErr := validateTags(r.Filter.Tags, maxObjectTags); apiErr != nil {
+		return apiErr
+	}
+
+	for _, transition := range r.Transitions {
+		if transition.Days < 0 || (transition.Days == 0 && transition.Date == nil) {
+			return newAPIError("lifecycle_transition_invalid")
+		}
+		if !isKnownStorageClass(transition.StorageClass) {
+			return newAPIError("lifecycle_storage_class_invalid", transition.StorageClass)
+		}
+	}
+	for _, transition := range r.NoncurrentVersionTransitions {
+		if transition.NoncurrentDays <= 0 {
+			return newAPIError("lifecycle_noncurrent_days_invalid")
+		}
+		if !isKnownStorageClass(transition.StorageClass) {
+			return newAPIError("lifecycle_storage_class_invalid", transition.StorageClass)
+		}
+	}
+
+	return nil
+}
+
+// Matches checks prefix and size conditions. Tag conditions are not evaluated
+// since object listings carry no tags.
+func (f *LifecycleFilter) Matches(obj types.Object) bool {
+	size := aws.ToInt64(obj.Size)
+
+	if !strings.HasPrefix(aws.ToString(obj.Key), f.Prefix) {
+		return false
+	}
+	if f.ObjectSizeGreaterThan > 0 && size <= f.ObjectSizeGreaterThan {
+		return false
+	}
+	if f.ObjectSizeLessThan > 0 && size >= f.ObjectSizeLessThan {
+		return false
+	}
+
+	return true
+}
+
+func isKnownStorageClass(storageClass string) bool {
+	for _, known := range types.TransitionStorageClass("").Values() {
+		if string(known) == storageClass {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lifecycleDue reports whether an action configured by days or date applies at now
+func lifecycleDue(days int32, date *time.Time, lastModified, now time.Time) bool {
+	if date != nil {
+		return !now.Before(*date)
+	}
+
+	return now.Sub(lastModified) >= time.Duration(days)*24*time.Hour
+}
+
+type lifecycleCount struct {
+	Objects int64 `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+func (c *lifecycleCount) add(size int64) {
+	c.Objects++
+	c.Bytes += size
+}
+
+type LifecycleSimulation struct {
+	Scanned     lifecycleCount             `json:"scanned"`
+	Matched     lifecycleCount             `json:"matched"`
+	Expired     lifecycleCount             `json:"expired"`
+	Transitions map[string]*lifecycleCount `json:"transitions"`
+	Unaffected  lifecycleCount             `json:"unaffected"`
+}
+
+func simulateLifecycleRule(ctx context.Context, bucketName, prefix string, rule LifecycleRule) (*LifecycleSimulation, error) {
+	now := time.Now()
+	result := &LifecycleSimulation{Transitions: make(map[string]*lifecycleCount)}
+
+	err := walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		size := aws.ToInt64(obj.Size)
+		result.Scanned.add(size)
+
+		if !rule.Filter.Matches(obj) {
+			return nil
+		}
+		result.Matched.add(size)
+
+		lastModified := aws.ToTime(obj.LastModified)
+		expires := rule.Expiration != nil && (rule.Expiration.Days > 0 || rule.Expiration.Date != nil)
+		if expires && lifecycleDue(rule.Expiration.Days, rule.Expiration.Date, lastModified, now) {
+			result.Expired.add(size)
+			return nil
+		}
+
+		// The transition that became due last decides where the object ends up
+		target := ""
+		var targetDue time.Time
+		for _, transition := range rule.Transitions {
+			if !lifecycleDue(transition.Days, transition.Date, lastModified, now) {
+				continue
+			}
+
+			due := lastModified.Add(time.Duration(transition.Days) * 24 * time.Hour)
+			if transition.Date != nil {
+				due = *transition.Date
+			}
+			if target == "" || due.After(targetDue) {
+				target = transition.StorageClass
+				targetDue = due
+			}
+		}
+
+		if target == "" || target == string(obj.StorageClass) {
+			result.Unaffected.add(size)
+			return nil
+		}
+
+		if result.Transitions[target] == nil {
+			result.Transitions[target] = &lifecycleCount{}
+		}
+		result.Transitions[target].add(size)
+
+		return nil
+	})
+
+	return result, err
+}
+
+func simulateLifecycle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Prefix string        `json:"prefix"`
+		Rule   LifecycleRule `json:"rule"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if apiErr := data.Rule.Validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	// Only objects the rule could ever match need to be scanned
+	prefix := data.Prefix
+	if strings.HasPrefix(data.Rule.Filter.Prefix, prefix) {
+		prefix = data.Rule.Filter.Prefix
+	}
+
+	result, err := simulateLifecycleRule(r.Context(), bucketName, prefix, data.Rule)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "list_objects_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+func optionalInt32(value int32) *int32 {
+	if value == 0 {
+		return nil
+	}
+	return aws.Int32(value)
+}
+
+func optionalInt64(value int64) *int64 {
+	if value == 0 {
+		return nil
+	}
+	return aws.Int64(value)
+}
+
+// toS3 converts the rule into the SDK representation. A filter with more than
+// one condition has to be wrapped into an And operator.
+func (r *LifecycleRule) toS3() types.LifecycleRule {
+	rule := types.LifecycleRule{
+		ID:     aws.String(r.ID),
+		Status: types.ExpirationStatusDisabled,
+	}
+	if r.Enabled {
+		rule.Status = types.ExpirationStatusEnabled
+	}
+
+	conditions := len(r.Filter.Tags)
+	if r.Filter.Prefix != "" {
+		conditions++
+	}
+	if r.Filter.ObjectSizeGreaterThan > 0 {
+		conditions++
+	}
+	if r.Filter.ObjectSizeLessThan > 0 {
+		conditions++
+	}
+
+	filter := &types.LifecycleRuleFilter{}
+	switch {
+	case conditions > 1:
+		filter.And = &types.LifecycleRuleAndOperator{
+			Prefix:                aws.String(r.Filter.Prefix),
+			ObjectSizeGreaterThan: optionalInt64(r.Filter.ObjectSizeGreaterThan),
+			ObjectSizeLessThan:    optionalInt64(r.Filter.ObjectSizeLessThan),
+			Tags:                  tagsFromMap(r.Filter.Tags),
+		}
+	case len(r.Filter.Tags) == 1:
+		filter.Tag = &tagsFromMap(r.Filter.Tags)[0]
+	case r.Filter.ObjectSizeGreaterThan > 0:
+		filter.ObjectSizeGreaterThan = aws.Int64(r.Filter.ObjectSizeGreaterThan)
+	case r.Filter.ObjectSizeLessThan > 0:
+		filter.ObjectSizeLessThan = aws.Int64(r.Filter.ObjectSizeLessThan)
+	default:
+		filter.Prefix = aws.String(r.Filter.Prefix)
+	}
+	rule.Filter = filter
+
+	if r.Expiration != nil {
+		rule.Expiration = &types.LifecycleExpiration{
+			Days: optionalInt32(r.Expiration.Days),
+			Date: r.Expiration.Date,
+		}
+		if r.Expiration.ExpiredObjectDeleteMarker {
+			rule.Expiration.ExpiredObjectDeleteMarker = aws.Bool(true)
+		}
+	}
+	for _, transition := range r.Transitions {
+		rule.Transitions = append(rule.Transitions, types.Transition{
+			Days:         optionalInt32(transition.Days),
+			Date:         transition.Date,
+			StorageClass: types.TransitionStorageClass(transition.StorageClass),
+		})
+	}
+	if r.NoncurrentVersionExpiration != nil {
+		rule.NoncurrentVersionExpiration = &types.NoncurrentVersionExpiration{
+			NoncurrentDays:          aws.Int32(r.NoncurrentVersionExpiration.NoncurrentDays),
+			NewerNoncurrentVersions: optionalInt32(r.NoncurrentVersionExpiration.NewerNoncurrentVersions),
+		}
+	}
+	for _, transition := range r.NoncurrentVersionTransitions {
+		rule.NoncurrentVersionTransitions = append(rule.NoncurrentVersionTransitions, types.NoncurrentVersionTransition{
+			NoncurrentDays: aws.Int32(transition.NoncurrentDays),
+			StorageClass:   types.TransitionStorageClass(transition.StorageClass),
+		})
+	}
+	if r.AbortIncompleteMultipartUploadDays > 0 {
+		rule.AbortIncompleteMultipartUpload = &types.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int32(r.AbortIncompleteMultipartUploadDays),
+		}
+	}
+
+	return rule
+}
+
+func lifecycleRuleFromS3(rule types.LifecycleRule) LifecycleRule {
+	result := LifecycleRule{
+		ID:      aws.ToString(rule.ID),
+		Enabled: rule.Status == types.ExpirationStatusEnabled,
+		// Rules written before filters existed carry their prefix directly
+		Filter: LifecycleFilter{Prefix: aws.ToString(rule.Prefix)},
+	}
+
+	if filter := rule.Filter; filter != nil {
+		if filter.And != nil {
+			result.Filter = LifecycleFilter{
+				Prefix:                aws.ToString(filter.And.Prefix),
+				ObjectSizeGreaterThan: aws.ToInt64(filter.And.ObjectSizeGreaterThan),
+				ObjectSizeLessThan:    aws.ToInt64(filter.And.ObjectSizeLessThan),
+			}
+			if len(filter.And.Tags) > 0 {
+				result.Filter.Tags = tagsToMap(filter.And.Tags)
+			}
+		} else {
+			result.Filter = LifecycleFilter{
+				Prefix:                aws.ToString(filter.Prefix),
+				ObjectSizeGreaterThan: aws.ToInt64(filter.ObjectSizeGreaterThan),
+				ObjectSizeLessThan:    aws.ToInt64(filter.ObjectSizeLessThan),
+			}
+			if filter.Tag != nil {
+				result.Filter.Tags = tagsToMap([]types.Tag{*filter.Tag})
+			}
+		}
+	}
+
+	if rule.Expiration != nil {
+		result.Expiration = &LifecycleExpiration{
+			Days:                      aws.ToInt32(rule.Expiration.Days),
+			Date:                      rule.Expiration.Date,
+			ExpiredObjectDeleteMarker: aws.ToBool(rule.Expiration.ExpiredObjectDeleteMarker),
+		}
+	}
+	for _, transition := range rule.Transitions {
+		result.Transitions = append(result.Transitions, LifecycleTransition{
+			Days:         aws.ToInt32(transition.Days),
+			Date:         transition.Date,
+			StorageClass: string(transition.StorageClass),
+		})
+	}
+	if rule.NoncurrentVersionExpiration != nil {
+		result.NoncurrentVersionExpiration = &LifecycleNoncurrentExpiration{
+			NoncurrentDays:          aws.ToInt32(rule.NoncurrentVersionExpiration.NoncurrentDays),
+			NewerNoncurrentVersions: aws.ToInt32(rule.NoncurrentVersionExpiration.NewerNoncurrentVersions),
+		}
+	}
+	for _, transition := range rule.NoncurrentVersionTransitions {
+		result.NoncurrentVersionTransitions = append(result.NoncurrentVersionTransitions, LifecycleNoncurrentTransition{
+			NoncurrentDays: aws.ToInt32(transition.NoncurrentDays),
+			StorageClass:   string(transition.StorageClass),
+		})
+	}
+	if rule.AbortIncompleteMultipartUpload != nil {
+		result.AbortIncompleteMultipartUploadDays = aws.ToInt32(rule.AbortIncompleteMultipartUpload.DaysAfterInitiation)
+	}
+
+	return result
+}
+
+func validateLifecycleRules(rules []LifecycleRule) *apiError {
+	if len(rules) > maxLifecycleRules {
+		return newAPIError("lifecycle_too_many_rules", maxLifecycleRules)
+	}
+
+	seen := make(map[string]bool, len(rules))
+	for i := range rules {
+		if rules[i].ID == "" {
+			return newAPIError("lifecycle_rule_id_required")
+		}
+		if seen[rules[i].ID] {
+			return newAPIError("lifecycle_rule_id_duplicate", rules[i].ID)
+		}
+		seen[rules[i].ID] = true
+
+		if apiErr := rules[i].Validate(); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	return nil
+}
+
+func getBucketLifecycle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetBucketLifecycleConfiguration(r.Context(), &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucketName),
+	})
+
+	// Buckets without rules answer with an error instead of an empty configuration
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration" {
+		json.NewEncoder(w).Encode([]LifecycleRule{})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_lifecycle_failed", err)
+		return
+	}
+
+	rules := make([]LifecycleRule, 0, len(result.Rules))
+	for _, rule := range result.Rules {
+		rules = append(rules, lifecycleRuleFromS3(rule))
+	}
+
+	json.NewEncoder(w).Encode(rules)
+}
+
+func putBucketLifecycle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var rules []LifecycleRule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if apiErr := validateLifecycleRules(rules); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	// An empty configuration is rejected by PutBucketLifecycleConfiguration, clearing means deleting
+	if len(rules) == 0 {
+		deleteBucketLifecycle(w, r)
+		return
+	}
+
+	configuration := &types.BucketLifecycleConfiguration{}
+	for i := range rules {
+		configuration.Rules = append(configuration.Rules, rules[i].toS3())
+	}
+
+	_, err := bucketClient(r.Context(), bucketName).PutBucketLifecycleConfiguration(r.Context(), &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucketName),
+		LifecycleConfiguration: configuration,
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_lifecycle_failed", err)
+		return
+	}
+
+	audit.Record(r, "lifecycle.put", bucketName, "", map[string]interface{}{"rules": len(rules)})
+
+	json.NewEncoder(w).Encode(rules)
+}
+
+func deleteBucketLifecycle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	_, err := bucketClient(r.Context(), bucketName).DeleteBucketLifecycle(r.Context(), &s3.DeleteBucketLifecycleInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "delete_lifecycle_failed", err)
+		return
+	}
+
+	audit.Record(r, "lifecycle.delete", bucketName, "", nil)
+
+	w.WriteHeader(http.StatusOK)
+}
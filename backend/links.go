@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+var deepLinks *recordStore[DeepLink]
+
+// DeepLink points at a view of the UI: a bucket, a folder and optionally a
+// selected object. The token is derived from the target, so linking the same
+// view twice gives the same link.
+type DeepLink struct {
+	Token      string    `json:"token"`
+	Region     string    `json:"region"`
+	BucketName string    `json:"bucketName"`
+	Prefix     string    `json:"prefix"`
+	ObjectKey  string    `json:"objectKey,omitempty"`
+	CreatedBy  string    `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func newDeepLinkStore(dataDir string) (*recordStore[DeepLink], error) {
+	return newRecordStore(dataDir, "links.json", func(link *DeepLink) string {
+		return link.Token
+	})
+}
+
+func deepLinkToken(bucketName, prefix, objectKey string) string {
+	sum := sha256.Sum256([]byte(bucketName + "\x00" + prefix + "\x00" + objectKey))
+	return hex.EncodeToString(sum[:12])
+}
+
+// checkDeepLinkTarget makes sure the bucket and the selected object can be
+// accessed with the current credentials, so a link never opens a view that
+// fails to load
+func checkDeepLinkTarget(ctx context.Context, link DeepLink) (int, *apiError) {
+	client := bucketClient(ctx, link.BucketName)
+
+	_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(link.BucketName)})
+	if err == nil && link.ObjectKey != "" {
+		_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:       aws.String(link.BucketName),
+			Key:          aws.String(link.ObjectKey),
+			RequestPayer: requestPayer(ctx, link.BucketName),
+		})
+	}
+	if err == nil {
+		return http.StatusOK, nil
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotFound", "NoSuchBucket", "NoSuchKey":
+			return http.StatusNotFound, newAPIError("link_target_not_found")
+		case "Forbidden", "AccessDenied":
+			return http.StatusForbidden, newAPIError("link_target_forbidden")
+		}
+	}
+
+	return http.StatusInternalServerError, newAPIError("link_check_failed", err)
+}
+
+func createDeepLink(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		BucketName string `json:"bucketName"`
+		Prefix     string `json:"prefix"`
+		ObjectKey  string `json:"objectKey"`
+		CreatedBy  string `json:"createdBy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+	if data.BucketName == "" {
+		httpError(w, r, http.StatusBadRequest, "bucket_name_required")
+		return
+	}
+
+	prefix := strings.TrimPrefix(data.Prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	token := deepLinkToken(data.BucketName, prefix, data.ObjectKey)
+	if link, exists := deepLinks.Get(token); exists {
+		json.NewEncoder(w).Encode(link)
+		return
+	}
+
+	link := DeepLink{
+		Token:      token,
+		Region:     regions.Locate(r.Context(), data.BucketName),
+		BucketName: data.BucketName,
+		Prefix:     prefix,
+		ObjectKey:  data.ObjectKey,
+		CreatedBy:  data.CreatedBy,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if status, apiErr := checkDeepLinkTarget(r.Context(), link); apiErr != nil {
+		writeAPIError(w, r, status, apiErr)
+		return
+	}
+	if err := deepLinks.Put(link); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "link_create_failed", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(link)
+}
+
+// resolveDeepLink returns the view of a link once its target is checked
+// again, the object may have been deleted since the link was made
+func resolveDeepLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	link, found := deepLinks.Get(vars["token"])
+	if !found {
+		httpError(w, r, http.StatusNotFound, "link_not_found")
+		return
+	}
+	if status, apiErr := checkDeepLinkTarget(r.Context(), link); apiErr != nil {
+		writeAPIError(w, r, status, apiErr)
+		return
+	}
+	link.Region = regions.Locate(r.Context(), link.BucketName)
+
+	json.NewEncoder(w).Encode(link)
+}
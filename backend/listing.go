@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// walkObjects calls fn for every object below prefix, following pagination
+func walkObjects(ctx context.Context, bucketName, prefix string, fn func(obj types.Object) error) error {
+	paginator := s3.NewListObjectsV2Paginator(bucketClient(ctx, bucketName), &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Contents {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkVersions calls fn for every page of object versions and delete markers below prefix
+func walkVersions(ctx context.Context, bucketName, prefix string, fn func(page *s3.ListObjectVersionsOutput) error) error {
+	paginator := s3.NewListObjectVersionsPaginator(bucketClient(ctx, bucketName), &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// The bucket list is cached under the empty bucket name
+var bucketListings = newListingCache[[]types.Bucket]()
+var objectListings = newListingCache[*s3.ListObjectsV2Output]()
+
+type listingKey struct {
+	bucketName string
+	prefix     string
+}
+
+type listingEntry[T any] struct {
+	value    T
+	storedAt time.Time
+}
+
+// listingCache keeps recent listings so browsing back and forth between
+// folders does not list them again. Writes through the admin drop the cached
+// listings of their bucket, changes made elsewhere show up after the TTL.
+type listingCache[T any] struct {
+	mu      sync.Mutex
+	entries map[listingKey]listingEntry[T]
+}
+
+func newListingCache[T any]() *listingCache[T] {
+	return &listingCache[T]{entries: make(map[listingKey]listingEntry[T])}
+}
+
+// Get returns a listing that is not older than maxAge
+func (c *listingCache[T]) Get(bucketName, prefix string, maxAge time.Duration) (T, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[listingKey{bucketName, prefix}]
+	age := time.Since(entry.storedAt)
+	if !ok || age >= maxAge {
+		var zero T
+		return zero, 0, false
+	}
+
+	return entry.value, age, true
+}
+
+func (c *listingCache[T]) Put(bucketName, prefix string, value T) {
+	if appConfig.Listing.CacheTTLSeconds <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= appConfig.Listing.MaxEntries {
+		ttl := time.Duration(appConfig.Listing.CacheTTLSeconds) * time.Second
+		for key, entry := range c.entries {
+			if time.Since(entry.storedAt) >= ttl {
+				delete(c.entries, key)
+			}
+		}
+		// Everything is fresh, make room by dropping arbitrary entries
+		for key := range c.entries {
+			if len(c.entries) < appConfig.Listing.MaxEntries {
+				break
+			}
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[listingKey{bucketName, prefix}] = listingEntry[T]{value: value, storedAt: time.Now()}
+}
+
+func (c *listingCache[T]) Invalidate(bucketName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.bucketName == bucketName {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func invalidateListings(bucketName string) {
+	bucketListings.Invalidate("")
+	if bucketName != "" {
+		objectListings.Invalidate(bucketName)
+	}
+}
+
+// listingMaxAge is how old a cached listing may be for this request. Clients
+// needing read-after-write consistency pass noCache=true, cacheTtl can only
+// shorten the configured TTL.
+func listingMaxAge(query url.Values) (time.Duration, *apiError) {
+	maxAge := time.Duration(appConfig.Listing.CacheTTLSeconds) * time.Second
+
+	if query.Get("noCache") == "true" {
+		return 0, nil
+	}
+	if value := query.Get("cacheTtl"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return 0, newAPIError("cache_ttl_invalid", value)
+		}
+		maxAge = min(maxAge, time.Duration(seconds)*time.Second)
+	}
+
+	return maxAge, nil
+}
+
+func writeListingCacheHeaders(w http.ResponseWriter, hit bool, age time.Duration) {
+	if !hit {
+		w.Header().Set("X-Cache", "miss")
+		return
+	}
+
+	w.Header().Set("X-Cache", "hit")
+	w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+}
+
+// listingCacheMiddleware drops cached listings of the bucket in the path
+// after any request that may have changed it, and the bucket list after
+// any request that may have created or deleted a bucket
+func listingCacheMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			return
+		}
+		invalidateListings(mux.Vars(r)["bucketName"])
+	})
+}
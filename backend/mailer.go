@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func mailConfigured() bool {
+	return appConfig.SMTP.Host != "" && appConfig.SMTP.From != ""
+}
+
+func sendMail(to []string, subject, body string) error {
+	if !mailConfigured() {
+		return fmt.Errorf("smtp is not configured")
+	}
+
+	addr := net.JoinHostPort(appConfig.SMTP.Host, strconv.Itoa(appConfig.SMTP.Port))
+
+	var auth smtp.Auth
+	if appConfig.SMTP.Username != "" {
+		auth = smtp.PlainAuth("", appConfig.SMTP.Username, appConfig.SMTP.Password, appConfig.SMTP.Host)
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", appConfig.SMTP.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return smtp.SendMail(addr, auth, appConfig.SMTP.From, to, []byte(message.String()))
+}
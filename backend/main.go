@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -23,6 +24,7 @@ import (
 
 var s3Client *s3.Client
 var awsRegion string
+var appConfig *AppConfig
 
 type spaHandler struct {
 	staticPath string
@@ -45,7 +47,9 @@ func (h spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// check whether a file exists at the given path
 	_, err = os.Stat(path)
 	if os.IsNotExist(err) {
-		// file does not exist, serve index.html
+		// file does not exist, serve index.html. It references the hashed
+		// assets of the current build, so it must always be revalidated
+		w.Header().Set("Cache-Control", "no-cache")
 		http.ServeFile(w, r, filepath.Join(h.staticPath, h.indexPath))
 		return
 	} else if err != nil {
@@ -55,24 +59,41 @@ func (h spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Vite puts a content hash into every asset name, so they never change
+	if strings.HasPrefix(r.URL.Path, "/assets/") {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
 	// otherwise, use http.FileServer to serve the static file
 	http.FileServer(http.Dir(h.staticPath)).ServeHTTP(w, r)
 }
 
 func main() {
-	appConfig, err := NewConfig("config.yaml")
+	var err error
+	appConfig, err = NewConfig("config.yaml")
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
 
 	awsRegion = appConfig.AWS.Region
 
+	// A rotated access key replaces the configured one, so it has to be known
+	// before the clients are set up
+	credentialState, err = newCredentialStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load credentials state: %v", err)
+	}
+	loadActiveCredentials()
+
 	cfg, err := config.LoadDefaultConfig(context.TODO(),
 		config.WithRegion(awsRegion),
 		config.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			pair := activeCredentials()
 			return aws.Credentials{
-				AccessKeyID:     appConfig.AWS.AccessKey,
-				SecretAccessKey: appConfig.AWS.SecretKey,
+				AccessKeyID:     pair.AccessKeyID,
+				SecretAccessKey: pair.SecretAccessKey,
 			}, nil
 		})),
 		config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(
@@ -92,31 +113,379 @@ func main() {
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
 
+	credentialsCache, _ = cfg.Credentials.(*aws.CredentialsCache)
+
+	if appConfig.Debug.Enabled {
+		cfg.APIOptions = append(cfg.APIOptions, captureS3Requests)
+	}
+
 	s3Client = s3.NewFromConfig(cfg, func(o *s3.Options) {
 		o.UsePathStyle = true
 	})
 
+	// The accelerated endpoint only exists on AWS and needs virtual hosted-style addressing
+	if appConfig.AWS.UseAccelerateEndpoint {
+		if appConfig.AWS.Endpoint != "" {
+			log.Printf("ignoring aws.use_accelerate_endpoint, a custom endpoint is configured")
+		} else {
+			s3AccelerateClient = s3.NewFromConfig(cfg, func(o *s3.Options) {
+				o.UseAccelerate = true
+			})
+		}
+	}
+	if appConfig.AWS.AutoDiscover && appConfig.AWS.Endpoint != "" {
+		log.Printf("ignoring aws.auto_discover, a custom endpoint is configured")
+		appConfig.AWS.AutoDiscover = false
+	}
+	if len(appConfig.AWS.Regions) > 0 && appConfig.AWS.Endpoint != "" {
+		log.Printf("ignoring aws.regions, a custom endpoint is configured")
+		appConfig.AWS.Regions = nil
+	}
+	for _, region := range appConfig.AWS.Regions {
+		if !regionPattern.MatchString(region) {
+			log.Fatalf("invalid region %q in aws.regions", region)
+		}
+	}
+
+	var apiErr *apiError
+	if trustedProxies, apiErr = parseCIDRs(appConfig.Server.TrustedProxies); apiErr != nil {
+		log.Fatalf("invalid server.trusted_proxies: %v", apiErr)
+	}
+	if allowedNetworks, apiErr = parseCIDRs(appConfig.Server.AllowedCIDRs); apiErr != nil {
+		log.Fatalf("invalid server.allowed_cidrs: %v", apiErr)
+	}
+	if deniedNetworks, apiErr = parseCIDRs(appConfig.Server.DeniedCIDRs); apiErr != nil {
+		log.Fatalf("invalid server.denied_cidrs: %v", apiErr)
+	}
+
+	shares, err = newShareStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load shares: %v", err)
+	}
+
+	publicIndexes, err = newPublicIndexStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load public indexes: %v", err)
+	}
+
+	deepLinks, err = newDeepLinkStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load deep links: %v", err)
+	}
+
+	schedules, err = newScheduleStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load schedules: %v", err)
+	}
+	scheduleRuns, err = newScheduleRunStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load schedule runs: %v", err)
+	}
+	if configSchedules, err = configuredSchedules(); err != nil {
+		log.Fatalf("invalid schedules: %v", err)
+	}
+
+	inboxes, err = newInboxStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load inboxes: %v", err)
+	}
+
+	transfers, err = newTransferStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load transfers: %v", err)
+	}
+
+	branding, err = newBrandingStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load branding: %v", err)
+	}
+
+	freezes, err = newFreezeStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load bucket freezes: %v", err)
+	}
+
+	legalHoldRequests, err = newLegalHoldStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load legal hold requests: %v", err)
+	}
+
+	downloadCompression, err = newDownloadCompressionStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load download compression settings: %v", err)
+	}
+
+	savedSearches, err = newSavedSearchStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load saved searches: %v", err)
+	}
+
+	thumbnails, err = newThumbnailCache()
+	if err != nil {
+		log.Fatalf("failed to set up thumbnail cache: %v", err)
+	}
+
+	healthHistory, err = newHealthStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load health history: %v", err)
+	}
+	if appConfig.Health.ProbeIntervalSeconds > 0 {
+		go monitorHealth()
+	}
+
+	maintenance, err = newMaintenanceStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load maintenance state: %v", err)
+	}
+
+	audit, err = newAuditLog(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to open audit log: %v", err)
+	}
+	if err := audit.StartShipping(); err != nil {
+		log.Fatalf("failed to set up audit log shipping: %v", err)
+	}
+
+	dedupIndex, err = newHashIndex(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load dedup index: %v", err)
+	}
+
+	statsScans.Start(appConfig.BucketStats.MaxConcurrentScans)
+
+	growthHistory, err = newGrowthStore(appConfig.Storage.DataDir)
+	if err != nil {
+		log.Fatalf("failed to load growth history: %v", err)
+	}
+	if appConfig.Growth.Enabled && appConfig.Growth.IntervalHours > 0 {
+		go trackGrowth()
+	}
+
+	if appConfig.Retention.IntervalMinutes > 0 {
+		go runCleanup()
+	}
+	go runSchedules()
+	if appConfig.Metrics.Enabled && appConfig.Metrics.BucketStatsIntervalMinutes > 0 {
+		go collectBucketStats()
+	}
+
 	r := mux.NewRouter()
 
-	api := r.PathPrefix("/api").Subrouter()
+	// Everything is served below the base path, so path based ingress rules need no rewriting
+	basePath := appConfig.Server.BasePath
+	root := r
+	if basePath != "" {
+		// Relative asset paths of the SPA only resolve with the trailing slash
+		r.Handle(basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+		root = r.PathPrefix(basePath).Subrouter()
+	}
 
+	api := root.PathPrefix("/api").Subrouter()
+	// The version header goes first so rejected requests carry it as well
+	api.Use(versionMiddleware)
+	api.Use(ipFilterMiddleware)
+	api.Use(maintenanceMiddleware)
+	api.Use(freezeMiddleware)
+	api.Use(listingCacheMiddleware)
+	api.Use(s3DebugMiddleware)
+
+	api.HandleFunc("/version", getVersion).Methods("GET")
+	if appConfig.Metrics.Enabled {
+		api.HandleFunc("/metrics", serveMetrics).Methods("GET")
+	}
+	api.HandleFunc("/features", getFeatures).Methods("GET")
+	api.HandleFunc("/settings/branding", getBranding).Methods("GET")
+	api.HandleFunc("/settings/branding", putBranding).Methods("PUT")
+	api.HandleFunc("/maintenance", getMaintenance).Methods("GET")
+	api.HandleFunc("/audit/verify", verifyAuditLog).Methods("GET")
+	api.HandleFunc("/maintenance", putMaintenance).Methods("PUT")
+	api.HandleFunc("/regions", listRegions).Methods("GET")
+	api.HandleFunc("/all-buckets", listAllBuckets).Methods("GET")
+	api.HandleFunc("/regions/{name}/health/history", getRegionHealthHistory).Methods("GET")
+	api.HandleFunc("/regions/{name}/search", searchRegion).Methods("GET")
+	api.HandleFunc("/regions/{name}/permissions", getPermissionReport).Methods("GET")
 	api.HandleFunc("/buckets", listBuckets).Methods("GET")
-	api.HandleFunc("/buckets", createBucket).Methods("POST")
+	api.HandleFunc("/buckets", idempotent(createBucket)).Methods("POST")
 	api.HandleFunc("/buckets/{bucketName}", deleteBucket).Methods("DELETE")
+	api.HandleFunc("/freezes", listFreezes).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/freeze", getBucketFreeze).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/freeze", freezeBucket).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/freeze", unfreezeBucket).Methods("DELETE")
+	api.HandleFunc("/buckets/{bucketName}/tags", getBucketTags).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/tags", putBucketTags).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/tags", deleteBucketTags).Methods("DELETE")
+	api.HandleFunc("/buckets/{bucketName}/object-lock", getObjectLockConfiguration).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/object-lock", putObjectLockConfiguration).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/request-payment", getBucketRequestPayment).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/request-payment", putBucketRequestPayment).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/accelerate", getBucketAccelerate).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/accelerate", putBucketAccelerate).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/download-compression", getDownloadCompression).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/download-compression", putDownloadCompression).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/inventory-reports", listInventoryReports).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/inventory-reports/{inventoryId}", putInventoryReport).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/inventory-reports/{inventoryId}", deleteInventoryReport).Methods("DELETE")
+	api.HandleFunc("/buckets/{bucketName}/inventory-reports/{inventoryId}/latest", getLatestInventoryDelivery).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/replication", getBucketReplication).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/replication", putBucketReplication).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/replication", deleteBucketReplication).Methods("DELETE")
+	api.HandleFunc("/buckets/{bucketName}/cors", getBucketCORS).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/cors", putBucketCORS).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/cors", deleteBucketCORS).Methods("DELETE")
+	api.HandleFunc("/buckets/{bucketName}/acl", getBucketACL).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/acl", putBucketACL).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/policy", getBucketPolicy).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/policy", putBucketPolicy).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/policy", deleteBucketPolicy).Methods("DELETE")
+	api.HandleFunc("/buckets/{bucketName}/policy/simulate", simulateBucketPolicy).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/recommendations", idempotent(createRecommendations)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/analytics/storage-classes", idempotent(createStorageClassReport)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/duplicates", idempotent(createDuplicateReport)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/lifecycle", getBucketLifecycle).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/lifecycle", putBucketLifecycle).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/lifecycle", deleteBucketLifecycle).Methods("DELETE")
+	api.HandleFunc("/buckets/{bucketName}/lifecycle/simulate", simulateLifecycle).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/inventory.csv", exportInventory).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/metadata/export", exportMetadata).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/metadata/import", idempotent(importMetadata)).Methods("POST")
 	api.HandleFunc("/buckets/{bucketName}/objects", listObjects).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/watch", watchBucket).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/stats", getBucketStats).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/growth", getBucketGrowth).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/cost", getCostEstimate).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/search", searchObjects).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/versions", listVersions).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/version-summary", listVersionSummary).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/undelete", idempotent(undeletePrefix)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/versions/purge", idempotent(purgeVersions)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/legal-holds", listLegalHoldRequests).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/legal-holds", createLegalHoldRequest).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/legal-holds/{requestId}/approve", approveLegalHoldRequest).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/legal-holds/{requestId}/reject", rejectLegalHoldRequest).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/operations", listOperations).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/operations/{operationId}", cancelOperation).Methods("DELETE")
+	// Sub-resources of an object can also be selected with ?action=, which
+	// reaches them for keys ending in a sub-resource name. ?action=object
+	// addresses such a key itself.
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", presignObject).Methods("GET").Queries("action", "presign")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", getObjectMetadata).Methods("GET").Queries("action", "metadata")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", updateObjectMetadata).Methods("PUT").Queries("action", "metadata")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", getObjectTags).Methods("GET").Queries("action", "tags")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", putObjectTags).Methods("PUT").Queries("action", "tags")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", deleteObjectTags).Methods("DELETE").Queries("action", "tags")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", getObjectRetention).Methods("GET").Queries("action", "retention")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", putObjectRetention).Methods("PUT").Queries("action", "retention")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", getObjectLegalHold).Methods("GET").Queries("action", "legal-hold")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", putObjectLegalHold).Methods("PUT").Queries("action", "legal-hold")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", previewObject).Methods("GET").Queries("action", "preview")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", getThumbnail).Methods("GET").Queries("action", "thumbnail")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", previewTable).Methods("GET").Queries("action", "table")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", getObjectContent).Methods("GET").Queries("action", "content")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", putObjectContent).Methods("PUT").Queries("action", "content")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", undeleteObject).Methods("POST").Queries("action", "undelete")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", downloadObject).Methods("GET").Queries("action", "object")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", deleteObject).Methods("DELETE").Queries("action", "object")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/presign", presignObject).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/metadata", getObjectMetadata).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/metadata", updateObjectMetadata).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/tags", getObjectTags).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/tags", putObjectTags).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/tags", deleteObjectTags).Methods("DELETE")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/retention", getObjectRetention).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/retention", putObjectRetention).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/legal-hold", getObjectLegalHold).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/legal-hold", putObjectLegalHold).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/preview", previewObject).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/thumbnail", getThumbnail).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/table", previewTable).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/content", getObjectContent).Methods("GET")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/content", putObjectContent).Methods("PUT")
+	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}/undelete", undeleteObject).Methods("POST")
 	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", downloadObject).Methods("GET")
-	api.HandleFunc("/buckets/{bucketName}/objects", uploadObject).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/objects", idempotent(uploadObject)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/objects/delete", idempotent(batchDeleteObjects)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/objects/move", idempotent(renameObject)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/batch", idempotent(runBatchOperations)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/batch/preview", previewBatchOperations).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/fetch", idempotent(fetchObject)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/manifest", createManifest).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/checksums", idempotent(createChecksums)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/objects/rename/preview", previewBatchRename).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/objects/rename", idempotent(batchRename)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/objects/copy", idempotent(batchCopyObjects)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/objects/compose", idempotent(composeObjects)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/objects/split", idempotent(splitObject)).Methods("POST")
 	api.HandleFunc("/buckets/{bucketName}/objects/{objectKey:.+}", deleteObject).Methods("DELETE")
+	api.HandleFunc("/buckets/{bucketName}/folders/rename", idempotent(renameFolder)).Methods("POST")
+	api.HandleFunc("/buckets/{bucketName}/folders/archive", idempotent(prepareArchive)).Methods("POST")
 	api.HandleFunc("/buckets/{bucketName}/folders/{folderPrefix:.+}", deleteFolder).Methods("DELETE")
 	api.HandleFunc("/buckets/{bucketName}/folders/{folderPrefix:.+}", downloadFolder).Methods("GET").Queries("download", "true")
+	api.HandleFunc("/searches", listSavedSearches).Methods("GET")
+	api.HandleFunc("/searches", idempotent(createSavedSearch)).Methods("POST")
+	api.HandleFunc("/searches/{searchId}", getSavedSearch).Methods("GET")
+	api.HandleFunc("/searches/{searchId}", updateSavedSearch).Methods("PUT")
+	api.HandleFunc("/searches/{searchId}", deleteSavedSearch).Methods("DELETE")
+	api.HandleFunc("/searches/{searchId}/run", runSavedSearch).Methods("GET")
+	api.HandleFunc("/stats-scans", listStatsScans).Methods("GET")
+	api.HandleFunc("/stats-scans", idempotent(createStatsScan)).Methods("POST")
+	api.HandleFunc("/stats-scans/{scanId}/requeue", requeueStatsScan).Methods("POST")
+	api.HandleFunc("/stats-scans/{scanId}", cancelStatsScan).Methods("DELETE")
+	api.HandleFunc("/credentials", getCredentialStatus).Methods("GET")
+	api.HandleFunc("/credentials/rotate", rotateCredentials).Methods("POST")
+	api.HandleFunc("/credentials/rollback", rollbackCredentials).Methods("POST")
+	api.HandleFunc("/events", streamEvents).Methods("GET")
+	api.HandleFunc("/sync", idempotent(createSync)).Methods("POST")
+	api.HandleFunc("/schedules", listSchedules).Methods("GET")
+	api.HandleFunc("/schedules", createSchedule).Methods("POST")
+	api.HandleFunc("/schedules/{scheduleId}", getSchedule).Methods("GET")
+	api.HandleFunc("/schedules/{scheduleId}", updateSchedule).Methods("PUT")
+	api.HandleFunc("/schedules/{scheduleId}", deleteSchedule).Methods("DELETE")
+	api.HandleFunc("/schedules/{scheduleId}/run", idempotent(triggerSchedule)).Methods("POST")
+	api.HandleFunc("/jobs", listJobs).Methods("GET")
+	api.HandleFunc("/jobs/{jobId}", getJob).Methods("GET")
+	api.HandleFunc("/jobs/{jobId}", cancelJob).Methods("DELETE")
+	api.HandleFunc("/jobs/{jobId}/events", streamJobEvents).Methods("GET")
+	api.HandleFunc("/jobs/{jobId}/archive", downloadArchive).Methods("GET")
+	api.HandleFunc("/jobs/{jobId}/checksums", downloadChecksums).Methods("GET")
+	api.HandleFunc("/shares", listShares).Methods("GET")
+	api.HandleFunc("/shares", createShare).Methods("POST")
+	api.HandleFunc("/shares/{shareId}/rotate", rotateShare).Methods("POST")
+	api.HandleFunc("/shares/{shareId}", deleteShare).Methods("DELETE")
+	api.HandleFunc("/public/shares/{token}", downloadShare).Methods("GET")
+	api.HandleFunc("/public-indexes", listPublicIndexes).Methods("GET")
+	api.HandleFunc("/public-indexes", createPublicIndex).Methods("POST")
+	api.HandleFunc("/public-indexes/{name}", deletePublicIndex).Methods("DELETE")
+	api.HandleFunc("/public/indexes/{name}", browsePublicIndex).Methods("GET")
+	api.HandleFunc("/public/indexes/{name}/files/{filePath:.+}", downloadPublicIndexFile).Methods("GET")
+	api.HandleFunc("/links", createDeepLink).Methods("POST")
+	api.HandleFunc("/links/{token}", resolveDeepLink).Methods("GET")
+	api.HandleFunc("/inboxes", listInboxes).Methods("GET")
+	api.HandleFunc("/inboxes", createInbox).Methods("POST")
+	api.HandleFunc("/inboxes/{inboxId}", deleteInbox).Methods("DELETE")
+	api.HandleFunc("/transfers", listTransfers).Methods("GET")
+	api.HandleFunc("/transfers", idempotent(createTransfer)).Methods("POST")
+	api.HandleFunc("/transfers/{transferId}", deleteTransfer).Methods("DELETE")
+	api.HandleFunc("/public/inboxes/{token}", getPublicInbox).Methods("GET")
+	api.HandleFunc("/public/inboxes/{token}", uploadToInbox).Methods("POST")
+
+	if appConfig.Server.StaticDir != "" {
+		spa := spaHandler{staticPath: appConfig.Server.StaticDir, indexPath: "index.html"}
+		root.PathPrefix("/").Handler(http.StripPrefix(basePath, spa))
+	}
 
 	// CORS middleware
 	cOrigins := handlers.AllowedOrigins([]string{"*"})
-	cHeaders := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
+	cHeaders := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", idempotencyHeader, clientVersionHeader, debugHeader})
+	cExposed := handlers.ExposedHeaders([]string{versionHeader, "X-Cache", "Age"})
 	cMethods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"})
 
+	handler, err := withAccessLog(handlers.CORS(cOrigins, cHeaders, cMethods, cExposed)(r))
+	if err != nil {
+		log.Fatalf("failed to set up access log: %v", err)
+	}
+
 	log.Println("Starting server on :8081")
-	if err := http.ListenAndServe(":8081", handlers.CORS(cOrigins, cHeaders, cMethods)(r)); err != nil {
+	if err := http.ListenAndServe(":8081", handler); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -124,38 +493,71 @@ func main() {
 func createBucket(w http.ResponseWriter, r *http.Request) {
 	var data map[string]string
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
 		return
 	}
 
 	bucketName, ok := data["bucketName"]
 	if !ok || bucketName == "" {
-		http.Error(w, "Bucket name is required", http.StatusBadRequest)
+		httpError(w, r, http.StatusBadRequest, "bucket_name_required")
 		return
 	}
 
-	_, err := s3Client.CreateBucket(context.TODO(), &s3.CreateBucketInput{
-		Bucket: aws.String(bucketName),
-		CreateBucketConfiguration: &types.CreateBucketConfiguration{
-			LocationConstraint: types.BucketLocationConstraint(awsRegion),
-		},
-	})
+	// An explicit region creates the bucket there with the same credentials
+	region := awsRegion
+	var options []func(*s3.Options)
+	if data["region"] != "" && data["region"] != awsRegion {
+		if !regionPattern.MatchString(data["region"]) {
+			httpError(w, r, http.StatusBadRequest, "region_invalid", data["region"])
+			return
+		}
+		if appConfig.AWS.Endpoint != "" {
+			httpError(w, r, http.StatusBadRequest, "region_unsupported")
+			return
+		}
+		region = data["region"]
+		options = append(options, inRegion(region))
+	}
+
+	_, err := s3Client.CreateBucket(r.Context(), &s3.CreateBucketInput{
+		Bucket:                    aws.String(bucketName),
+		CreateBucketConfiguration: bucketConfiguration(region),
+	}, options...)
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create bucket: %s", err), http.StatusInternalServerError)
+		httpError(w, r, http.StatusInternalServerError, "create_bucket_failed", err)
 		return
 	}
+	regions.remember(bucketName, region)
 
 	w.WriteHeader(http.StatusCreated)
 }
 
 func listBuckets(w http.ResponseWriter, r *http.Request) {
-	result, err := s3Client.ListBuckets(context.TODO(), &s3.ListBucketsInput{})
+	maxAge, apiErr := listingMaxAge(r.URL.Query())
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	if buckets, age, ok := bucketListings.Get("", "", maxAge); ok {
+		writeListingCacheHeaders(w, true, age)
+		json.NewEncoder(w).Encode(buckets)
+		return
+	}
+
+	result, err := s3Client.ListBuckets(r.Context(), &s3.ListBucketsInput{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list buckets: %s", err), http.StatusInternalServerError)
+		httpError(w, r, http.StatusInternalServerError, "list_buckets_failed", err)
 		return
 	}
 
+	if appConfig.AWS.AutoDiscover {
+		annotateBucketRegions(r.Context(), result.Buckets)
+	}
+	bucketListings.Put("", "", result.Buckets)
+
+	writeListingCacheHeaders(w, false, 0)
 	json.NewEncoder(w).Encode(result.Buckets)
 }
 
@@ -169,22 +571,40 @@ func listObjects(w http.ResponseWriter, r *http.Request) {
 		prefix += "/"
 	}
 
-	input := &s3.ListObjectsV2Input{
-		Bucket:    aws.String(bucketName),
-		Prefix:    aws.String(prefix),
-		Delimiter: aws.String("/"),
+	filter, apiErr := parseObjectFilter(r.URL.Query())
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
 	}
-
-	result, err := s3Client.ListObjectsV2(context.TODO(), input)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list objects: %s", err), http.StatusInternalServerError)
+	maxAge, apiErr := listingMaxAge(r.URL.Query())
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
 		return
 	}
 
+	result, age, cached := objectListings.Get(bucketName, prefix, maxAge)
+	if !cached {
+		input := &s3.ListObjectsV2Input{
+			Bucket:       aws.String(bucketName),
+			Prefix:       aws.String(prefix),
+			Delimiter:    aws.String("/"),
+			RequestPayer: requestPayer(r.Context(), bucketName),
+		}
+
+		var err error
+		result, err = bucketClient(r.Context(), bucketName).ListObjectsV2(r.Context(), input)
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "list_objects_failed", err)
+			return
+		}
+		objectListings.Put(bucketName, prefix, result)
+	}
+	writeListingCacheHeaders(w, cached, age)
+
 	var objects []interface{}
 	for _, obj := range result.Contents {
 		// Do not include the folder itself in the list of objects
-		if *obj.Key == prefix {
+		if *obj.Key == prefix || !filter.Matches(obj) {
 			continue
 		}
 		objects = append(objects, obj)
@@ -204,7 +624,7 @@ func uploadObject(w http.ResponseWriter, r *http.Request) {
 
 	file, handler, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "Failed to get file from form", http.StatusBadRequest)
+		httpError(w, r, http.StatusBadRequest, "file_missing")
 		return
 	}
 	defer file.Close()
@@ -218,17 +638,74 @@ func uploadObject(w http.ResponseWriter, r *http.Request) {
 
 	key = path.Clean(key)
 
-	_, err = s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+	encryption, apiErr := parseEncryption(r.FormValue("serverSideEncryption"), r.FormValue("sseKmsKeyId"))
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	conflict := r.FormValue("conflict")
+	if conflict == "" {
+		conflict = conflictOverwrite
+	}
+	if !isKnownValue(conflict, conflictPolicies) {
+		httpError(w, r, http.StatusBadRequest, "conflict_policy_invalid", conflict)
+		return
+	}
+
+	ctx, done := operations.Begin(r.Context(), bucketName, "upload", key, "")
+	defer done()
+
+	key, err = resolveUploadKey(ctx, bucketName, key, conflict)
+	if errors.Is(err, errObjectExists) {
+		httpError(w, r, http.StatusConflict, "object_exists", handler.Filename)
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "upload_failed", err)
+		return
+	}
+
+	if appConfig.Uploads.Dedup {
+		deduplicated, err := dedupUpload(ctx, bucketName, key, file, encryption)
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "upload_failed", err)
+			return
+		}
+
+		publishObjectEvent(ObjectCreated, bucketName, key)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"key":          key,
+			"deduplicated": deduplicated,
+		})
+		return
+	}
+
+	input := &s3.PutObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(key),
 		Body:   file,
-	})
+	}
+	encryption.applyPut(input)
+	// Guards against an upload to the same key racing the check above
+	if conflict != conflictOverwrite {
+		input.IfNoneMatch = aws.String("*")
+	}
+
+	_, err = transferClient(ctx, bucketName).PutObject(ctx, input)
+	if isPreconditionFailed(err) {
+		httpError(w, r, http.StatusConflict, "object_exists", handler.Filename)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to upload file: %s", err), http.StatusInternalServerError)
+		httpError(w, r, http.StatusInternalServerError, "upload_failed", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	publishObjectEvent(ObjectCreated, bucketName, key)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"key": key})
 }
 
 func downloadObject(w http.ResponseWriter, r *http.Request) {
@@ -236,19 +713,11 @@ func downloadObject(w http.ResponseWriter, r *http.Request) {
 	bucketName := vars["bucketName"]
 	objectKey := vars["objectKey"]
 
-	result, err := s3Client.GetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
-	})
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to download file: %s", err), http.StatusInternalServerError)
-		return
-	}
-	defer result.Body.Close()
-
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", objectKey))
-	w.Header().Set("Content-Type", "application/octet-stream")
-	io.Copy(w, result.Body)
+	streamObject(w, r, &s3.GetObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(objectKey),
+		VersionId: optionalVersionID(r),
+	}, attachmentDisposition(path.Base(objectKey)))
 }
 
 func deleteObject(w http.ResponseWriter, r *http.Request) {
@@ -256,15 +725,30 @@ func deleteObject(w http.ResponseWriter, r *http.Request) {
 	bucketName := vars["bucketName"]
 	objectKey := vars["objectKey"]
 
-	_, err := s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+	ctx, done := operations.Begin(r.Context(), bucketName, "delete", objectKey, "")
+	defer done()
+
+	// Deleting a specific version removes it permanently instead of adding a delete marker
+	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(objectKey),
-	})
+	}
+	versionID := r.URL.Query().Get("versionId")
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	_, err := bucketClient(ctx, bucketName).DeleteObject(ctx, input)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete file: %s", err), http.StatusInternalServerError)
+		httpError(w, r, http.StatusInternalServerError, "delete_file_failed", err)
 		return
 	}
 
+	if versionID != "" {
+		audit.Record(r, "object.version.delete", bucketName, objectKey, map[string]interface{}{"versionId": versionID})
+	}
+	publishObjectEvent(ObjectDeleted, bucketName, objectKey)
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -273,14 +757,32 @@ func deleteFolder(w http.ResponseWriter, r *http.Request) {
 	bucketName := vars["bucketName"]
 	folderPrefix := vars["folderPrefix"]
 
+	// Large folders take a while, as a job the progress can be followed
+	if r.URL.Query().Get("async") == "true" {
+		job, err := startBucketJob(bucketName, "delete-folder", folderPrefix, func(ctx context.Context, job *jobHandle) error {
+			defer publishObjectEvent(ObjectDeleted, bucketName, folderPrefix)
+			return deleteFolderInBatches(ctx, job, bucketName, folderPrefix)
+		})
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+			return
+		}
+
+		writeJobAccepted(w, job)
+		return
+	}
+
+	ctx, done := operations.Begin(r.Context(), bucketName, "delete-folder", folderPrefix, "")
+	defer done()
+
 	// List all objects from the folder
 	listObjectsInput := &s3.ListObjectsV2Input{
 		Bucket: aws.String(bucketName),
 		Prefix: aws.String(folderPrefix),
 	}
-	listedObjects, err := s3Client.ListObjectsV2(context.TODO(), listObjectsInput)
+	listedObjects, err := bucketClient(ctx, bucketName).ListObjectsV2(ctx, listObjectsInput)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list objects for deletion: %s", err), http.StatusInternalServerError)
+		httpError(w, r, http.StatusInternalServerError, "list_objects_deletion_failed", err)
 		return
 	}
 
@@ -296,13 +798,15 @@ func deleteFolder(w http.ResponseWriter, r *http.Request) {
 			Bucket: aws.String(bucketName),
 			Delete: &types.Delete{Objects: objectsToDelete},
 		}
-		_, err = s3Client.DeleteObjects(context.TODO(), deleteObjectsInput)
+		_, err = bucketClient(ctx, bucketName).DeleteObjects(ctx, deleteObjectsInput)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to delete objects: %s", err), http.StatusInternalServerError)
+			httpError(w, r, http.StatusInternalServerError, "delete_objects_failed", err)
 			return
 		}
 	}
 
+	publishObjectEvent(ObjectDeleted, bucketName, folderPrefix)
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -320,9 +824,9 @@ func downloadFolder(w http.ResponseWriter, r *http.Request) {
 		Bucket: aws.String(bucketName),
 		Prefix: aws.String(folderPrefix),
 	}
-	listedObjects, err := s3Client.ListObjectsV2(context.TODO(), listObjectsInput)
+	listedObjects, err := bucketClient(r.Context(), bucketName).ListObjectsV2(r.Context(), listObjectsInput)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list objects for download: %s", err), http.StatusInternalServerError)
+		httpError(w, r, http.StatusInternalServerError, "list_objects_download_failed", err)
 		return
 	}
 
@@ -338,9 +842,9 @@ func downloadFolder(w http.ResponseWriter, r *http.Request) {
 			Bucket: aws.String(bucketName),
 			Key:    object.Key,
 		}
-		getObjectOutput, err := s3Client.GetObject(context.TODO(), getObjectInput)
+		getObjectOutput, err := bucketClient(r.Context(), bucketName).GetObject(r.Context(), getObjectInput)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get object %s: %s", *object.Key, err), http.StatusInternalServerError)
+			httpError(w, r, http.StatusInternalServerError, "get_object_failed", *object.Key, err)
 			return
 		}
 		defer getObjectOutput.Body.Close()
@@ -348,14 +852,14 @@ func downloadFolder(w http.ResponseWriter, r *http.Request) {
 		// Create a new file in the zip archive
 		zipFile, err := zipWriter.Create(*object.Key)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create zip file for %s: %s", *object.Key, err), http.StatusInternalServerError)
+			httpError(w, r, http.StatusInternalServerError, "zip_create_failed", *object.Key, err)
 			return
 		}
 
 		// Copy the object content to the zip file
 		_, err = io.Copy(zipFile, getObjectOutput.Body)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to copy object %s to zip: %s", *object.Key, err), http.StatusInternalServerError)
+			httpError(w, r, http.StatusInternalServerError, "zip_copy_failed", *object.Key, err)
 			return
 		}
 	}
@@ -369,9 +873,9 @@ func deleteBucket(w http.ResponseWriter, r *http.Request) {
 	listObjectsInput := &s3.ListObjectsV2Input{
 		Bucket: aws.String(bucketName),
 	}
-	listedObjects, err := s3Client.ListObjectsV2(context.TODO(), listObjectsInput)
+	listedObjects, err := bucketClient(r.Context(), bucketName).ListObjectsV2(r.Context(), listObjectsInput)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list objects for deletion: %s", err), http.StatusInternalServerError)
+		httpError(w, r, http.StatusInternalServerError, "list_objects_deletion_failed", err)
 		return
 	}
 
@@ -387,22 +891,23 @@ func deleteBucket(w http.ResponseWriter, r *http.Request) {
 			Bucket: aws.String(bucketName),
 			Delete: &types.Delete{Objects: objectsToDelete},
 		}
-		_, err = s3Client.DeleteObjects(context.TODO(), deleteObjectsInput)
+		_, err = bucketClient(r.Context(), bucketName).DeleteObjects(r.Context(), deleteObjectsInput)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to delete objects: %s", err), http.StatusInternalServerError)
+			httpError(w, r, http.StatusInternalServerError, "delete_objects_failed", err)
 			return
 		}
 	}
 
 	// Delete the bucket
-	_, err = s3Client.DeleteBucket(context.TODO(), &s3.DeleteBucketInput{
+	_, err = bucketClient(r.Context(), bucketName).DeleteBucket(r.Context(), &s3.DeleteBucketInput{
 		Bucket: aws.String(bucketName),
 	})
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete bucket: %s", err), http.StatusInternalServerError)
+		httpError(w, r, http.StatusInternalServerError, "delete_bucket_failed", err)
 		return
 	}
+	regions.forget(bucketName)
 
 	w.WriteHeader(http.StatusOK)
 }
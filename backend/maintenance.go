@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxMaintenanceMessageLength = 500
+
+var maintenance *documentStore[Maintenance]
+
+// Maintenance blocks mutating API calls, or all of them with BlockReads, while
+// it is enabled or while the scheduled window is open.
+type Maintenance struct {
+	Enabled     bool       `json:"enabled"`
+	Message     string     `json:"message,omitempty"`
+	BlockReads  bool       `json:"blockReads"`
+	WindowStart *time.Time `json:"windowStart,omitempty"`
+	WindowEnd   *time.Time `json:"windowEnd,omitempty"`
+}
+
+func (m *Maintenance) Validate() *apiError {
+	if len(m.Message) > maxMaintenanceMessageLength {
+		return newAPIError("maintenance_message_too_long", maxMaintenanceMessageLength)
+	}
+	if m.WindowEnd != nil && m.WindowStart == nil {
+		return newAPIError("maintenance_window_start_required")
+	}
+	if m.WindowStart != nil && m.WindowEnd != nil && !m.WindowEnd.After(*m.WindowStart) {
+		return newAPIError("maintenance_window_invalid")
+	}
+
+	return nil
+}
+
+func (m *Maintenance) Active(now time.Time) bool {
+	if m.Enabled {
+		return true
+	}
+	if m.WindowStart == nil || now.Before(*m.WindowStart) {
+		return false
+	}
+
+	return m.WindowEnd == nil || now.Before(*m.WindowEnd)
+}
+
+func newMaintenanceStore(dataDir string) (*documentStore[Maintenance], error) {
+	return newDocumentStore(dataDir, "maintenance.json", Maintenance{})
+}
+
+// maintenanceExempt lists the API paths that keep working during maintenance
+// so the SPA can explain what is going on and admins can end it.
+var maintenanceExempt = []string{"/maintenance", "/version", "/features", "/settings/branding"}
+
+func maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := maintenance.Get()
+		now := time.Now()
+
+		if !state.Active(now) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		apiPath := strings.TrimPrefix(r.URL.Path, appConfig.Server.BasePath+"/api")
+		if isKnownValue(apiPath, maintenanceExempt) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		if readOnly && !state.BlockReads {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if state.WindowEnd != nil && state.WindowEnd.After(now) && !state.Enabled {
+			seconds := int(math.Ceil(state.WindowEnd.Sub(now).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		}
+
+		if state.Message != "" {
+			httpError(w, r, http.StatusServiceUnavailable, "maintenance_announced", state.Message)
+			return
+		}
+		httpError(w, r, http.StatusServiceUnavailable, "maintenance_active")
+	})
+}
+
+func getMaintenance(w http.ResponseWriter, r *http.Request) {
+	state := maintenance.Get()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"maintenance": state,
+		"active":      state.Active(time.Now()),
+	})
+}
+
+func putMaintenance(w http.ResponseWriter, r *http.Request) {
+	var data Maintenance
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if apiErr := data.Validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	if err := maintenance.Set(data); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "maintenance_save_failed", err)
+		return
+	}
+
+	audit.Record(r, "maintenance.update", "", "", map[string]interface{}{
+		"enabled":     data.Enabled,
+		"blockReads":  data.BlockReads,
+		"windowStart": data.WindowStart,
+		"windowEnd":   data.WindowEnd,
+	})
+
+	getMaintenance(w, r)
+}
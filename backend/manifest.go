@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// Manifests are built in memory before they are sent
+const maxManifestObjects = 100000
+
+var errManifestTooLarge = errors.New("too many objects for a manifest")
+
+type ManifestEntry struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size,omitempty"`
+	URL  string `json:"url"`
+}
+
+type Manifest struct {
+	BucketName string          `json:"bucketName"`
+	ExpiresAt  time.Time       `json:"expiresAt"`
+	Objects    []ManifestEntry `json:"objects"`
+}
+
+func buildManifest(ctx context.Context, bucketName, prefix string, keys []string, expiry time.Duration) (*Manifest, error) {
+	manifest := &Manifest{
+		BucketName: bucketName,
+		ExpiresAt:  time.Now().Add(expiry).UTC(),
+		Objects:    []ManifestEntry{},
+	}
+
+	// URLs for accelerated or requester pays buckets need to say so themselves
+	presignClient := s3.NewPresignClient(transferClient(ctx, bucketName))
+	payer := requestPayer(ctx, bucketName)
+
+	add := func(key string, size int64) error {
+		if len(manifest.Objects) == maxManifestObjects {
+			return errManifestTooLarge
+		}
+
+		request, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket:       aws.String(bucketName),
+			Key:          aws.String(key),
+			RequestPayer: payer,
+		}, s3.WithPresignExpires(expiry))
+		if err != nil {
+			return err
+		}
+
+		manifest.Objects = append(manifest.Objects, ManifestEntry{Key: key, Size: size, URL: request.URL})
+		return nil
+	}
+
+	if len(keys) > 0 {
+		for _, key := range keys {
+			if err := add(key, 0); err != nil {
+				return nil, err
+			}
+		}
+		return manifest, nil
+	}
+
+	err := walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		return add(aws.ToString(obj.Key), aws.ToInt64(obj.Size))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func writeManifestCSV(w http.ResponseWriter, manifest *Manifest) {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"key", "size", "url"})
+	for _, entry := range manifest.Objects {
+		writer.Write([]string{entry.Key, strconv.FormatInt(entry.Size, 10), entry.URL})
+	}
+	writer.Flush()
+}
+
+// createManifest answers with a file of presigned GET URLs for a prefix or a
+// selection of keys, so other systems can fetch a dataset without the backend
+// proxying it
+func createManifest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Prefix  string   `json:"prefix"`
+		Keys    []string `json:"keys"`
+		Expires int      `json:"expires"`
+		Format  string   `json:"format"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.Format == "" {
+		data.Format = "csv"
+	}
+	if data.Format != "csv" && data.Format != "json" {
+		httpError(w, r, http.StatusBadRequest, "manifest_format_invalid", data.Format)
+		return
+	}
+	if len(data.Keys) > 0 && data.Prefix != "" {
+		httpError(w, r, http.StatusBadRequest, "manifest_selection_invalid")
+		return
+	}
+
+	expires := ""
+	if data.Expires != 0 {
+		expires = strconv.Itoa(data.Expires)
+	}
+	expiry, apiErr := parsePresignExpiry(expires)
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	manifest, err := buildManifest(r.Context(), bucketName, data.Prefix, data.Keys, expiry)
+	if errors.Is(err, errManifestTooLarge) {
+		httpError(w, r, http.StatusBadRequest, "manifest_too_large", maxManifestObjects)
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "presign_failed", err)
+		return
+	}
+
+	audit.Record(r, "manifest.create", bucketName, data.Prefix, map[string]interface{}{
+		"objects":   len(manifest.Objects),
+		"expiresAt": manifest.ExpiresAt,
+	})
+
+	fileName := bucketName
+	if data.Prefix != "" {
+		fileName = path.Base(path.Clean(data.Prefix))
+	}
+	w.Header().Set("Content-Disposition", attachmentDisposition(fileName+"-manifest."+data.Format))
+
+	if data.Format == "json" {
+		json.NewEncoder(w).Encode(manifest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	writeManifestCSV(w, manifest)
+}
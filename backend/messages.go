@@ -0,0 +1,707 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultLanguage = "en"
+
+// messages maps a language to the user-facing texts of all message codes.
+// Every code must exist in the default language; other languages may omit
+// codes and fall back to it.
+var messages = map[string]map[string]string{
+	"en": {
+		"invalid_request_body":               "Invalid request body",
+		"bucket_name_required":               "Bucket name is required",
+		"bucket_and_key_required":            "Bucket name and object key are required",
+		"expires_invalid":                    "expires must be a positive number of seconds",
+		"expires_too_long":                   "expires must not exceed %d seconds",
+		"file_missing":                       "Failed to get file from form",
+		"create_bucket_failed":               "Failed to create bucket: %s",
+		"list_buckets_failed":                "Failed to list buckets: %s",
+		"delete_bucket_failed":               "Failed to delete bucket: %s",
+		"list_objects_failed":                "Failed to list objects: %s",
+		"list_objects_deletion_failed":       "Failed to list objects for deletion: %s",
+		"list_objects_download_failed":       "Failed to list objects for download: %s",
+		"upload_failed":                      "Failed to upload file: %s",
+		"download_failed":                    "Failed to download file: %s",
+		"delete_file_failed":                 "Failed to delete file: %s",
+		"delete_objects_failed":              "Failed to delete objects: %s",
+		"get_object_failed":                  "Failed to get object %s: %s",
+		"zip_create_failed":                  "Failed to create zip file for %s: %s",
+		"zip_copy_failed":                    "Failed to copy object %s to zip: %s",
+		"presign_failed":                     "Failed to presign object: %s",
+		"object_not_found":                   "Failed to find object: %s",
+		"share_create_failed":                "Failed to create share: %s",
+		"share_rotate_failed":                "Failed to rotate share: %s",
+		"share_delete_failed":                "Failed to delete share: %s",
+		"share_not_found":                    "Share not found",
+		"share_expired":                      "Share has expired",
+		"move_keys_required":                 "Source and destination key are required",
+		"move_destination_is_folder":         "Destination key must not end with a slash",
+		"move_same_location":                 "Source and destination are identical",
+		"move_cross_region":                  "Cannot move objects between regions (%s to %s)",
+		"move_destination_exists":            "Destination %s already exists",
+		"move_failed":                        "Failed to move object: %s",
+		"bucket_region_failed":               "Failed to determine region of bucket %s: %s",
+		"keys_required":                      "At least one key is required",
+		"job_start_failed":                   "Failed to start job: %s",
+		"job_not_found":                      "Job not found",
+		"compose_keys_required":              "Source keys and destination key are required",
+		"compose_too_many_sources":           "At most %d source objects can be composed",
+		"compose_destination_is_source":      "Destination key must not be one of the source keys",
+		"compose_failed":                     "Failed to compose objects: %s",
+		"split_source_required":              "Source key is required",
+		"split_chunk_size_invalid":           "chunkSize must be a positive number of bytes",
+		"split_too_many_chunks":              "Splitting would create %d chunks, at most %d are allowed",
+		"list_versions_failed":               "Failed to list object versions: %s",
+		"rename_prefixes_required":           "Source and destination prefix are required",
+		"rename_prefixes_overlap":            "Source and destination prefix must not contain each other",
+		"lifecycle_rule_no_action":           "Rule needs at least one expiration, transition or cleanup action",
+		"lifecycle_expiration_invalid":       "Expiration needs positive days or a date",
+		"lifecycle_transition_invalid":       "Transition needs positive days or a date",
+		"lifecycle_storage_class_invalid":    "Unknown storage class %q",
+		"policy_simulation_fields_required":  "Principal and action are required",
+		"get_policy_failed":                  "Failed to get bucket policy: %s",
+		"update_metadata_failed":             "Failed to update object metadata: %s",
+		"inbox_limits_required":              "expires, maxFileSize and maxFiles must be positive",
+		"inbox_create_failed":                "Failed to create inbox: %s",
+		"inbox_delete_failed":                "Failed to delete inbox: %s",
+		"inbox_not_found":                    "Inbox not found",
+		"inbox_expired":                      "Inbox has expired",
+		"inbox_full":                         "Inbox does not accept any more files",
+		"inbox_file_too_large":               "File exceeds the limit of %d bytes",
+		"inbox_file_exists":                  "A file named %s was already uploaded",
+		"inbox_scan_rejected":                "File was rejected by the scanner: %s",
+		"email_invalid":                      "Invalid email address %q",
+		"transfer_mail_not_configured":       "Transfer requests need smtp and server.public_url to be configured",
+		"transfer_create_failed":             "Failed to create transfer request: %s",
+		"transfer_mail_failed":               "Failed to send transfer request email: %s",
+		"transfer_not_found":                 "Transfer request not found",
+		"transfer_delete_failed":             "Failed to delete transfer request: %s",
+		"tags_too_many":                      "At most %d tags are allowed",
+		"tag_key_invalid":                    "Tag key %q must have between 1 and %d characters",
+		"tag_value_invalid":                  "Value of tag %q must not exceed %d characters",
+		"get_tags_failed":                    "Failed to get tags: %s",
+		"put_tags_failed":                    "Failed to update tags: %s",
+		"delete_tags_failed":                 "Failed to delete tags: %s",
+		"archive_prefix_required":            "Prefix is required",
+		"archive_not_ready":                  "Archive is not ready yet",
+		"object_not_deleted":                 "%s is not hidden by a delete marker",
+		"undelete_failed":                    "Failed to restore object: %s",
+		"idempotency_key_invalid":            "Idempotency-Key must not exceed %d characters",
+		"idempotency_key_reused":             "Idempotency-Key was already used for a different request",
+		"idempotency_key_in_progress":        "The original request for this Idempotency-Key did not complete, please retry",
+		"operation_not_found":                "Operation not found",
+		"client_version_mismatch":            "Client version %s does not match server version %s, please reload the page",
+		"lifecycle_noncurrent_days_invalid":  "Noncurrent version actions need positive noncurrent days",
+		"lifecycle_abort_days_invalid":       "Days to abort incomplete multipart uploads must not be negative",
+		"lifecycle_too_many_rules":           "A lifecycle configuration holds at most %d rules",
+		"lifecycle_rule_id_required":         "Every lifecycle rule needs an id",
+		"lifecycle_rule_id_duplicate":        "Lifecycle rule id %q is used more than once",
+		"get_lifecycle_failed":               "Failed to get lifecycle configuration: %s",
+		"put_lifecycle_failed":               "Failed to save lifecycle configuration: %s",
+		"delete_lifecycle_failed":            "Failed to delete lifecycle configuration: %s",
+		"policy_too_large":                   "Bucket policies must not exceed %d bytes",
+		"policy_invalid_json":                "Policy is not valid JSON: %s",
+		"policy_version_invalid":             "Unsupported policy version %q, use \"2012-10-17\"",
+		"policy_statements_required":         "Policy needs at least one statement",
+		"policy_effect_invalid":              "Statement #%d has invalid effect %q, use Allow or Deny",
+		"policy_principal_required":          "Statement #%d needs a Principal or NotPrincipal",
+		"policy_action_required":             "Statement #%d needs an Action or NotAction",
+		"policy_resource_required":           "Statement #%d needs a Resource or NotResource",
+		"policy_sid_duplicate":               "Statement id %q is used more than once",
+		"policy_resource_foreign":            "Resource %q does not belong to this bucket",
+		"policy_public_access":               "Allows access to everyone without conditions",
+		"policy_all_actions":                 "Allows all actions",
+		"policy_allow_with_not":              "Allow combined with NotAction or NotPrincipal grants more than it appears to",
+		"put_policy_failed":                  "Failed to save bucket policy: %s",
+		"delete_policy_failed":               "Failed to delete bucket policy: %s",
+		"acl_permission_invalid":             "Unknown permission %q",
+		"acl_grantee_incomplete":             "Grantee of type %s is missing its identifier",
+		"acl_grantee_type_invalid":           "Unknown grantee type %q",
+		"acl_canned_and_grants":              "Use either a canned ACL or explicit grants",
+		"acl_canned_invalid":                 "Unknown canned ACL %q",
+		"acl_owner_required":                 "Owner id is required when setting explicit grants",
+		"get_acl_failed":                     "Failed to get bucket ACL: %s",
+		"put_acl_failed":                     "Failed to save bucket ACL: %s",
+		"branding_name_required":             "Instance name is required",
+		"branding_name_too_long":             "Instance name must not exceed %d characters",
+		"branding_logo_invalid":              "Logo URL must be an http(s) URL or an absolute path",
+		"branding_color_invalid":             "Invalid color %q, use #rgb or #rrggbb",
+		"branding_theme_invalid":             "Unknown theme mode %q",
+		"branding_banner_invalid":            "Banner text must not be empty or exceed %d characters",
+		"branding_banner_severity_invalid":   "Unknown banner severity %q",
+		"branding_save_failed":               "Failed to save branding: %s",
+		"cors_origins_required":              "CORS rule needs at least one allowed origin",
+		"cors_origin_invalid":                "Invalid origin %q, at most one * is allowed",
+		"cors_methods_required":              "CORS rule needs at least one allowed method",
+		"cors_method_invalid":                "Method %q is not allowed, use GET, PUT, POST, DELETE or HEAD",
+		"cors_header_invalid":                "Invalid header %q, at most one * is allowed",
+		"cors_max_age_invalid":               "Max age must not be negative",
+		"cors_too_many_rules":                "A CORS configuration holds at most %d rules",
+		"get_cors_failed":                    "Failed to get CORS configuration: %s",
+		"put_cors_failed":                    "Failed to save CORS configuration: %s",
+		"delete_cors_failed":                 "Failed to delete CORS configuration: %s",
+		"maintenance_active":                 "The service is in maintenance mode, please try again later",
+		"maintenance_announced":              "Maintenance: %s",
+		"maintenance_message_too_long":       "Maintenance message must not exceed %d characters",
+		"maintenance_window_start_required":  "A maintenance window with an end needs a start",
+		"maintenance_window_invalid":         "Maintenance window must end after it starts",
+		"maintenance_save_failed":            "Failed to save maintenance settings: %s",
+		"encryption_algorithm_invalid":       "Unknown server-side encryption %q, use AES256, aws:kms or aws:kms:dsse",
+		"encryption_kms_key_without_kms":     "A KMS key id requires aws:kms or aws:kms:dsse encryption",
+		"cidr_invalid":                       "Invalid IP address or CIDR range %q",
+		"referer_pattern_invalid":            "Invalid referrer pattern %q",
+		"share_access_denied":                "This share cannot be accessed from here",
+		"bucket_frozen":                      "Bucket %s is frozen by %s: %s",
+		"bucket_not_frozen":                  "Bucket %s is not frozen",
+		"freeze_fields_required":             "frozenBy and reason are required",
+		"freeze_save_failed":                 "Failed to save bucket freeze: %s",
+		"get_object_lock_failed":             "Failed to get object lock configuration: %s",
+		"put_object_lock_failed":             "Failed to save object lock configuration: %s",
+		"object_lock_mode_invalid":           "Unknown retention mode %q, use GOVERNANCE or COMPLIANCE",
+		"object_lock_period_invalid":         "Default retention needs either positive days or positive years",
+		"retention_date_invalid":             "Retention date must be in the future",
+		"get_retention_failed":               "Failed to get object retention: %s",
+		"put_retention_failed":               "Failed to set object retention: %s",
+		"legal_hold_status_invalid":          "Unknown legal hold status %q, use ON or OFF",
+		"get_legal_hold_failed":              "Failed to get legal hold: %s",
+		"put_legal_hold_failed":              "Failed to set legal hold: %s",
+		"metadata_import_invalid":            "Invalid CSV file: %s",
+		"metadata_import_empty":              "The CSV file needs a header and at least one row",
+		"metadata_import_column_unknown":     "Unknown column %q",
+		"metadata_import_key_missing":        "The CSV file needs a key column",
+		"metadata_import_row_invalid":        "Line %d is invalid: %v",
+		"metadata_import_too_large":          "The CSV file must not exceed %d MB",
+		"get_request_payment_failed":         "Failed to get request payment configuration: %s",
+		"put_request_payment_failed":         "Failed to save request payment configuration: %s",
+		"payer_invalid":                      "Unknown payer %q, use BucketOwner or Requester",
+		"legal_hold_request_fields_required": "Requester and reason are required",
+		"legal_hold_selection_invalid":       "Select objects either by a list of keys or by prefix, patterns, filters or a saved search",
+		"legal_hold_too_many_keys":           "At most %d keys can be selected, use a prefix for larger selections",
+		"legal_hold_request_save_failed":     "Failed to save legal hold request: %s",
+		"legal_hold_decider_required":        "The name of the person deciding is required",
+		"legal_hold_request_not_found":       "Legal hold request not found",
+		"legal_hold_self_approval":           "Requests must be decided by someone other than the requester",
+		"legal_hold_request_decided":         "The legal hold request has already been decided",
+		"get_accelerate_failed":              "Failed to get transfer acceleration: %s",
+		"put_accelerate_failed":              "Failed to change transfer acceleration: %s",
+		"region_not_found":                   "Region %q is not configured",
+		"since_invalid":                      "Invalid time %q, expected RFC 3339",
+		"client_ip_denied":                   "Access from your network is not allowed",
+		"replication_role_invalid":           "Replication needs the ARN of an IAM role, got %q",
+		"replication_too_many_rules":         "At most %d replication rules are allowed",
+		"replication_rule_id_invalid":        "Replication rule IDs must be present and unique, got %q",
+		"replication_priority_duplicate":     "Priority %d is used by more than one rule",
+		"replication_destination_required":   "Rule %q needs a destination bucket",
+		"replication_storage_class_invalid":  "Unknown storage class %q",
+		"replication_account_required":       "Rule %q changes the replica owner and needs the destination account",
+		"get_versioning_failed":              "Failed to get versioning state: %s",
+		"replication_versioning_required":    "Versioning must be enabled on bucket %s before replication can be configured",
+		"get_replication_failed":             "Failed to get replication configuration: %s",
+		"put_replication_failed":             "Failed to save replication configuration: %s",
+		"delete_replication_failed":          "Failed to delete replication configuration: %s",
+		"conflict_policy_invalid":            "Unknown conflict policy %q, use overwrite, fail or keepBoth",
+		"object_exists":                      "%s already exists",
+		"rename_find_required":               "A pattern to find is required",
+		"rename_regex_invalid":               "Invalid regular expression: %s",
+		"rename_prefix_outside":              "The prefix to swap %q is not below %q",
+		"rename_mode_invalid":                "Unknown rename mode %q, use regex or prefix",
+		"rename_too_many":                    "At most %d keys can be renamed at once, narrow down the prefix",
+		"manifest_format_invalid":            "Unknown manifest format %q, use csv or json",
+		"manifest_selection_invalid":         "Select objects either by prefix or by a list of keys",
+		"manifest_too_large":                 "A manifest can list at most %d objects, narrow down the prefix",
+		"preview_too_large":                  "Objects larger than %d MB cannot be previewed, download them instead",
+		"checksum_manifest_name_invalid":     "The manifest name %q must not contain a slash",
+		"checksums_not_ready":                "The checksum manifest is not ready yet",
+		"thumbnail_size_invalid":             "Invalid thumbnail size %q",
+		"thumbnail_source_too_large":         "Images larger than %d MB get no thumbnail",
+		"thumbnail_unsupported":              "No thumbnail can be created for this object: %s",
+		"region_invalid":                     "Invalid region %q",
+		"region_unsupported":                 "Other regions can only be used with AWS, not with a custom endpoint",
+		"edit_too_large":                     "Objects larger than %d KB cannot be edited, download them instead",
+		"edit_not_text":                      "The object is not UTF-8 text and cannot be edited",
+		"edit_conflict":                      "The object was changed in the meantime, reload it before saving",
+		"table_rows_invalid":                 "The number of rows must be between 1 and %d",
+		"table_format_unsupported":           "Only CSV and TSV files can be shown as a table",
+		"table_parse_failed":                 "Failed to read the file as a table: %s",
+		"copy_option_invalid":                "Invalid value %q for the copy option %s",
+		"copy_acl_unsupported":               "The storage provider does not support object ACLs, they cannot be preserved",
+		"filter_size_invalid":                "Invalid %s %q, expected bytes or a size like 1GB",
+		"filter_time_invalid":                "Invalid %s %q, expected a date, an RFC 3339 timestamp or a number of days like 90d",
+		"search_limit_invalid":               "The limit must be between 1 and %d",
+		"search_query_required":              "Enter a search term, a pattern or an extension",
+		"metrics_unauthorized":               "A valid metrics token is required",
+		"saved_search_fields_required":       "A saved search needs a name and a bucket",
+		"saved_search_not_found":             "Saved search not found",
+		"saved_search_save_failed":           "Failed to save the search: %s",
+		"audit_verify_failed":                "Failed to verify the audit log: %s",
+		"download_compression_save_failed":   "Failed to save the download compression setting: %s",
+		"cache_ttl_invalid":                  "cacheTtl must be a non-negative number of seconds, got %q",
+		"growth_prefix_not_tracked":          "Prefix %q is not tracked, add it to growth.prefixes",
+		"public_index_name_invalid":          "Invalid name %q, use up to 63 lowercase letters, digits and dashes",
+		"public_index_exists":                "A public index named %q already exists",
+		"public_index_create_failed":         "Failed to create public index: %s",
+		"public_index_delete_failed":         "Failed to delete public index: %s",
+		"public_index_not_found":             "Public index not found",
+		"public_index_file_not_found":        "File %q not found",
+		"public_index_rate_limited":          "Too many requests, try again in a minute",
+		"public_index_list_failed":           "Failed to list files",
+		"stats_scan_failed":                  "Failed to queue bucket count: %s",
+		"stats_scan_not_found":               "Scan not found, it may have finished already",
+		"inventory_destination_required":     "The inventory needs a destination bucket",
+		"inventory_format_invalid":           "Unknown inventory format %q, expected CSV, ORC or Parquet",
+		"inventory_frequency_invalid":        "Unknown frequency %q, expected Daily or Weekly",
+		"inventory_versions_invalid":         "Unknown included versions %q, expected Current or All",
+		"inventory_field_invalid":            "Unknown optional field %q",
+		"get_inventory_failed":               "Failed to get inventory configuration: %s",
+		"put_inventory_failed":               "Failed to save inventory configuration: %s",
+		"delete_inventory_failed":            "Failed to delete inventory configuration: %s",
+		"inventory_not_found":                "Inventory configuration %q not found",
+		"inventory_not_delivered":            "Inventory %q has not been delivered yet, the first delivery can take up to 48 hours",
+		"inventory_read_failed":              "Failed to read inventory delivery: %s",
+		"credentials_required":               "Access key ID and secret access key are required",
+		"credentials_grace_invalid":          "Invalid grace period %d, it must not be negative",
+		"credentials_unchanged":              "This access key is already in use",
+		"credentials_invalid":                "The access key was rejected: %s",
+		"credentials_missing_buckets":        "The new access key cannot see %d buckets of the current one, rotate with force to switch anyway",
+		"credentials_no_previous":            "There is no previous access key to roll back to",
+		"credentials_state_failed":           "Failed to save the access key state: %v",
+		"job_timed_out":                      "The job was aborted after its timeout of %s",
+		"job_already_finished":               "The job has already finished with status %s",
+		"link_not_found":                     "Link not found",
+		"link_target_not_found":              "The bucket or object of this link no longer exists",
+		"link_target_forbidden":              "Access to the bucket or object of this link is denied",
+		"link_check_failed":                  "Failed to check the target of the link: %v",
+		"link_create_failed":                 "Failed to create link: %v",
+		"report_format_invalid":              "Unknown report format %q, use csv or json",
+		"websocket_required":                 "This endpoint only accepts WebSocket connections",
+		"websocket_version_unsupported":      "Unsupported WebSocket version, use 13",
+		"websocket_upgrade_failed":           "Failed to open the WebSocket connection: %v",
+		"sync_buckets_required":              "Source and destination bucket are required",
+		"sync_prefixes_overlap":              "Source and destination must not contain each other",
+		"sync_delete_failed":                 "Delete failed: %s %s",
+		"schedule_name_required":             "A schedule needs a name",
+		"schedule_cron_invalid":              "Invalid cron expression %q: %v",
+		"schedule_task_invalid":              "Unknown task %q, use one of %s",
+		"schedule_task_incomplete":           "The settings of the %s task are incomplete",
+		"schedule_purge_age_invalid":         "Invalid age %d, it must not be negative",
+		"schedule_id_invalid":                "Schedule IDs in the config must be set and unique, got %q",
+		"schedule_not_found":                 "Schedule not found",
+		"schedule_save_failed":               "Failed to save schedule: %v",
+		"schedule_from_config":               "Schedule %s is defined in the config and cannot be changed here",
+		"schedule_run_failed":                "The schedule could not be started: %s",
+		"batch_operations_required":          "No operations given",
+		"batch_too_many":                     "At most %d operations can run in one batch",
+		"batch_action_invalid":               "Unknown batch action %q",
+		"batch_key_required":                 "Every operation needs a key",
+		"batch_destination_required":         "Copies need a destination key",
+		"batch_copy_identical":               "An object cannot be copied onto itself",
+		"batch_object_unavailable":           "Object cannot be read: %v",
+		"fetch_url_invalid":                  "%q is not an http or https URL",
+		"fetch_key_required":                 "No object key given and none found in the URL",
+		"fetch_status_failed":                "Remote server answered %s",
+		"share_download_failed":              "The shared file could not be downloaded",
+		"idempotency_key_mismatch":           "Idempotency-Key was already used with a different request body",
+	},
+	"de": {
+		"invalid_request_body":               "Ungültiger Request-Body",
+		"bucket_name_required":               "Bucket-Name ist erforderlich",
+		"bucket_and_key_required":            "Bucket-Name und Objekt-Schlüssel sind erforderlich",
+		"expires_invalid":                    "expires muss eine positive Anzahl Sekunden sein",
+		"expires_too_long":                   "expires darf %d Sekunden nicht überschreiten",
+		"file_missing":                       "Datei konnte nicht aus dem Formular gelesen werden",
+		"create_bucket_failed":               "Bucket konnte nicht erstellt werden: %s",
+		"list_buckets_failed":                "Buckets konnten nicht aufgelistet werden: %s",
+		"delete_bucket_failed":               "Bucket konnte nicht gelöscht werden: %s",
+		"list_objects_failed":                "Objekte konnten nicht aufgelistet werden: %s",
+		"list_objects_deletion_failed":       "Objekte zum Löschen konnten nicht aufgelistet werden: %s",
+		"list_objects_download_failed":       "Objekte zum Herunterladen konnten nicht aufgelistet werden: %s",
+		"upload_failed":                      "Datei konnte nicht hochgeladen werden: %s",
+		"download_failed":                    "Datei konnte nicht heruntergeladen werden: %s",
+		"delete_file_failed":                 "Datei konnte nicht gelöscht werden: %s",
+		"delete_objects_failed":              "Objekte konnten nicht gelöscht werden: %s",
+		"get_object_failed":                  "Objekt %s konnte nicht geladen werden: %s",
+		"zip_create_failed":                  "ZIP-Eintrag für %s konnte nicht erstellt werden: %s",
+		"zip_copy_failed":                    "Objekt %s konnte nicht in das ZIP kopiert werden: %s",
+		"presign_failed":                     "Objekt konnte nicht signiert werden: %s",
+		"object_not_found":                   "Objekt wurde nicht gefunden: %s",
+		"share_create_failed":                "Freigabe konnte nicht erstellt werden: %s",
+		"share_rotate_failed":                "Freigabe konnte nicht erneuert werden: %s",
+		"share_delete_failed":                "Freigabe konnte nicht gelöscht werden: %s",
+		"share_not_found":                    "Freigabe nicht gefunden",
+		"share_expired":                      "Freigabe ist abgelaufen",
+		"move_keys_required":                 "Quell- und Zielschlüssel sind erforderlich",
+		"move_destination_is_folder":         "Zielschlüssel darf nicht mit einem Schrägstrich enden",
+		"move_same_location":                 "Quelle und Ziel sind identisch",
+		"move_cross_region":                  "Objekte können nicht zwischen Regionen verschoben werden (%s nach %s)",
+		"move_destination_exists":            "Ziel %s existiert bereits",
+		"move_failed":                        "Objekt konnte nicht verschoben werden: %s",
+		"bucket_region_failed":               "Region von Bucket %s konnte nicht ermittelt werden: %s",
+		"keys_required":                      "Mindestens ein Schlüssel ist erforderlich",
+		"job_start_failed":                   "Job konnte nicht gestartet werden: %s",
+		"job_not_found":                      "Job nicht gefunden",
+		"compose_keys_required":              "Quellschlüssel und Zielschlüssel sind erforderlich",
+		"compose_too_many_sources":           "Es können höchstens %d Quellobjekte zusammengefügt werden",
+		"compose_destination_is_source":      "Zielschlüssel darf keiner der Quellschlüssel sein",
+		"compose_failed":                     "Objekte konnten nicht zusammengefügt werden: %s",
+		"split_source_required":              "Quellschlüssel ist erforderlich",
+		"split_chunk_size_invalid":           "chunkSize muss eine positive Anzahl Bytes sein",
+		"split_too_many_chunks":              "Das Aufteilen würde %d Teile erzeugen, erlaubt sind höchstens %d",
+		"list_versions_failed":               "Objektversionen konnten nicht aufgelistet werden: %s",
+		"rename_prefixes_required":           "Quell- und Zielpräfix sind erforderlich",
+		"rename_prefixes_overlap":            "Quell- und Zielpräfix dürfen sich nicht gegenseitig enthalten",
+		"lifecycle_rule_no_action":           "Regel benötigt mindestens einen Ablauf, Übergang oder eine Aufräumaktion",
+		"lifecycle_expiration_invalid":       "Ablauf benötigt positive Tage oder ein Datum",
+		"lifecycle_transition_invalid":       "Übergang benötigt positive Tage oder ein Datum",
+		"lifecycle_storage_class_invalid":    "Unbekannte Speicherklasse %q",
+		"policy_simulation_fields_required":  "Principal und Aktion sind erforderlich",
+		"get_policy_failed":                  "Bucket-Policy konnte nicht geladen werden: %s",
+		"update_metadata_failed":             "Objekt-Metadaten konnten nicht aktualisiert werden: %s",
+		"inbox_limits_required":              "expires, maxFileSize und maxFiles müssen positiv sein",
+		"inbox_create_failed":                "Eingang konnte nicht erstellt werden: %s",
+		"inbox_delete_failed":                "Eingang konnte nicht gelöscht werden: %s",
+		"inbox_not_found":                    "Eingang nicht gefunden",
+		"inbox_expired":                      "Eingang ist abgelaufen",
+		"inbox_full":                         "Eingang nimmt keine weiteren Dateien an",
+		"inbox_file_too_large":               "Datei überschreitet das Limit von %d Bytes",
+		"inbox_file_exists":                  "Eine Datei namens %s wurde bereits hochgeladen",
+		"inbox_scan_rejected":                "Datei wurde vom Scanner abgelehnt: %s",
+		"email_invalid":                      "Ungültige E-Mail-Adresse %q",
+		"transfer_mail_not_configured":       "Transferanfragen benötigen eine Konfiguration für smtp und server.public_url",
+		"transfer_create_failed":             "Transferanfrage konnte nicht erstellt werden: %s",
+		"transfer_mail_failed":               "E-Mail zur Transferanfrage konnte nicht gesendet werden: %s",
+		"transfer_not_found":                 "Transferanfrage nicht gefunden",
+		"transfer_delete_failed":             "Transferanfrage konnte nicht gelöscht werden: %s",
+		"tags_too_many":                      "Höchstens %d Tags sind erlaubt",
+		"tag_key_invalid":                    "Tag-Schlüssel %q muss zwischen 1 und %d Zeichen lang sein",
+		"tag_value_invalid":                  "Wert von Tag %q darf %d Zeichen nicht überschreiten",
+		"get_tags_failed":                    "Tags konnten nicht geladen werden: %s",
+		"put_tags_failed":                    "Tags konnten nicht aktualisiert werden: %s",
+		"delete_tags_failed":                 "Tags konnten nicht gelöscht werden: %s",
+		"archive_prefix_required":            "Präfix ist erforderlich",
+		"archive_not_ready":                  "Archiv ist noch nicht fertig",
+		"object_not_deleted":                 "%s ist nicht durch eine Löschmarkierung verborgen",
+		"undelete_failed":                    "Objekt konnte nicht wiederhergestellt werden: %s",
+		"idempotency_key_invalid":            "Idempotency-Key darf höchstens %d Zeichen lang sein",
+		"idempotency_key_reused":             "Idempotency-Key wurde bereits für eine andere Anfrage verwendet",
+		"idempotency_key_in_progress":        "Die ursprüngliche Anfrage zu diesem Idempotency-Key wurde nicht abgeschlossen, bitte erneut versuchen",
+		"operation_not_found":                "Vorgang nicht gefunden",
+		"client_version_mismatch":            "Client-Version %s passt nicht zur Server-Version %s, bitte die Seite neu laden",
+		"lifecycle_noncurrent_days_invalid":  "Aktionen für nicht aktuelle Versionen benötigen positive Tage",
+		"lifecycle_abort_days_invalid":       "Tage bis zum Abbruch unvollständiger Multipart-Uploads dürfen nicht negativ sein",
+		"lifecycle_too_many_rules":           "Eine Lifecycle-Konfiguration umfasst höchstens %d Regeln",
+		"lifecycle_rule_id_required":         "Jede Lifecycle-Regel benötigt eine ID",
+		"lifecycle_rule_id_duplicate":        "Lifecycle-Regel-ID %q wird mehrfach verwendet",
+		"get_lifecycle_failed":               "Lifecycle-Konfiguration konnte nicht geladen werden: %s",
+		"put_lifecycle_failed":               "Lifecycle-Konfiguration konnte nicht gespeichert werden: %s",
+		"delete_lifecycle_failed":            "Lifecycle-Konfiguration konnte nicht gelöscht werden: %s",
+		"policy_too_large":                   "Bucket-Policies dürfen höchstens %d Bytes groß sein",
+		"policy_invalid_json":                "Policy ist kein gültiges JSON: %s",
+		"policy_version_invalid":             "Nicht unterstützte Policy-Version %q, bitte \"2012-10-17\" verwenden",
+		"policy_statements_required":         "Policy benötigt mindestens ein Statement",
+		"policy_effect_invalid":              "Statement #%d hat den ungültigen Effect %q, erlaubt sind Allow oder Deny",
+		"policy_principal_required":          "Statement #%d benötigt ein Principal oder NotPrincipal",
+		"policy_action_required":             "Statement #%d benötigt eine Action oder NotAction",
+		"policy_resource_required":           "Statement #%d benötigt eine Resource oder NotResource",
+		"policy_sid_duplicate":               "Statement-ID %q wird mehrfach verwendet",
+		"policy_resource_foreign":            "Resource %q gehört nicht zu diesem Bucket",
+		"policy_public_access":               "Erlaubt allen den Zugriff ohne Bedingungen",
+		"policy_all_actions":                 "Erlaubt alle Aktionen",
+		"policy_allow_with_not":              "Allow in Kombination mit NotAction oder NotPrincipal gewährt mehr als es scheint",
+		"put_policy_failed":                  "Bucket-Policy konnte nicht gespeichert werden: %s",
+		"delete_policy_failed":               "Bucket-Policy konnte nicht gelöscht werden: %s",
+		"acl_permission_invalid":             "Unbekannte Berechtigung %q",
+		"acl_grantee_incomplete":             "Beim Empfänger vom Typ %s fehlt die Kennung",
+		"acl_grantee_type_invalid":           "Unbekannter Empfängertyp %q",
+		"acl_canned_and_grants":              "Entweder eine vordefinierte ACL oder explizite Berechtigungen angeben",
+		"acl_canned_invalid":                 "Unbekannte vordefinierte ACL %q",
+		"acl_owner_required":                 "Für explizite Berechtigungen ist die Owner-ID erforderlich",
+		"get_acl_failed":                     "Bucket-ACL konnte nicht geladen werden: %s",
+		"put_acl_failed":                     "Bucket-ACL konnte nicht gespeichert werden: %s",
+		"branding_name_required":             "Instanzname ist erforderlich",
+		"branding_name_too_long":             "Instanzname darf höchstens %d Zeichen lang sein",
+		"branding_logo_invalid":              "Logo-URL muss eine http(s)-URL oder ein absoluter Pfad sein",
+		"branding_color_invalid":             "Ungültige Farbe %q, bitte #rgb oder #rrggbb verwenden",
+		"branding_theme_invalid":             "Unbekannter Theme-Modus %q",
+		"branding_banner_invalid":            "Bannertext darf nicht leer und höchstens %d Zeichen lang sein",
+		"branding_banner_severity_invalid":   "Unbekannte Banner-Stufe %q",
+		"branding_save_failed":               "Branding konnte nicht gespeichert werden: %s",
+		"cors_origins_required":              "CORS-Regel benötigt mindestens einen erlaubten Origin",
+		"cors_origin_invalid":                "Ungültiger Origin %q, höchstens ein * ist erlaubt",
+		"cors_methods_required":              "CORS-Regel benötigt mindestens eine erlaubte Methode",
+		"cors_method_invalid":                "Methode %q ist nicht erlaubt, bitte GET, PUT, POST, DELETE oder HEAD verwenden",
+		"cors_header_invalid":                "Ungültiger Header %q, höchstens ein * ist erlaubt",
+		"cors_max_age_invalid":               "Max-Age darf nicht negativ sein",
+		"cors_too_many_rules":                "Eine CORS-Konfiguration umfasst höchstens %d Regeln",
+		"get_cors_failed":                    "CORS-Konfiguration konnte nicht geladen werden: %s",
+		"put_cors_failed":                    "CORS-Konfiguration konnte nicht gespeichert werden: %s",
+		"delete_cors_failed":                 "CORS-Konfiguration konnte nicht gelöscht werden: %s",
+		"maintenance_active":                 "Der Dienst befindet sich im Wartungsmodus, bitte später erneut versuchen",
+		"maintenance_announced":              "Wartung: %s",
+		"maintenance_message_too_long":       "Wartungshinweis darf höchstens %d Zeichen lang sein",
+		"maintenance_window_start_required":  "Ein Wartungsfenster mit Ende benötigt einen Beginn",
+		"maintenance_window_invalid":         "Wartungsfenster muss nach seinem Beginn enden",
+		"maintenance_save_failed":            "Wartungseinstellungen konnten nicht gespeichert werden: %s",
+		"encryption_algorithm_invalid":       "Unbekannte serverseitige Verschlüsselung %q, bitte AES256, aws:kms oder aws:kms:dsse verwenden",
+		"encryption_kms_key_without_kms":     "Eine KMS-Schlüssel-ID erfordert aws:kms- oder aws:kms:dsse-Verschlüsselung",
+		"cidr_invalid":                       "Ungültige IP-Adresse oder ungültiger CIDR-Bereich %q",
+		"referer_pattern_invalid":            "Ungültiges Referrer-Muster %q",
+		"share_access_denied":                "Auf diese Freigabe kann von hier aus nicht zugegriffen werden",
+		"bucket_frozen":                      "Bucket %s wurde von %s eingefroren: %s",
+		"bucket_not_frozen":                  "Bucket %s ist nicht eingefroren",
+		"freeze_fields_required":             "frozenBy und reason sind erforderlich",
+		"freeze_save_failed":                 "Einfrieren des Buckets konnte nicht gespeichert werden: %s",
+		"get_object_lock_failed":             "Object-Lock-Konfiguration konnte nicht geladen werden: %s",
+		"put_object_lock_failed":             "Object-Lock-Konfiguration konnte nicht gespeichert werden: %s",
+		"object_lock_mode_invalid":           "Unbekannter Aufbewahrungsmodus %q, bitte GOVERNANCE oder COMPLIANCE verwenden",
+		"object_lock_period_invalid":         "Standard-Aufbewahrung benötigt entweder positive Tage oder positive Jahre",
+		"retention_date_invalid":             "Aufbewahrungsdatum muss in der Zukunft liegen",
+		"get_retention_failed":               "Aufbewahrung des Objekts konnte nicht geladen werden: %s",
+		"put_retention_failed":               "Aufbewahrung des Objekts konnte nicht gesetzt werden: %s",
+		"legal_hold_status_invalid":          "Unbekannter Legal-Hold-Status %q, bitte ON oder OFF verwenden",
+		"get_legal_hold_failed":              "Legal Hold konnte nicht geladen werden: %s",
+		"put_legal_hold_failed":              "Legal Hold konnte nicht gesetzt werden: %s",
+		"metadata_import_invalid":            "Ungültige CSV-Datei: %s",
+		"metadata_import_empty":              "Die CSV-Datei benötigt eine Kopfzeile und mindestens eine Zeile",
+		"metadata_import_column_unknown":     "Unbekannte Spalte %q",
+		"metadata_import_key_missing":        "Die CSV-Datei benötigt eine Spalte key",
+		"metadata_import_row_invalid":        "Zeile %d ist ungültig: %v",
+		"metadata_import_too_large":          "Die CSV-Datei darf höchstens %d MB groß sein",
+		"get_request_payment_failed":         "Zahlungskonfiguration für Anfragen konnte nicht geladen werden: %s",
+		"put_request_payment_failed":         "Zahlungskonfiguration für Anfragen konnte nicht gespeichert werden: %s",
+		"payer_invalid":                      "Unbekannter Zahler %q, bitte BucketOwner oder Requester verwenden",
+		"legal_hold_request_fields_required": "Antragsteller und Begründung sind erforderlich",
+		"legal_hold_selection_invalid":       "Objekte bitte entweder über eine Liste von Schlüsseln oder über Präfix, Muster, Filter oder eine gespeicherte Suche auswählen",
+		"legal_hold_too_many_keys":           "Es können höchstens %d Schlüssel ausgewählt werden, für größere Auswahlen bitte ein Präfix verwenden",
+		"legal_hold_request_save_failed":     "Legal-Hold-Antrag konnte nicht gespeichert werden: %s",
+		"legal_hold_decider_required":        "Der Name der entscheidenden Person ist erforderlich",
+		"legal_hold_request_not_found":       "Legal-Hold-Antrag nicht gefunden",
+		"legal_hold_self_approval":           "Anträge müssen von einer anderen Person als dem Antragsteller entschieden werden",
+		"legal_hold_request_decided":         "Über den Legal-Hold-Antrag wurde bereits entschieden",
+		"get_accelerate_failed":              "Transfer Acceleration konnte nicht geladen werden: %s",
+		"put_accelerate_failed":              "Transfer Acceleration konnte nicht geändert werden: %s",
+		"region_not_found":                   "Region %q ist nicht konfiguriert",
+		"since_invalid":                      "Ungültige Zeitangabe %q, erwartet wird RFC 3339",
+		"client_ip_denied":                   "Zugriff aus Ihrem Netzwerk ist nicht erlaubt",
+		"replication_role_invalid":           "Replikation benötigt die ARN einer IAM-Rolle, erhalten: %q",
+		"replication_too_many_rules":         "Höchstens %d Replikationsregeln sind erlaubt",
+		"replication_rule_id_invalid":        "Replikationsregeln benötigen eine eindeutige ID, erhalten: %q",
+		"replication_priority_duplicate":     "Priorität %d wird von mehr als einer Regel verwendet",
+		"replication_destination_required":   "Regel %q benötigt einen Ziel-Bucket",
+		"replication_storage_class_invalid":  "Unbekannte Speicherklasse %q",
+		"replication_account_required":       "Regel %q ändert den Eigentümer der Replikate und benötigt das Zielkonto",
+		"get_versioning_failed":              "Versionierungsstatus konnte nicht geladen werden: %s",
+		"replication_versioning_required":    "Für Bucket %s muss die Versionierung aktiviert sein, bevor Replikation eingerichtet werden kann",
+		"get_replication_failed":             "Replikationskonfiguration konnte nicht geladen werden: %s",
+		"put_replication_failed":             "Replikationskonfiguration konnte nicht gespeichert werden: %s",
+		"delete_replication_failed":          "Replikationskonfiguration konnte nicht gelöscht werden: %s",
+		"conflict_policy_invalid":            "Unbekannte Konfliktregel %q, bitte overwrite, fail oder keepBoth verwenden",
+		"object_exists":                      "%s existiert bereits",
+		"rename_find_required":               "Ein Suchmuster ist erforderlich",
+		"rename_regex_invalid":               "Ungültiger regulärer Ausdruck: %s",
+		"rename_prefix_outside":              "Das zu ersetzende Präfix %q liegt nicht unterhalb von %q",
+		"rename_mode_invalid":                "Unbekannter Umbenennungsmodus %q, bitte regex oder prefix verwenden",
+		"rename_too_many":                    "Höchstens %d Schlüssel können auf einmal umbenannt werden, bitte das Präfix einschränken",
+		"manifest_format_invalid":            "Unbekanntes Manifest-Format %q, bitte csv oder json verwenden",
+		"manifest_selection_invalid":         "Objekte bitte entweder über ein Präfix oder über eine Liste von Schlüsseln auswählen",
+		"manifest_too_large":                 "Ein Manifest kann höchstens %d Objekte enthalten, bitte das Präfix einschränken",
+		"preview_too_large":                  "Objekte größer als %d MB können nicht in der Vorschau angezeigt werden, bitte herunterladen",
+		"checksum_manifest_name_invalid":     "Der Manifest-Name %q darf keinen Schrägstrich enthalten",
+		"checksums_not_ready":                "Das Prüfsummen-Manifest ist noch nicht fertig",
+		"thumbnail_size_invalid":             "Ungültige Vorschaubildgröße %q",
+		"thumbnail_source_too_large":         "Für Bilder größer als %d MB wird kein Vorschaubild erstellt",
+		"thumbnail_unsupported":              "Für dieses Objekt kann kein Vorschaubild erstellt werden: %s",
+		"region_invalid":                     "Ungültige Region %q",
+		"region_unsupported":                 "Andere Regionen können nur mit AWS verwendet werden, nicht mit einem eigenen Endpunkt",
+		"edit_too_large":                     "Objekte größer als %d KB können nicht bearbeitet werden, bitte herunterladen",
+		"edit_not_text":                      "Das Objekt ist kein UTF-8-Text und kann nicht bearbeitet werden",
+		"edit_conflict":                      "Das Objekt wurde zwischenzeitlich geändert, bitte vor dem Speichern neu laden",
+		"table_rows_invalid":                 "Die Anzahl der Zeilen muss zwischen 1 und %d liegen",
+		"table_format_unsupported":           "Nur CSV- und TSV-Dateien können als Tabelle angezeigt werden",
+		"table_parse_failed":                 "Die Datei konnte nicht als Tabelle gelesen werden: %s",
+		"copy_option_invalid":                "Ungültiger Wert %q für die Kopieroption %s",
+		"copy_acl_unsupported":               "Der Speicheranbieter unterstützt keine Objekt-ACLs, sie können nicht übernommen werden",
+		"filter_size_invalid":                "Ungültiger Wert für %s: %q, erwartet werden Bytes oder eine Größe wie 1GB",
+		"filter_time_invalid":                "Ungültiger Wert für %s: %q, erwartet wird ein Datum, ein RFC-3339-Zeitstempel oder eine Anzahl Tage wie 90d",
+		"search_limit_invalid":               "Das Limit muss zwischen 1 und %d liegen",
+		"search_query_required":              "Bitte einen Suchbegriff, ein Muster oder eine Dateiendung angeben",
+		"metrics_unauthorized":               "Ein gültiges Metrik-Token ist erforderlich",
+		"saved_search_fields_required":       "Eine gespeicherte Suche braucht einen Namen und einen Bucket",
+		"saved_search_not_found":             "Gespeicherte Suche wurde nicht gefunden",
+		"saved_search_save_failed":           "Die Suche konnte nicht gespeichert werden: %s",
+		"audit_verify_failed":                "Das Audit-Log konnte nicht geprüft werden: %s",
+		"download_compression_save_failed":   "Die Einstellung zur Download-Komprimierung konnte nicht gespeichert werden: %s",
+		"cache_ttl_invalid":                  "cacheTtl muss eine nicht-negative Anzahl von Sekunden sein, erhalten: %q",
+		"growth_prefix_not_tracked":          "Präfix %q wird nicht erfasst, es muss in growth.prefixes eingetragen werden",
+		"public_index_name_invalid":          "Ungültiger Name %q, erlaubt sind bis zu 63 Kleinbuchstaben, Ziffern und Bindestriche",
+		"public_index_exists":                "Ein öffentlicher Index mit dem Namen %q existiert bereits",
+		"public_index_create_failed":         "Öffentlicher Index konnte nicht erstellt werden: %s",
+		"public_index_delete_failed":         "Öffentlicher Index konnte nicht gelöscht werden: %s",
+		"public_index_not_found":             "Öffentlicher Index nicht gefunden",
+		"public_index_file_not_found":        "Datei %q nicht gefunden",
+		"public_index_rate_limited":          "Zu viele Anfragen, bitte in einer Minute erneut versuchen",
+		"public_index_list_failed":           "Dateien konnten nicht aufgelistet werden",
+		"stats_scan_failed":                  "Zählung des Buckets konnte nicht eingeplant werden: %s",
+		"stats_scan_not_found":               "Zählung nicht gefunden, sie ist womöglich bereits beendet",
+		"inventory_destination_required":     "Das Inventar benötigt einen Ziel-Bucket",
+		"inventory_format_invalid":           "Unbekanntes Inventarformat %q, erwartet wird CSV, ORC oder Parquet",
+		"inventory_frequency_invalid":        "Unbekannte Häufigkeit %q, erwartet wird Daily oder Weekly",
+		"inventory_versions_invalid":         "Unbekannte Versionsauswahl %q, erwartet wird Current oder All",
+		"inventory_field_invalid":            "Unbekanntes optionales Feld %q",
+		"get_inventory_failed":               "Inventarkonfiguration konnte nicht geladen werden: %s",
+		"put_inventory_failed":               "Inventarkonfiguration konnte nicht gespeichert werden: %s",
+		"delete_inventory_failed":            "Inventarkonfiguration konnte nicht gelöscht werden: %s",
+		"inventory_not_found":                "Inventarkonfiguration %q nicht gefunden",
+		"inventory_not_delivered":            "Inventar %q wurde noch nicht geliefert, die erste Lieferung kann bis zu 48 Stunden dauern",
+		"inventory_read_failed":              "Inventarlieferung konnte nicht gelesen werden: %s",
+		"credentials_required":               "Access Key ID und Secret Access Key sind erforderlich",
+		"credentials_grace_invalid":          "Ungültige Übergangszeit %d, sie darf nicht negativ sein",
+		"credentials_unchanged":              "Dieser Access Key wird bereits verwendet",
+		"credentials_invalid":                "Der Access Key wurde abgelehnt: %s",
+		"credentials_missing_buckets":        "Der neue Access Key sieht %d Buckets des aktuellen nicht, mit force trotzdem wechseln",
+		"credentials_no_previous":            "Es gibt keinen vorherigen Access Key zum Zurückwechseln",
+		"credentials_state_failed":           "Speichern des Access-Key-Zustands fehlgeschlagen: %v",
+		"job_timed_out":                      "Der Job wurde nach seinem Timeout von %s abgebrochen",
+		"job_already_finished":               "Der Job ist bereits mit Status %s beendet",
+		"link_not_found":                     "Link nicht gefunden",
+		"link_target_not_found":              "Bucket oder Objekt dieses Links existiert nicht mehr",
+		"link_target_forbidden":              "Zugriff auf Bucket oder Objekt dieses Links verweigert",
+		"link_check_failed":                  "Prüfen des Linkziels fehlgeschlagen: %v",
+		"link_create_failed":                 "Erstellen des Links fehlgeschlagen: %v",
+		"report_format_invalid":              "Unbekanntes Berichtsformat %q, bitte csv oder json verwenden",
+		"websocket_required":                 "Dieser Endpunkt akzeptiert nur WebSocket-Verbindungen",
+		"websocket_version_unsupported":      "Nicht unterstützte WebSocket-Version, bitte 13 verwenden",
+		"websocket_upgrade_failed":           "Öffnen der WebSocket-Verbindung fehlgeschlagen: %v",
+		"sync_buckets_required":              "Quell- und Ziel-Bucket sind erforderlich",
+		"sync_prefixes_overlap":              "Quelle und Ziel dürfen sich nicht gegenseitig enthalten",
+		"sync_delete_failed":                 "Löschen fehlgeschlagen: %s %s",
+		"schedule_name_required":             "Ein Zeitplan braucht einen Namen",
+		"schedule_cron_invalid":              "Ungültiger Cron-Ausdruck %q: %v",
+		"schedule_task_invalid":              "Unbekannte Aufgabe %q, bitte eine von %s verwenden",
+		"schedule_task_incomplete":           "Die Einstellungen der Aufgabe %s sind unvollständig",
+		"schedule_purge_age_invalid":         "Ungültiges Alter %d, es darf nicht negativ sein",
+		"schedule_id_invalid":                "Zeitplan-IDs in der Konfiguration müssen gesetzt und eindeutig sein, erhalten: %q",
+		"schedule_not_found":                 "Zeitplan nicht gefunden",
+		"schedule_save_failed":               "Speichern des Zeitplans fehlgeschlagen: %v",
+		"schedule_from_config":               "Zeitplan %s ist in der Konfiguration definiert und kann hier nicht geändert werden",
+		"schedule_run_failed":                "Der Zeitplan konnte nicht gestartet werden: %s",
+		"batch_operations_required":          "Keine Operationen angegeben",
+		"batch_too_many":                     "Ein Batch kann höchstens %d Operationen enthalten",
+		"batch_action_invalid":               "Unbekannte Batch-Aktion %q",
+		"batch_key_required":                 "Jede Operation braucht einen Schlüssel",
+		"batch_destination_required":         "Kopien brauchen einen Zielschlüssel",
+		"batch_copy_identical":               "Ein Objekt kann nicht auf sich selbst kopiert werden",
+		"batch_object_unavailable":           "Objekt kann nicht gelesen werden: %v",
+		"fetch_url_invalid":                  "%q ist keine http- oder https-URL",
+		"fetch_key_required":                 "Kein Objektschlüssel angegeben und keiner in der URL gefunden",
+		"fetch_status_failed":                "Der entfernte Server antwortete mit %s",
+		"share_download_failed":              "Die geteilte Datei konnte nicht heruntergeladen werden",
+		"idempotency_key_mismatch":           "Idempotency-Key wurde bereits mit einem anderen Anfrageinhalt verwendet",
+	},
+}
+
+// apiError carries a message code so validation helpers can report errors
+// without knowing the language of the request.
+type apiError struct {
+	code string
+	args []interface{}
+}
+
+func newAPIError(code string, args ...interface{}) *apiError {
+	return &apiError{code: code, args: args}
+}
+
+func (e *apiError) Error() string {
+	return translate(defaultLanguage, e.code, e.args...)
+}
+
+func translate(lang, code string, args ...interface{}) string {
+	format, ok := messages[lang][code]
+	if !ok {
+		format, ok = messages[defaultLanguage][code]
+	}
+	if !ok {
+		format = code
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
+
+// negotiateLanguage picks the best supported language from an Accept-Language header
+func negotiateLanguage(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+
+		candidates = append(candidates, candidate{lang: lang, q: q})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	for _, c := range candidates {
+		if c.q <= 0 {
+			continue
+		}
+		if c.lang == "*" {
+			return defaultLanguage
+		}
+
+		// Only the primary subtag matters, "de-AT" is served as "de"
+		base, _, _ := strings.Cut(c.lang, "-")
+		if _, ok := messages[base]; ok {
+			return base
+		}
+	}
+
+	return defaultLanguage
+}
+
+func httpError(w http.ResponseWriter, r *http.Request, status int, code string, args ...interface{}) {
+	lang := negotiateLanguage(r.Header.Get("Accept-Language"))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":    code,
+		"message": translate(lang, code, args...),
+	})
+}
+
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, err *apiError) {
+	httpError(w, r, status, err.code, err.args...)
+}
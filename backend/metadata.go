@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+type ObjectMetadata struct {
+	ContentType        *string           `json:"contentType,omitempty"`
+	CacheControl       *string           `json:"cacheControl,omitempty"`
+	ContentDisposition *string           `json:"contentDisposition,omitempty"`
+	ContentEncoding    *string           `json:"contentEncoding,omitempty"`
+	ContentLanguage    *string           `json:"contentLanguage,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+}
+
+func headObjectMetadata(head *s3.HeadObjectOutput) ObjectMetadata {
+	return ObjectMetadata{
+		ContentType:        head.ContentType,
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentLanguage:    head.ContentLanguage,
+		Metadata:           head.Metadata,
+	}
+}
+
+// overlay returns m with the fields set in data replaced
+func (m ObjectMetadata) overlay(data ObjectMetadata) ObjectMetadata {
+	if data.ContentType != nil {
+		m.ContentType = data.ContentType
+	}
+	if data.CacheControl != nil {
+		m.CacheControl = data.CacheControl
+	}
+	if data.ContentDisposition != nil {
+		m.ContentDisposition = data.ContentDisposition
+	}
+	if data.ContentEncoding != nil {
+		m.ContentEncoding = data.ContentEncoding
+	}
+	if data.ContentLanguage != nil {
+		m.ContentLanguage = data.ContentLanguage
+	}
+	if data.Metadata != nil {
+		m.Metadata = data.Metadata
+	}
+
+	return m
+}
+
+// replaceObjectMetadata copies the object onto itself with the fields set in
+// data replaced, keeping all others
+func replaceObjectMetadata(ctx context.Context, bucketName, objectKey string, data ObjectMetadata) (ObjectMetadata, error) {
+	head, err := bucketClient(ctx, bucketName).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return ObjectMetadata{}, err
+	}
+
+	// REPLACE drops everything not sent along, so start from the current values
+	updated := headObjectMetadata(head).overlay(data)
+
+	input := &s3.CopyObjectInput{
+		Bucket:             aws.String(bucketName),
+		Key:                aws.String(objectKey),
+		CopySource:         aws.String(copySource(bucketName, objectKey)),
+		MetadataDirective:  types.MetadataDirectiveReplace,
+		ContentType:        updated.ContentType,
+		CacheControl:       updated.CacheControl,
+		ContentDisposition: updated.ContentDisposition,
+		ContentEncoding:    updated.ContentEncoding,
+		ContentLanguage:    updated.ContentLanguage,
+		Metadata:           updated.Metadata,
+	}
+
+	// Without these the copy would silently fall back to the bucket defaults
+	if head.StorageClass != "" {
+		input.StorageClass = head.StorageClass
+	}
+	if head.ServerSideEncryption == types.ServerSideEncryptionAwsKms {
+		input.ServerSideEncryption = head.ServerSideEncryption
+		input.SSEKMSKeyId = head.SSEKMSKeyId
+	}
+
+	if _, err := bucketClient(ctx, bucketName).CopyObject(ctx, input); err != nil {
+		return ObjectMetadata{}, err
+	}
+
+	return updated, nil
+}
+
+func getObjectMetadata(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	head, err := bucketClient(r.Context(), bucketName).HeadObject(r.Context(), &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusNotFound, "object_not_found", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(headObjectMetadata(head))
+}
+
+func updateObjectMetadata(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	var data ObjectMetadata
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	updated, err := replaceObjectMetadata(r.Context(), bucketName, objectKey, data)
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		httpError(w, r, http.StatusNotFound, "object_not_found", err)
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "update_metadata_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// Imports are parsed up front, so their size is bounded
+const maxMetadataImportSize = 32 << 20
+
+// metadataColumns are the CSV columns besides key. User metadata and tags are
+// url query encoded, e.g. "team=data&pii=false".
+var metadataColumns = []string{
+	"contentType", "cacheControl", "contentDisposition", "contentEncoding", "contentLanguage", "metadata", "tags",
+}
+
+// MetadataImportRow holds the columns of one CSV row. Columns missing from the
+// file or left empty keep the current value of the object.
+type MetadataImportRow struct {
+	Key      string
+	Metadata ObjectMetadata
+	Tags     map[string]string
+}
+
+func encodeMap(values map[string]string) string {
+	query := url.Values{}
+	for key, value := range values {
+		query.Set(key, value)
+	}
+
+	return query.Encode()
+}
+
+func decodeMap(value string) (map[string]string, error) {
+	query, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(query))
+	for key := range query {
+		values[key] = query.Get(key)
+	}
+
+	return values, nil
+}
+
+func exportObjectRow(ctx context.Context, bucketName, key string) ([]string, error) {
+	head, err := bucketClient(ctx, bucketName).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tagging, err := bucketClient(ctx, bucketName).GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		key,
+		aws.ToString(head.ContentType),
+		aws.ToString(head.CacheControl),
+		aws.ToString(head.ContentDisposition),
+		aws.ToString(head.ContentEncoding),
+		aws.ToString(head.ContentLanguage),
+		encodeMap(head.Metadata),
+		encodeMap(tagsToMap(tagging.TagSet)),
+	}, nil
+}
+
+// exportMetadata streams the metadata and tags of all objects below prefix as
+// CSV, in the format importMetadata accepts
+func exportMetadata(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	prefix := r.URL.Query().Get("prefix")
+
+	fileName := bucketName
+	if prefix != "" {
+		fileName = path.Clean(prefix)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", attachmentDisposition(path.Base(fileName)+"-metadata.csv"))
+
+	writer := csv.NewWriter(w)
+	writer.Write(append([]string{"key"}, metadataColumns...))
+
+	ctx := r.Context()
+	err := walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		row, err := exportObjectRow(ctx, bucketName, aws.ToString(obj.Key))
+		if err != nil {
+			return err
+		}
+
+		writer.Write(row)
+		return writer.Error()
+	})
+	writer.Flush()
+
+	// The status line is already sent, all we can do is cut the file short
+	if err != nil {
+		log.Printf("metadata export of %s/%s aborted: %v", bucketName, prefix, err)
+	}
+}
+
+// parseMetadataImport validates the whole file, so a broken row is reported
+// before any object is touched
+func parseMetadataImport(records [][]string) ([]MetadataImportRow, *apiError) {
+	if len(records) < 2 {
+		return nil, newAPIError("metadata_import_empty")
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		name = strings.TrimSpace(name)
+		if name != "key" && !isKnownValue(name, metadataColumns) {
+			return nil, newAPIError("metadata_import_column_unknown", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["key"]; !ok {
+		return nil, newAPIError("metadata_import_key_missing")
+	}
+
+	cell := func(record []string, name string) *string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) || record[i] == "" {
+			return nil
+		}
+		return aws.String(record[i])
+	}
+
+	rows := make([]MetadataImportRow, 0, len(records)-1)
+	for line, record := range records[1:] {
+		row := MetadataImportRow{
+			Key: aws.ToString(cell(record, "key")),
+			Metadata: ObjectMetadata{
+				ContentType:        cell(record, "contentType"),
+				CacheControl:       cell(record, "cacheControl"),
+				ContentDisposition: cell(record, "contentDisposition"),
+				ContentEncoding:    cell(record, "contentEncoding"),
+				ContentLanguage:    cell(record, "contentLanguage"),
+			},
+		}
+		// Line numbers are 1-based and include the header
+		if row.Key == "" {
+			return nil, newAPIError("metadata_import_row_invalid", line+2, "key is empty")
+		}
+
+		if value := cell(record, "metadata"); value != nil {
+			metadata, err := decodeMap(*value)
+			if err != nil {
+				return nil, newAPIError("metadata_import_row_invalid", line+2, err)
+			}
+			row.Metadata.Metadata = metadata
+		}
+
+		if value := cell(record, "tags"); value != nil {
+			tags, err := decodeMap(*value)
+			if err != nil {
+				return nil, newAPIError("metadata_import_row_invalid", line+2, err)
+			}
+			if apiErr := validateTags(tags, maxObjectTags); apiErr != nil {
+				return nil, newAPIError("metadata_import_row_invalid", line+2, translate("en", apiErr.code, apiErr.args...))
+			}
+			row.Tags = tags
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func (row MetadataImportRow) changesMetadata() bool {
+	m := row.Metadata
+
+	return m.ContentType != nil || m.CacheControl != nil || m.ContentDisposition != nil ||
+		m.ContentEncoding != nil || m.ContentLanguage != nil || m.Metadata != nil
+}
+
+func applyMetadataRow(ctx context.Context, bucketName string, row MetadataImportRow) error {
+	// The copy keeps the current tags, so new ones are written afterwards
+	if row.changesMetadata() {
+		if _, err := replaceObjectMetadata(ctx, bucketName, row.Key, row.Metadata); err != nil {
+			return err
+		}
+	}
+
+	if row.Tags != nil {
+		_, err := bucketClient(ctx, bucketName).PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+			Bucket:  aws.String(bucketName),
+			Key:     aws.String(row.Key),
+			Tagging: &types.Tagging{TagSet: tagsFromMap(row.Tags)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importMetadata applies a CSV sent as the "file" form field or as the raw
+// request body in a job, one object per row
+func importMetadata(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMetadataImportSize)
+
+	var reader io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.ParseMultipartForm(10 << 20) // 10 MB
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "file_missing")
+			return
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	records, err := csv.NewReader(reader).ReadAll()
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		httpError(w, r, http.StatusRequestEntityTooLarge, "metadata_import_too_large", maxMetadataImportSize>>20)
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "metadata_import_invalid", err)
+		return
+	}
+
+	rows, apiErr := parseMetadataImport(records)
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	// Rows are applied in key order, the job results are easier to scan that way
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Key < rows[j].Key
+	})
+
+	job, err := startBucketJob(bucketName, "metadata-import", fmt.Sprintf("%d objects", len(rows)), func(ctx context.Context, job *jobHandle) error {
+		job.SetTotal(len(rows))
+
+		for _, row := range rows {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			job.Report(row.Key, applyMetadataRow(ctx, bucketName, row))
+		}
+
+		return nil
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	audit.Record(r, "metadata.import", bucketName, "", map[string]interface{}{
+		"jobId": job.ID,
+		"rows":  len(rows),
+	})
+
+	writeJobAccepted(w, job)
+}
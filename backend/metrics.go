@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// collectBucketStats scans one bucket after the other. Listing is the only
+// way to count objects that works with every provider, so it runs in the
+// background and scrapes only read the cache.
+func collectBucketStats() {
+	interval := time.Duration(appConfig.Metrics.BucketStatsIntervalMinutes) * time.Minute
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx := context.Background()
+		result, err := s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
+		if err != nil {
+			log.Printf("metrics: failed to list buckets: %v", err)
+		} else {
+			if appConfig.AWS.AutoDiscover {
+				annotateBucketRegions(ctx, result.Buckets)
+			}
+
+			names := make(map[string]bool, len(result.Buckets))
+			for _, bucket := range result.Buckets {
+				name := aws.ToString(bucket.Name)
+				names[name] = true
+
+				if _, err := statsScans.Scan(ctx, name, statsPriorityBackground, "metrics"); err != nil {
+					log.Printf("metrics: failed to scan bucket %s: %v", name, err)
+				}
+			}
+			bucketStats.retain(names)
+		}
+
+		<-ticker.C
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeGauge(w http.ResponseWriter, name, help string, samples map[string]float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+
+	labels := make([]string, 0, len(samples))
+	for label := range samples {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		fmt.Fprintf(w, "%s{%s} %g\n", name, label, samples[label])
+	}
+}
+
+// serveMetrics exports the cached bucket totals in the Prometheus text format
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if token := appConfig.Metrics.Token; token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			httpError(w, r, http.StatusUnauthorized, "metrics_unauthorized")
+			return
+		}
+	}
+
+	objects := map[string]float64{}
+	bytes := map[string]float64{}
+	scanned := map[string]float64{}
+	durations := map[string]float64{}
+	for name, stats := range bucketStats.snapshot() {
+		bucket := `bucket="` + labelEscaper.Replace(name) + `"`
+		for storageClass, count := range stats.Objects {
+			label := bucket + `,storage_class="` + labelEscaper.Replace(storageClass) + `"`
+			objects[label] = float64(count)
+			bytes[label] = float64(stats.Bytes[storageClass])
+		}
+		scanned[bucket] = float64(stats.ScannedAt.Unix())
+		durations[bucket] = stats.Duration.Seconds()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeGauge(w, "s3admin_bucket_objects", "Number of current object versions in the bucket.", objects)
+	writeGauge(w, "s3admin_bucket_size_bytes", "Total size of the current object versions in the bucket.", bytes)
+	writeGauge(w, "s3admin_bucket_scan_timestamp_seconds", "When the bucket was last counted.", scanned)
+	writeGauge(w, "s3admin_bucket_scan_duration_seconds", "How long counting the bucket took.", durations)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// copySource builds the URL-encoded CopySource value expected by CopyObject
+func copySource(bucketName, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return bucketName + "/" + strings.Join(segments, "/")
+}
+
+func objectExists(ctx context.Context, bucketName, key string) (bool, error) {
+	_, err := bucketClient(ctx, bucketName).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func bucketRegion(ctx context.Context, bucketName string) (string, error) {
+	result, err := s3Client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Buckets in us-east-1 report an empty location constraint
+	if result.LocationConstraint == "" {
+		return "us-east-1", nil
+	}
+
+	return string(result.LocationConstraint), nil
+}
+
+// renameOptions keep a renamed object as it was, including its storage class.
+// ACLs are left to the bucket, most buckets have them disabled anyway.
+var renameOptions = CopyOptions{
+	Metadata:     copyPreserve,
+	Tags:         copyPreserve,
+	ACL:          copyDefault,
+	StorageClass: copyPreserve,
+}
+
+func moveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, options CopyOptions, progress copyProgress) error {
+	if err := copyObject(ctx, srcBucket, srcKey, dstBucket, dstKey, options, progress); err != nil {
+		return err
+	}
+
+	_, err := bucketClient(ctx, srcBucket).DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+	})
+
+	return err
+}
+
+func renameObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		SourceKey         string      `json:"sourceKey"`
+		DestinationBucket string      `json:"destinationBucket"`
+		DestinationKey    string      `json:"destinationKey"`
+		Overwrite         bool        `json:"overwrite"`
+		Options           CopyOptions `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+	if apiErr := data.Options.validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	if data.DestinationBucket == "" {
+		data.DestinationBucket = bucketName
+	}
+	if !checkNotFrozen(w, r, data.DestinationBucket) {
+		return
+	}
+
+	if data.SourceKey == "" || data.DestinationKey == "" {
+		httpError(w, r, http.StatusBadRequest, "move_keys_required")
+		return
+	}
+	if strings.HasSuffix(data.DestinationKey, "/") {
+		httpError(w, r, http.StatusBadRequest, "move_destination_is_folder")
+		return
+	}
+	if data.DestinationBucket == bucketName && data.DestinationKey == data.SourceKey {
+		httpError(w, r, http.StatusBadRequest, "move_same_location")
+		return
+	}
+
+	ctx := r.Context()
+
+	if data.DestinationBucket != bucketName {
+		srcRegion, err := bucketRegion(ctx, bucketName)
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "bucket_region_failed", bucketName, err)
+			return
+		}
+		dstRegion, err := bucketRegion(ctx, data.DestinationBucket)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "bucket_region_failed", data.DestinationBucket, err)
+			return
+		}
+		if srcRegion != dstRegion {
+			httpError(w, r, http.StatusBadRequest, "move_cross_region", srcRegion, dstRegion)
+			return
+		}
+	}
+
+	if !data.Overwrite {
+		exists, err := objectExists(ctx, data.DestinationBucket, data.DestinationKey)
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "move_failed", err)
+			return
+		}
+		if exists {
+			httpError(w, r, http.StatusConflict, "move_destination_exists", data.DestinationKey)
+			return
+		}
+	}
+
+	if err := moveObject(ctx, bucketName, data.SourceKey, data.DestinationBucket, data.DestinationKey, data.Options, nil); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "move_failed", err)
+		return
+	}
+	// The middleware only covers the bucket in the path
+	invalidateListings(data.DestinationBucket)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"bucketName": data.DestinationBucket,
+		"key":        data.DestinationKey,
+	})
+}
+
+// batchTargetKey keeps the selected key's own name (and for folders everything
+// below it) when placing it under the destination prefix
+func batchTargetKey(selectedKey, objectKey, destinationPrefix string) string {
+	parent := path.Dir(strings.TrimSuffix(selectedKey, "/"))
+	relative := objectKey
+	if parent != "." {
+		relative = strings.TrimPrefix(objectKey, parent+"/")
+	}
+
+	if destinationPrefix == "" {
+		return relative
+	}
+
+	return strings.TrimSuffix(destinationPrefix, "/") + "/" + relative
+}
+
+func batchCopyObjects(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Keys              []string    `json:"keys"`
+		DestinationBucket string      `json:"destinationBucket"`
+		DestinationPrefix string      `json:"destinationPrefix"`
+		Move              bool        `json:"move"`
+		Options           CopyOptions `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+	if apiErr := data.Options.validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	if len(data.Keys) == 0 {
+		httpError(w, r, http.StatusBadRequest, "keys_required")
+		return
+	}
+	if data.DestinationBucket == "" {
+		data.DestinationBucket = bucketName
+	}
+	if !checkNotFrozen(w, r, data.DestinationBucket) {
+		return
+	}
+
+	jobType := "copy"
+	if data.Move {
+		jobType = "move"
+	}
+
+	job, err := startBucketJob(bucketName, jobType, "", func(ctx context.Context, job *jobHandle) error {
+		transfer := func(srcKey, dstKey string) {
+			if data.DestinationBucket == bucketName && srcKey == dstKey {
+				job.Report(srcKey, errors.New("source and destination are identical"))
+				return
+			}
+
+			var err error
+			if data.Move {
+				err = moveObject(ctx, bucketName, srcKey, data.DestinationBucket, dstKey, data.Options, job.AddBytes)
+			} else {
+				err = copyObject(ctx, bucketName, srcKey, data.DestinationBucket, dstKey, data.Options, job.AddBytes)
+			}
+			job.Done(srcKey, err)
+		}
+
+		for _, key := range data.Keys {
+			if !strings.HasSuffix(key, "/") {
+				job.AddTotal(1)
+				transfer(key, batchTargetKey(key, key, data.DestinationPrefix))
+				continue
+			}
+
+			err := walkObjects(ctx, bucketName, key, func(obj types.Object) error {
+				job.AddTotal(1)
+				transfer(*obj.Key, batchTargetKey(key, *obj.Key, data.DestinationPrefix))
+				return nil
+			})
+			if err != nil {
+				job.AddTotal(1)
+				job.Report(key, err)
+			}
+		}
+
+		return nil
+	}, data.DestinationBucket)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	writeJobAccepted(w, job)
+}
+
+func renameFolder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		SourcePrefix      string `json:"sourcePrefix"`
+		DestinationPrefix string `json:"destinationPrefix"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.SourcePrefix == "" || data.DestinationPrefix == "" {
+		httpError(w, r, http.StatusBadRequest, "rename_prefixes_required")
+		return
+	}
+
+	sourcePrefix := strings.TrimSuffix(data.SourcePrefix, "/") + "/"
+	destinationPrefix := strings.TrimSuffix(data.DestinationPrefix, "/") + "/"
+
+	// Renaming into a subfolder of itself would keep feeding the listing new keys
+	if strings.HasPrefix(destinationPrefix, sourcePrefix) || strings.HasPrefix(sourcePrefix, destinationPrefix) {
+		httpError(w, r, http.StatusBadRequest, "rename_prefixes_overlap")
+		return
+	}
+
+	job, err := startBucketJob(bucketName, "rename-folder", sourcePrefix, func(ctx context.Context, job *jobHandle) error {
+		return walkObjects(ctx, bucketName, sourcePrefix, func(obj types.Object) error {
+			job.AddTotal(1)
+			dstKey := destinationPrefix + strings.TrimPrefix(*obj.Key, sourcePrefix)
+			job.Done(*obj.Key, moveObject(ctx, bucketName, *obj.Key, bucketName, dstKey, renameOptions, job.AddBytes))
+			return nil
+		})
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	writeJobAccepted(w, job)
+}
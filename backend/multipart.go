@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// S3 rejects multipart uploads where any part but the last is smaller than 5 MiB
+	minPartSize = 5 << 20
+	// UploadPartCopy can copy at most 5 GiB per part
+	maxCopyPartSize = 5 << 30
+	// CopyObject refuses sources larger than 5 GiB
+	maxCopyObjectSize = 5 << 30
+	maxUploadParts    = 10000
+	// Streams of unknown length double their part size this often
+	partSizeGrowthInterval = 1000
+)
+
+// multipartWriter assembles an object from byte ranges of other objects.
+// Ranges large enough to form a part on their own are copied server-side,
+// smaller ones are buffered until a valid part can be uploaded.
+type multipartWriter struct {
+	ctx        context.Context
+	bucketName string
+	key        string
+	uploadID   string
+	partSize   int
+	streamOnly bool
+	// growParts lets streams of unknown length go past 10,000 parts of the
+	// initial size
+	growParts bool
+	// ifNoneMatch is passed on completion, "*" refuses to overwrite an
+	// existing object
+	ifNoneMatch *string
+	parts       []types.CompletedPart
+	buffer      bytes.Buffer
+}
+
+func newMultipartWriter(ctx context.Context, bucketName, key string, input *s3.CreateMultipartUploadInput) (*multipartWriter, error) {
+	if input == nil {
+		input = &s3.CreateMultipartUploadInput{}
+	}
+	input.Bucket = aws.String(bucketName)
+	input.Key = aws.String(key)
+
+	result, err := bucketClient(ctx, bucketName).CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &multipartWriter{
+		ctx:        ctx,
+		bucketName: bucketName,
+		key:        key,
+		uploadID:   *result.UploadId,
+		partSize:   minPartSize,
+	}, nil
+}
+
+// AddRange appends the bytes [start, end) of the source object. last must be
+// set for the final range so it may be copied even if it is small.
+func (m *multipartWriter) AddRange(srcBucket, srcKey string, start, end int64, last bool) error {
+	if m.buffer.Len() > 0 {
+		fill := min(int64(minPartSize-m.buffer.Len()), end-start)
+		if err := m.bufferRange(srcBucket, srcKey, start, start+fill); err != nil {
+			return err
+		}
+		start += fill
+
+		if m.buffer.Len() >= minPartSize {
+			if err := m.flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if start == end {
+		return nil
+	}
+
+	if end-start < minPartSize && !(last && m.buffer.Len() == 0) {
+		return m.bufferRange(srcBucket, srcKey, start, end)
+	}
+
+	for start < end {
+		partEnd := min(start+maxCopyPartSize, end)
+		// Never leave a tail that is too small to be a part of its own
+		if end-partEnd > 0 && end-partEnd < minPartSize {
+			partEnd = end - minPartSize
+		}
+
+		if err := m.copyRange(srcBucket, srcKey, start, partEnd); err != nil {
+			return err
+		}
+		start = partEnd
+	}
+
+	return nil
+}
+
+// Write buffers raw bytes which are uploaded once they reach the part size
+func (m *multipartWriter) Write(p []byte) (int, error) {
+	n, _ := m.buffer.Write(p)
+	if m.buffer.Len() >= m.partSize {
+		if err := m.flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+func (m *multipartWriter) bufferRange(srcBucket, srcKey string, start, end int64) error {
+	result, err := bucketClient(m.ctx, srcBucket).GetObject(m.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end-1)),
+	})
+	if err != nil {
+		return err
+	}
+	defer result.Body.Close()
+
+	_, err = m.ReadFrom(result.Body)
+
+	return err
+}
+
+// ReadFrom streams r into the upload, flushing a part whenever the buffer is full
+func (m *multipartWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{m}, r)
+}
+
+func (m *multipartWriter) copyRange(srcBucket, srcKey string, start, end int64) error {
+	if m.streamOnly {
+		return m.bufferRange(srcBucket, srcKey, start, end)
+	}
+
+	partNumber := int32(len(m.parts) + 1)
+
+	result, err := bucketClient(m.ctx, m.bucketName).UploadPartCopy(m.ctx, &s3.UploadPartCopyInput{
+		Bucket:          aws.String(m.bucketName),
+		Key:             aws.String(m.key),
+		UploadId:        aws.String(m.uploadID),
+		PartNumber:      aws.Int32(partNumber),
+		CopySource:      aws.String(copySource(srcBucket, srcKey)),
+		CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end-1)),
+	})
+	if isCopyUnsupported(err) || isCopyDenied(err) {
+		// Once the data went through the buffer every later range has to as
+		// well, otherwise parts would end up out of order
+		m.streamOnly = true
+		return m.bufferRange(srcBucket, srcKey, start, end)
+	}
+	if err != nil {
+		return err
+	}
+
+	m.parts = append(m.parts, types.CompletedPart{
+		ETag:       result.CopyPartResult.ETag,
+		PartNumber: aws.Int32(partNumber),
+	})
+
+	return nil
+}
+
+func (m *multipartWriter) flush() error {
+	partNumber := int32(len(m.parts) + 1)
+
+	result, err := bucketClient(m.ctx, m.bucketName).UploadPart(m.ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(m.bucketName),
+		Key:        aws.String(m.key),
+		UploadId:   aws.String(m.uploadID),
+		PartNumber: aws.Int32(partNumber),
+		Body:       bytes.NewReader(m.buffer.Bytes()),
+	})
+	if err != nil {
+		return err
+	}
+	m.buffer.Reset()
+
+	m.parts = append(m.parts, types.CompletedPart{
+		ETag:       result.ETag,
+		PartNumber: aws.Int32(partNumber),
+	})
+	if m.growParts && len(m.parts)%partSizeGrowthInterval == 0 && m.partSize*2 <= maxCopyPartSize {
+		m.partSize *= 2
+	}
+
+	return nil
+}
+
+func (m *multipartWriter) Complete() error {
+	if m.buffer.Len() > 0 || len(m.parts) == 0 {
+		if err := m.flush(); err != nil {
+			return err
+		}
+	}
+
+	_, err := bucketClient(m.ctx, m.bucketName).CompleteMultipartUpload(m.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(m.bucketName),
+		Key:             aws.String(m.key),
+		UploadId:        aws.String(m.uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: m.parts},
+		IfNoneMatch:     m.ifNoneMatch,
+	})
+
+	return err
+}
+
+func (m *multipartWriter) Abort() {
+	// Use a fresh context, the writer's one may already be cancelled
+	bucketClient(context.Background(), m.bucketName).AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(m.bucketName),
+		Key:      aws.String(m.key),
+		UploadId: aws.String(m.uploadID),
+	})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultSearchLimit = 1000
+	maxSearchLimit     = 10000
+)
+
+var errSearchLimitReached = errors.New("search limit reached")
+
+var sizeUnits = map[string]int64{
+	"":   1,
+	"B":  1,
+	"K":  1 << 10,
+	"KB": 1 << 10,
+	"M":  1 << 20,
+	"MB": 1 << 20,
+	"G":  1 << 30,
+	"GB": 1 << 30,
+	"T":  1 << 40,
+	"TB": 1 << 40,
+}
+
+// objectFilter narrows listings down by size, modification time and name.
+// Unset bounds are zero.
+type objectFilter struct {
+	minSize        int64
+	maxSize        int64
+	modifiedAfter  time.Time
+	modifiedBefore time.Time
+	extensions     []string
+	patterns       []string
+}
+
+// parseSize accepts bytes or a number with a binary unit, e.g. 1GB
+func parseSize(value string) (int64, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	number := strings.TrimRight(value, "KMGTB")
+	multiplier, ok := sizeUnits[value[len(number):]]
+	if !ok {
+		return 0, false
+	}
+
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || parsed < 0 {
+		return 0, false
+	}
+
+	return int64(parsed * float64(multiplier)), true
+}
+
+// parseFilterTime accepts a date, an RFC 3339 timestamp or a number of days
+// before now like 90d
+func parseFilterTime(value string) (time.Time, bool) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		parsed, err := strconv.Atoi(days)
+		if err != nil || parsed < 0 {
+			return time.Time{}, false
+		}
+		return time.Now().AddDate(0, 0, -parsed), true
+	}
+
+	if parsed, err := time.Parse("2006-01-02", value); err == nil {
+		return parsed, true
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, true
+	}
+
+	return time.Time{}, false
+}
+
+func parseObjectFilter(query url.Values) (objectFilter, *apiError) {
+	var filter objectFilter
+
+	for _, bound := range []struct {
+		name  string
+		value *int64
+	}{{"minSize", &filter.minSize}, {"maxSize", &filter.maxSize}} {
+		if value := query.Get(bound.name); value != "" {
+			parsed, ok := parseSize(value)
+			if !ok {
+				return objectFilter{}, newAPIError("filter_size_invalid", bound.name, value)
+			}
+			*bound.value = parsed
+		}
+	}
+
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{{"modifiedAfter", &filter.modifiedAfter}, {"modifiedBefore", &filter.modifiedBefore}} {
+		if value := query.Get(bound.name); value != "" {
+			parsed, ok := parseFilterTime(value)
+			if !ok {
+				return objectFilter{}, newAPIError("filter_time_invalid", bound.name, value)
+			}
+			*bound.value = parsed
+		}
+	}
+
+	for _, value := range query["ext"] {
+		for _, ext := range strings.Split(value, ",") {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				filter.extensions = append(filter.extensions, "."+strings.TrimPrefix(strings.ToLower(ext), "."))
+			}
+		}
+	}
+	filter.patterns = query["pattern"]
+
+	return filter, nil
+}
+
+// Matches checks an object against all bounds. Patterns without a slash are
+// matched against the name of the object, others against the whole key.
+func (f objectFilter) Matches(obj types.Object) bool {
+	size := aws.ToInt64(obj.Size)
+	if size < f.minSize || (f.maxSize > 0 && size > f.maxSize) {
+		return false
+	}
+
+	modified := aws.ToTime(obj.LastModified)
+	if !f.modifiedAfter.IsZero() && !modified.After(f.modifiedAfter) {
+		return false
+	}
+	if !f.modifiedBefore.IsZero() && !modified.Before(f.modifiedBefore) {
+		return false
+	}
+
+	key := aws.ToString(obj.Key)
+	if len(f.extensions) > 0 && !isKnownValue(strings.ToLower(path.Ext(key)), f.extensions) {
+		return false
+	}
+
+	if len(f.patterns) > 0 {
+		matched := false
+		for _, pattern := range f.patterns {
+			value := key
+			if !strings.Contains(pattern, "/") {
+				value = path.Base(key)
+			}
+			if wildcardMatch(pattern, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// findObjects collects up to limit matching objects below prefix and reports
+// whether there were more
+func findObjects(ctx context.Context, bucketName, prefix string, filter objectFilter, limit int) ([]types.Object, bool, error) {
+	objects := []types.Object{}
+	err := walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		if !filter.Matches(obj) {
+			return nil
+		}
+		if len(objects) == limit {
+			return errSearchLimitReached
+		}
+		objects = append(objects, obj)
+		return nil
+	})
+	if errors.Is(err, errSearchLimitReached) {
+		return objects, true, nil
+	}
+
+	return objects, false, err
+}
+
+// parseSearchLimit reads the optional limit parameter of searches
+func parseSearchLimit(value string) (int, *apiError) {
+	if value == "" {
+		return defaultSearchLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > maxSearchLimit {
+		return 0, newAPIError("search_limit_invalid", maxSearchLimit)
+	}
+
+	return limit, nil
+}
+
+// searchObjects finds objects anywhere below a prefix, unlike the listing
+// which shows one folder at a time
+func searchObjects(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	query := r.URL.Query()
+
+	filter, apiErr := parseObjectFilter(query)
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	limit, apiErr := parseSearchLimit(query.Get("limit"))
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	objects, truncated, err := findObjects(r.Context(), bucketName, query.Get("prefix"), filter, limit)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "list_objects_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"objects":   objects,
+		"truncated": truncated,
+	})
+}
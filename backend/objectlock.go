@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+type ObjectLockConfiguration struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode,omitempty"`
+	Days    int32  `json:"days,omitempty"`
+	Years   int32  `json:"years,omitempty"`
+}
+
+type ObjectRetention struct {
+	Mode        string     `json:"mode,omitempty"`
+	RetainUntil *time.Time `json:"retainUntil,omitempty"`
+}
+
+func isNoSuchConfiguration(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return isKnownValue(apiErr.ErrorCode(), codes)
+}
+
+func getObjectLockConfiguration(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetObjectLockConfiguration(r.Context(), &s3.GetObjectLockConfigurationInput{
+		Bucket: aws.String(bucketName),
+	})
+
+	// Buckets created without object lock answer with an error
+	if isNoSuchConfiguration(err, "ObjectLockConfigurationNotFoundError") {
+		json.NewEncoder(w).Encode(ObjectLockConfiguration{})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_object_lock_failed", err)
+		return
+	}
+
+	configuration := ObjectLockConfiguration{}
+	if lock := result.ObjectLockConfiguration; lock != nil {
+		configuration.Enabled = lock.ObjectLockEnabled == types.ObjectLockEnabledEnabled
+		if lock.Rule != nil && lock.Rule.DefaultRetention != nil {
+			configuration.Mode = string(lock.Rule.DefaultRetention.Mode)
+			configuration.Days = aws.ToInt32(lock.Rule.DefaultRetention.Days)
+			configuration.Years = aws.ToInt32(lock.Rule.DefaultRetention.Years)
+		}
+	}
+
+	json.NewEncoder(w).Encode(configuration)
+}
+
+// putObjectLockConfiguration sets the default retention. Object lock can only
+// be enabled on buckets with versioning, and never be disabled again.
+func putObjectLockConfiguration(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data ObjectLockConfiguration
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	configuration := &types.ObjectLockConfiguration{ObjectLockEnabled: types.ObjectLockEnabledEnabled}
+	if data.Mode != "" {
+		if !isKnownValue(data.Mode, types.ObjectLockRetentionMode("").Values()) {
+			httpError(w, r, http.StatusBadRequest, "object_lock_mode_invalid", data.Mode)
+			return
+		}
+		// Exactly one of days and years must be set
+		if (data.Days > 0) == (data.Years > 0) || data.Days < 0 || data.Years < 0 {
+			httpError(w, r, http.StatusBadRequest, "object_lock_period_invalid")
+			return
+		}
+
+		retention := &types.DefaultRetention{Mode: types.ObjectLockRetentionMode(data.Mode)}
+		if data.Days > 0 {
+			retention.Days = aws.Int32(data.Days)
+		} else {
+			retention.Years = aws.Int32(data.Years)
+		}
+		configuration.Rule = &types.ObjectLockRule{DefaultRetention: retention}
+	}
+
+	_, err := bucketClient(r.Context(), bucketName).PutObjectLockConfiguration(r.Context(), &s3.PutObjectLockConfigurationInput{
+		Bucket:                  aws.String(bucketName),
+		ObjectLockConfiguration: configuration,
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_object_lock_failed", err)
+		return
+	}
+
+	audit.Record(r, "object-lock.put", bucketName, "", map[string]interface{}{
+		"mode":  data.Mode,
+		"days":  data.Days,
+		"years": data.Years,
+	})
+
+	data.Enabled = true
+	json.NewEncoder(w).Encode(data)
+}
+
+func optionalVersionID(r *http.Request) *string {
+	if versionID := r.URL.Query().Get("versionId"); versionID != "" {
+		return aws.String(versionID)
+	}
+	return nil
+}
+
+func getObjectRetention(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	result, err := bucketClient(r.Context(), vars["bucketName"]).GetObjectRetention(r.Context(), &s3.GetObjectRetentionInput{
+		Bucket:    aws.String(vars["bucketName"]),
+		Key:       aws.String(vars["objectKey"]),
+		VersionId: optionalVersionID(r),
+	})
+	if isNoSuchConfiguration(err, "NoSuchObjectLockConfiguration") {
+		json.NewEncoder(w).Encode(ObjectRetention{})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_retention_failed", err)
+		return
+	}
+
+	retention := ObjectRetention{}
+	if result.Retention != nil {
+		retention.Mode = string(result.Retention.Mode)
+		retention.RetainUntil = result.Retention.RetainUntilDate
+	}
+
+	json.NewEncoder(w).Encode(retention)
+}
+
+func putObjectRetention(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	var data struct {
+		ObjectRetention
+		// Shortening or removing governance retention needs an explicit bypass
+		BypassGovernance bool `json:"bypassGovernance"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if !isKnownValue(data.Mode, types.ObjectLockRetentionMode("").Values()) {
+		httpError(w, r, http.StatusBadRequest, "object_lock_mode_invalid", data.Mode)
+		return
+	}
+	if data.RetainUntil == nil || !data.RetainUntil.After(time.Now()) {
+		httpError(w, r, http.StatusBadRequest, "retention_date_invalid")
+		return
+	}
+
+	input := &s3.PutObjectRetentionInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(objectKey),
+		VersionId: optionalVersionID(r),
+		Retention: &types.ObjectLockRetention{
+			Mode:            types.ObjectLockRetentionMode(data.Mode),
+			RetainUntilDate: data.RetainUntil,
+		},
+	}
+	if data.BypassGovernance {
+		input.BypassGovernanceRetention = aws.Bool(true)
+	}
+
+	if _, err := bucketClient(r.Context(), bucketName).PutObjectRetention(r.Context(), input); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_retention_failed", err)
+		return
+	}
+
+	audit.Record(r, "object.retention.put", bucketName, objectKey, map[string]interface{}{
+		"mode":             data.Mode,
+		"retainUntil":      data.RetainUntil,
+		"versionId":        r.URL.Query().Get("versionId"),
+		"bypassGovernance": data.BypassGovernance,
+	})
+
+	json.NewEncoder(w).Encode(data.ObjectRetention)
+}
+
+func getObjectLegalHold(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	result, err := bucketClient(r.Context(), vars["bucketName"]).GetObjectLegalHold(r.Context(), &s3.GetObjectLegalHoldInput{
+		Bucket:    aws.String(vars["bucketName"]),
+		Key:       aws.String(vars["objectKey"]),
+		VersionId: optionalVersionID(r),
+	})
+	if isNoSuchConfiguration(err, "NoSuchObjectLockConfiguration") {
+		json.NewEncoder(w).Encode(map[string]string{"status": string(types.ObjectLockLegalHoldStatusOff)})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_legal_hold_failed", err)
+		return
+	}
+
+	status := types.ObjectLockLegalHoldStatusOff
+	if result.LegalHold != nil && result.LegalHold.Status != "" {
+		status = result.LegalHold.Status
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": string(status)})
+}
+
+func setLegalHold(ctx context.Context, bucketName, key string, versionID *string, status types.ObjectLockLegalHoldStatus) error {
+	_, err := bucketClient(ctx, bucketName).PutObjectLegalHold(ctx, &s3.PutObjectLegalHoldInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(key),
+		VersionId: versionID,
+		LegalHold: &types.ObjectLockLegalHold{Status: status},
+	})
+
+	return err
+}
+
+func putObjectLegalHold(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	var data struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if !isKnownValue(data.Status, types.ObjectLockLegalHoldStatus("").Values()) {
+		httpError(w, r, http.StatusBadRequest, "legal_hold_status_invalid", data.Status)
+		return
+	}
+
+	err := setLegalHold(r.Context(), bucketName, objectKey, optionalVersionID(r), types.ObjectLockLegalHoldStatus(data.Status))
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_legal_hold_failed", err)
+		return
+	}
+
+	audit.Record(r, "object.legal-hold.put", bucketName, objectKey, map[string]interface{}{
+		"status":    data.Status,
+		"versionId": r.URL.Query().Get("versionId"),
+	})
+
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Operation is a piece of work currently running against a bucket, either
+// within a request or as part of a background job.
+type Operation struct {
+	ID         string    `json:"id"`
+	BucketName string    `json:"bucketName"`
+	Type       string    `json:"type"`
+	Target     string    `json:"target,omitempty"`
+	JobID      string    `json:"jobId,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+}
+
+type runningOperation struct {
+	Operation
+	cancel context.CancelFunc
+}
+
+type operationTracker struct {
+	mu         sync.Mutex
+	operations map[string]*runningOperation
+}
+
+var operations = &operationTracker{operations: make(map[string]*runningOperation)}
+
+// Begin registers an operation and returns a context that is cancelled when
+// the operation is cancelled through the API. The returned func must be
+// called once the operation is over.
+func (t *operationTracker) Begin(parent context.Context, bucketName, opType, target, jobID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	id, err := randomToken(8)
+	if err != nil {
+		// Untracked operations still run, they just can't be listed or cancelled
+		return ctx, cancel
+	}
+
+	t.mu.Lock()
+	t.operations[id] = &runningOperation{
+		Operation: Operation{
+			ID:         id,
+			BucketName: bucketName,
+			Type:       opType,
+			Target:     target,
+			JobID:      jobID,
+			StartedAt:  time.Now().UTC(),
+		},
+		cancel: cancel,
+	}
+	t.mu.Unlock()
+
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.operations, id)
+		t.mu.Unlock()
+		cancel()
+	}
+}
+
+func (t *operationTracker) List(bucketName string) []Operation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	list := make([]Operation, 0)
+	for _, op := range t.operations {
+		if op.BucketName == bucketName {
+			list = append(list, op.Operation)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.Before(list[j].StartedAt)
+	})
+
+	return list
+}
+
+func (t *operationTracker) Cancel(bucketName, id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	op, ok := t.operations[id]
+	if !ok || op.BucketName != bucketName {
+		return false
+	}
+	op.cancel()
+
+	return true
+}
+
+// startBucketJob starts a job that is listed as an operation of bucketName
+// while it runs. writes names other buckets the job writes to, their cached
+// listings are dropped along with the ones of bucketName.
+func startBucketJob(bucketName, jobType, target string, run jobRun, writes ...string) (Job, error) {
+	return jobs.Start(jobType, func(ctx context.Context, job *jobHandle) error {
+		ctx, done := operations.Begin(ctx, bucketName, jobType, target, job.ID())
+		defer done()
+		// Jobs keep writing after the request that started them returned
+		defer func() {
+			invalidateListings(bucketName)
+			for _, written := range writes {
+				invalidateListings(written)
+			}
+		}()
+
+		return run(ctx, job)
+	})
+}
+
+func listOperations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	json.NewEncoder(w).Encode(operations.List(vars["bucketName"]))
+}
+
+func cancelOperation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	if !operations.Cancel(bucketName, vars["operationId"]) {
+		httpError(w, r, http.StatusNotFound, "operation_not_found")
+		return
+	}
+
+	audit.Record(r, "operation.cancel", bucketName, "", map[string]interface{}{"operationId": vars["operationId"]})
+
+	w.WriteHeader(http.StatusNoContent)
+}
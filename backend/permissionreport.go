@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+var permissionReportColumns = []string{"bucket", "policyGrants", "publicPolicy", "aclGrants", "publicAcl", "publicAccessBlock", "public", "encryption", "kmsKeyId", "errors"}
+
+// BucketPermissions sums up who can access a bucket and how it is encrypted
+type BucketPermissions struct {
+	BucketName   string   `json:"bucketName"`
+	PolicyGrants []string `json:"policyGrants"`
+	PublicPolicy bool     `json:"publicPolicy"`
+	ACLGrants    []string `json:"aclGrants"`
+	PublicACL    bool     `json:"publicAcl"`
+	// PublicAccessBlock is "full", "partial" or "none"
+	PublicAccessBlock string `json:"publicAccessBlock"`
+	// Public is set when a policy or ACL grants public access that the
+	// public access block does not stop
+	Public     bool     `json:"public"`
+	Encryption string   `json:"encryption"`
+	KMSKeyID   string   `json:"kmsKeyId,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+type PermissionReport struct {
+	Region  string              `json:"region"`
+	Buckets []BucketPermissions `json:"buckets"`
+}
+
+// missingConfiguration reports whether err only says the bucket has no such
+// configuration, which is a valid state for the report
+func missingConfiguration(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && slices.Contains(codes, apiErr.ErrorCode())
+}
+
+func describePrincipal(principal policyPrincipal) string {
+	if principal.Matches("*") {
+		return "*"
+	}
+
+	parts := []string{}
+	for kind, values := range principal {
+		parts = append(parts, kind+":"+strings.Join(values, ","))
+	}
+	sort.Strings(parts)
+
+	return strings.Join(parts, " ")
+}
+
+func collectBucketPermissions(ctx context.Context, client *s3.Client, bucketName string) BucketPermissions {
+	permissions := BucketPermissions{BucketName: bucketName, PolicyGrants: []string{}, ACLGrants: []string{}, Encryption: "none"}
+	fail := func(part string, err error) {
+		permissions.Errors = append(permissions.Errors, fmt.Sprintf("%s: %v", part, err))
+	}
+
+	policyResult, err := client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(bucketName)})
+	if err == nil {
+		policy := &BucketPolicy{}
+		if err := json.Unmarshal([]byte(aws.ToString(policyResult.Policy)), policy); err != nil {
+			fail("policy", err)
+		}
+		for _, statement := range policy.Statement {
+			actions := append(append([]string{}, statement.Action...), statement.NotAction...)
+			principal := describePrincipal(statement.Principal)
+			if statement.NotPrincipal != nil {
+				principal = "not " + describePrincipal(statement.NotPrincipal)
+			}
+
+			grant := fmt.Sprintf("%s %s %s", statement.Effect, principal, strings.Join(actions, ","))
+			if len(statement.Condition) > 0 {
+				grant += " (conditional)"
+			}
+			permissions.PolicyGrants = append(permissions.PolicyGrants, grant)
+
+			if strings.EqualFold(statement.Effect, "Allow") && statement.Principal.Matches("*") && len(statement.Condition) == 0 {
+				permissions.PublicPolicy = true
+			}
+		}
+	} else if !missingConfiguration(err, "NoSuchBucketPolicy") {
+		fail("policy", err)
+	}
+
+	aclResult, err := client.GetBucketAcl(ctx, &s3.GetBucketAclInput{Bucket: aws.String(bucketName)})
+	if err == nil {
+		for _, grant := range aclResult.Grants {
+			if grant.Grantee == nil {
+				continue
+			}
+			g := ACLGrant{
+				ID:          aws.ToString(grant.Grantee.ID),
+				DisplayName: aws.ToString(grant.Grantee.DisplayName),
+				Email:       aws.ToString(grant.Grantee.EmailAddress),
+				URI:         aws.ToString(grant.Grantee.URI),
+			}
+			grantee := g.URI
+			for _, name := range []string{g.DisplayName, g.Email, g.ID} {
+				if grantee == "" {
+					grantee = name
+				}
+			}
+			permissions.ACLGrants = append(permissions.ACLGrants, grantee+" "+string(grant.Permission))
+			permissions.PublicACL = permissions.PublicACL || g.Public()
+		}
+	} else {
+		fail("acl", err)
+	}
+
+	blocksPolicies, blocksACLs := false, false
+	permissions.PublicAccessBlock = "none"
+	blockResult, err := client.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(bucketName)})
+	if err == nil && blockResult.PublicAccessBlockConfiguration != nil {
+		block := blockResult.PublicAccessBlockConfiguration
+		settings := []bool{aws.ToBool(block.BlockPublicAcls), aws.ToBool(block.IgnorePublicAcls), aws.ToBool(block.BlockPublicPolicy), aws.ToBool(block.RestrictPublicBuckets)}
+		if slices.Contains(settings, true) {
+			permissions.PublicAccessBlock = "partial"
+		}
+		if !slices.Contains(settings, false) {
+			permissions.PublicAccessBlock = "full"
+		}
+		blocksACLs = aws.ToBool(block.IgnorePublicAcls)
+		blocksPolicies = aws.ToBool(block.RestrictPublicBuckets)
+	} else if err != nil && !missingConfiguration(err, "NoSuchPublicAccessBlockConfiguration") {
+		fail("publicAccessBlock", err)
+	}
+	permissions.Public = (permissions.PublicPolicy && !blocksPolicies) || (permissions.PublicACL && !blocksACLs)
+
+	encryptionResult, err := client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(bucketName)})
+	if err == nil && encryptionResult.ServerSideEncryptionConfiguration != nil {
+		for _, rule := range encryptionResult.ServerSideEncryptionConfiguration.Rules {
+			if rule.ApplyServerSideEncryptionByDefault == nil {
+				continue
+			}
+			permissions.Encryption = string(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+			permissions.KMSKeyID = aws.ToString(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
+		}
+	} else if err != nil && !missingConfiguration(err, "ServerSideEncryptionConfigurationNotFoundError") {
+		fail("encryption", err)
+	}
+
+	return permissions
+}
+
+// getPermissionReport collects policy and ACL grants, the public access
+// block and the default encryption of every bucket in a region, as JSON or
+// as CSV with format=csv. Buckets that cannot be read fully are reported
+// with their errors instead of failing the report.
+func getPermissionReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	region := vars["name"]
+
+	if !slices.Contains(listedRegions(), region) {
+		httpError(w, r, http.StatusNotFound, "region_not_found", region)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		httpError(w, r, http.StatusBadRequest, "report_format_invalid", format)
+		return
+	}
+
+	buckets, err := listRegionBuckets(r.Context(), region)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "list_buckets_failed", err)
+		return
+	}
+
+	report := PermissionReport{Region: region, Buckets: make([]BucketPermissions, len(buckets))}
+	client := regions.Client(region)
+	slots := make(chan struct{}, globalSearchConcurrency)
+	var wg sync.WaitGroup
+	for i, bucket := range buckets {
+		wg.Add(1)
+		go func(i int, bucketName string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			report.Buckets[i] = collectBucketPermissions(r.Context(), client, bucketName)
+		}(i, bucket.Name)
+	}
+	wg.Wait()
+
+	sort.Slice(report.Buckets, func(i, j int) bool {
+		return report.Buckets[i].BucketName < report.Buckets[j].BucketName
+	})
+
+	if format != "csv" {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", attachmentDisposition(region+"-permissions.csv"))
+
+	writer := csv.NewWriter(w)
+	writer.Write(permissionReportColumns)
+	for _, bucket := range report.Buckets {
+		writer.Write([]string{
+			bucket.BucketName,
+			strings.Join(bucket.PolicyGrants, "; "),
+			strconv.FormatBool(bucket.PublicPolicy),
+			strings.Join(bucket.ACLGrants, "; "),
+			strconv.FormatBool(bucket.PublicACL),
+			bucket.PublicAccessBlock,
+			strconv.FormatBool(bucket.Public),
+			bucket.Encryption,
+			bucket.KMSKeyID,
+			strings.Join(bucket.Errors, "; "),
+		})
+	}
+	writer.Flush()
+}
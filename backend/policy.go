@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+// S3 limits bucket policies to 20 KB
+const maxPolicySize = 20 * 1024
+
+// stringOrSlice accepts both forms allowed by the IAM policy grammar
+type stringOrSlice []string
+
+func (s *stringOrSlice) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = stringOrSlice{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = list
+
+	return nil
+}
+
+// policyPrincipal is either "*" or a map like {"AWS": ["arn:..."]}
+type policyPrincipal map[string]stringOrSlice
+
+func (p *policyPrincipal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		*p = policyPrincipal{"*": stringOrSlice{wildcard}}
+		return nil
+	}
+
+	var principals map[string]stringOrSlice
+	if err := json.Unmarshal(data, &principals); err != nil {
+		return err
+	}
+	*p = principals
+
+	return nil
+}
+
+func (p policyPrincipal) Matches(principal string) bool {
+	for _, values := range p {
+		for _, value := range values {
+			if value == "*" || wildcardMatch(value, principal) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type PolicyStatement struct {
+	Sid          string                     `json:"Sid,omitempty"`
+	Effect       string                     `json:"Effect"`
+	Principal    policyPrincipal            `json:"Principal,omitempty"`
+	NotPrincipal policyPrincipal            `json:"NotPrincipal,omitempty"`
+	Action       stringOrSlice              `json:"Action,omitempty"`
+	NotAction    stringOrSlice              `json:"NotAction,omitempty"`
+	Resource     stringOrSlice              `json:"Resource,omitempty"`
+	NotResource  stringOrSlice              `json:"NotResource,omitempty"`
+	Condition    map[string]json.RawMessage `json:"Condition,omitempty"`
+}
+
+type BucketPolicy struct {
+	Version   string            `json:"Version"`
+	ID        string            `json:"Id,omitempty"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+func (p *BucketPolicy) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Version   string          `json:"Version"`
+		ID        string          `json:"Id"`
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	p.Version = raw.Version
+	p.ID = raw.ID
+	p.Statement = nil
+
+	if len(raw.Statement) == 0 {
+		return nil
+	}
+
+	// A single statement may be given without the surrounding array
+	if raw.Statement[0] == '{' {
+		var statement PolicyStatement
+		if err := json.Unmarshal(raw.Statement, &statement); err != nil {
+			return err
+		}
+		p.Statement = []PolicyStatement{statement}
+		return nil
+	}
+
+	return json.Unmarshal(raw.Statement, &p.Statement)
+}
+
+// wildcardMatch implements the case-sensitive * and ? matching used in policies
+func wildcardMatch(pattern, value string) bool {
+	// path.Match treats / specially, policies do not
+	pattern = strings.ReplaceAll(pattern, "/", "\x00")
+	value = strings.ReplaceAll(value, "/", "\x00")
+	pattern = strings.NewReplacer("[", "\\[", "]", "\\]", "\\", "\\\\").Replace(pattern)
+
+	matched, err := path.Match(pattern, value)
+
+	return err == nil && matched
+}
+
+func matchesAny(patterns []string, value string, caseInsensitive bool) bool {
+	for _, pattern := range patterns {
+		if caseInsensitive {
+			if wildcardMatch(strings.ToLower(pattern), strings.ToLower(value)) {
+				return true
+			}
+			continue
+		}
+		if wildcardMatch(pattern, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *PolicyStatement) Applies(principal, action, resource string) bool {
+	if s.Principal != nil && !s.Principal.Matches(principal) {
+		return false
+	}
+	if s.NotPrincipal != nil && s.NotPrincipal.Matches(principal) {
+		return false
+	}
+	// Actions are case-insensitive, resources are not
+	if len(s.Action) > 0 && !matchesAny(s.Action, action, true) {
+		return false
+	}
+	if len(s.NotAction) > 0 && matchesAny(s.NotAction, action, true) {
+		return false
+	}
+	if len(s.Resource) > 0 && !matchesAny(s.Resource, resource, false) {
+		return false
+	}
+	if len(s.NotResource) > 0 && matchesAny(s.NotResource, resource, false) {
+		return false
+	}
+
+	return true
+}
+
+type PolicyStatementMatch struct {
+	Index       int    `json:"index"`
+	Sid         string `json:"sid,omitempty"`
+	Effect      string `json:"effect"`
+	Conditional bool   `json:"conditional"`
+}
+
+type PolicySimulation struct {
+	Decision    string                 `json:"decision"`
+	Resource    string                 `json:"resource"`
+	Statements  []PolicyStatementMatch `json:"statements"`
+	Explanation string                 `json:"explanation"`
+}
+
+// simulatePolicy evaluates a bucket policy the way S3 does: an explicit deny
+// beats any allow, and without a matching allow the request is implicitly denied.
+// Conditions are not evaluated, statements carrying them are flagged instead.
+func simulatePolicy(policy *BucketPolicy, principal, action, resource string) PolicySimulation {
+	result := PolicySimulation{
+		Decision:   "implicitDeny",
+		Resource:   resource,
+		Statements: []PolicyStatementMatch{},
+	}
+
+	var allow, deny *PolicyStatementMatch
+	for i, statement := range policy.Statement {
+		if !statement.Applies(principal, action, resource) {
+			continue
+		}
+
+		match := PolicyStatementMatch{
+			Index:       i,
+			Sid:         statement.Sid,
+			Effect:      statement.Effect,
+			Conditional: len(statement.Condition) > 0,
+		}
+		result.Statements = append(result.Statements, match)
+
+		if strings.EqualFold(statement.Effect, "Deny") && deny == nil {
+			deny = &result.Statements[len(result.Statements)-1]
+		}
+		if strings.EqualFold(statement.Effect, "Allow") && allow == nil {
+			allow = &result.Statements[len(result.Statements)-1]
+		}
+	}
+
+	describe := func(match *PolicyStatementMatch) string {
+		name := fmt.Sprintf("statement #%d", match.Index)
+		if match.Sid != "" {
+			name = fmt.Sprintf("statement %q", match.Sid)
+		}
+		if match.Conditional {
+			name += " (only if its conditions are met)"
+		}
+		return name
+	}
+
+	switch {
+	case deny != nil:
+		result.Decision = "deny"
+		result.Explanation = "Explicitly denied by " + describe(deny)
+	case allow != nil:
+		result.Decision = "allow"
+		result.Explanation = "Allowed by " + describe(allow)
+	default:
+		result.Explanation = "No statement allows this request, it is implicitly denied unless an IAM policy of the principal allows it"
+	}
+
+	return result
+}
+
+func getBucketPolicyDocument(ctx context.Context, bucketName string) (*BucketPolicy, error) {
+	result, err := bucketClient(ctx, bucketName).GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &BucketPolicy{}
+	if err := json.Unmarshal([]byte(aws.ToString(result.Policy)), policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func simulateBucketPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Principal string `json:"principal"`
+		Action    string `json:"action"`
+		Key       string `json:"key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.Principal == "" || data.Action == "" {
+		httpError(w, r, http.StatusBadRequest, "policy_simulation_fields_required")
+		return
+	}
+
+	policy, err := getBucketPolicyDocument(r.Context(), bucketName)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_policy_failed", err)
+		return
+	}
+
+	resource := "arn:aws:s3:::" + bucketName
+	if data.Key != "" {
+		resource += "/" + data.Key
+	}
+
+	json.NewEncoder(w).Encode(simulatePolicy(policy, data.Principal, data.Action, resource))
+}
+
+type PolicyWarning struct {
+	Statement int    `json:"statement"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+type policyFinding struct {
+	statement int
+	err       *apiError
+}
+
+// lintPolicy rejects policies S3 would refuse or misread and collects warnings
+// about statements that are valid but most likely too permissive.
+func lintPolicy(policy *BucketPolicy, bucketName string) (*apiError, []policyFinding) {
+	if policy.Version != "2012-10-17" && policy.Version != "2008-10-17" {
+		return newAPIError("policy_version_invalid", policy.Version), nil
+	}
+	if len(policy.Statement) == 0 {
+		return newAPIError("policy_statements_required"), nil
+	}
+
+	bucketARN := "arn:aws:s3:::" + bucketName
+	sids := make(map[string]bool)
+	var warnings []policyFinding
+
+	for i, statement := range policy.Statement {
+		if !strings.EqualFold(statement.Effect, "Allow") && !strings.EqualFold(statement.Effect, "Deny") {
+			return newAPIError("policy_effect_invalid", i, statement.Effect), nil
+		}
+		if statement.Principal == nil && statement.NotPrincipal == nil {
+			return newAPIError("policy_principal_required", i), nil
+		}
+		if len(statement.Action) == 0 && len(statement.NotAction) == 0 {
+			return newAPIError("policy_action_required", i), nil
+		}
+		if len(statement.Resource) == 0 && len(statement.NotResource) == 0 {
+			return newAPIError("policy_resource_required", i), nil
+		}
+
+		if statement.Sid != "" {
+			if sids[statement.Sid] {
+				return newAPIError("policy_sid_duplicate", statement.Sid), nil
+			}
+			sids[statement.Sid] = true
+		}
+
+		for _, resource := range append(append([]string{}, statement.Resource...), statement.NotResource...) {
+			if resource != bucketARN && !strings.HasPrefix(resource, bucketARN+"/") {
+				warnings = append(warnings, policyFinding{i, newAPIError("policy_resource_foreign", resource)})
+			}
+		}
+
+		if !strings.EqualFold(statement.Effect, "Allow") {
+			continue
+		}
+		if statement.Principal.Matches("*") && len(statement.Condition) == 0 {
+			warnings = append(warnings, policyFinding{i, newAPIError("policy_public_access")})
+		}
+		for _, action := range statement.Action {
+			if action == "*" || strings.EqualFold(action, "s3:*") {
+				warnings = append(warnings, policyFinding{i, newAPIError("policy_all_actions")})
+				break
+			}
+		}
+		if len(statement.NotAction) > 0 || statement.NotPrincipal != nil {
+			warnings = append(warnings, policyFinding{i, newAPIError("policy_allow_with_not")})
+		}
+	}
+
+	return nil, warnings
+}
+
+func localizeWarnings(r *http.Request, findings []policyFinding) []PolicyWarning {
+	lang := negotiateLanguage(r.Header.Get("Accept-Language"))
+
+	warnings := make([]PolicyWarning, 0, len(findings))
+	for _, finding := range findings {
+		warnings = append(warnings, PolicyWarning{
+			Statement: finding.statement,
+			Code:      finding.err.code,
+			Message:   translate(lang, finding.err.code, finding.err.args...),
+		})
+	}
+
+	return warnings
+}
+
+func getBucketPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetBucketPolicy(r.Context(), &s3.GetBucketPolicyInput{
+		Bucket: aws.String(bucketName),
+	})
+
+	// Buckets without a policy answer with an error instead of an empty document
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucketPolicy" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"policy":   nil,
+			"warnings": []PolicyWarning{},
+		})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_policy_failed", err)
+		return
+	}
+
+	document := aws.ToString(result.Policy)
+
+	// Existing policies are shown as they are, linting only adds hints
+	warnings := []PolicyWarning{}
+	policy := &BucketPolicy{}
+	if err := json.Unmarshal([]byte(document), policy); err == nil {
+		if _, findings := lintPolicy(policy, bucketName); findings != nil {
+			warnings = localizeWarnings(r, findings)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy":   json.RawMessage(document),
+		"warnings": warnings,
+	})
+}
+
+func putBucketPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	document, err := io.ReadAll(io.LimitReader(r.Body, maxPolicySize+1))
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+	if len(document) > maxPolicySize {
+		httpError(w, r, http.StatusBadRequest, "policy_too_large", maxPolicySize)
+		return
+	}
+
+	policy := &BucketPolicy{}
+	if err := json.Unmarshal(document, policy); err != nil {
+		httpError(w, r, http.StatusBadRequest, "policy_invalid_json", err)
+		return
+	}
+
+	apiErr, findings := lintPolicy(policy, bucketName)
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	_, err = bucketClient(r.Context(), bucketName).PutBucketPolicy(r.Context(), &s3.PutBucketPolicyInput{
+		Bucket: aws.String(bucketName),
+		Policy: aws.String(string(document)),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_policy_failed", err)
+		return
+	}
+
+	audit.Record(r, "policy.put", bucketName, "", map[string]interface{}{"statements": len(policy.Statement)})
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy":   json.RawMessage(document),
+		"warnings": localizeWarnings(r, findings),
+	})
+}
+
+func deleteBucketPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	_, err := bucketClient(r.Context(), bucketName).DeleteBucketPolicy(r.Context(), &s3.DeleteBucketPolicyInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "delete_policy_failed", err)
+		return
+	}
+
+	audit.Record(r, "policy.delete", bucketName, "", nil)
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPresignExpiry = time.Hour
+	// SigV4 presigned URLs are valid for at most seven days
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+func parsePresignExpiry(value string) (time.Duration, *apiError) {
+	if value == "" {
+		return defaultPresignExpiry, nil
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0, newAPIError("expires_invalid")
+	}
+
+	expiry := time.Duration(seconds) * time.Second
+	if expiry > maxPresignExpiry {
+		return 0, newAPIError("expires_too_long", int(maxPresignExpiry.Seconds()))
+	}
+
+	return expiry, nil
+}
+
+func presignObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	expiry, apiErr := parsePresignExpiry(r.URL.Query().Get("expires"))
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	presignClient := s3.NewPresignClient(bucketClient(r.Context(), bucketName))
+	request, err := presignClient.PresignGetObject(r.Context(), &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "presign_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"url":       request.URL,
+		"expiresAt": time.Now().Add(expiry).UTC(),
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// previewContentType prefers the stored type over guessing from the extension,
+// unless the stored one is just a generic default set by an upload tool
+func previewContentType(stored, key string) string {
+	switch stored {
+	case "", "application/octet-stream", "binary/octet-stream":
+		if detected := mime.TypeByExtension(path.Ext(key)); detected != "" {
+			return detected
+		}
+		return "application/octet-stream"
+	}
+
+	return stored
+}
+
+// previewObject serves an object inline so the browser can render it. Range
+// requests are passed through, e.g. for seeking in videos, full responses are
+// limited to preview.max_size_mb.
+func previewObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	ctx := r.Context()
+	head, err := bucketClient(ctx, bucketName).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucketName),
+		Key:          aws.String(objectKey),
+		VersionId:    optionalVersionID(r),
+		RequestPayer: requestPayer(ctx, bucketName),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		httpError(w, r, http.StatusNotFound, "object_not_found", err)
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "download_failed", err)
+		return
+	}
+
+	maxSize := int64(appConfig.Preview.MaxSizeMB) << 20
+	if r.Header.Get("Range") == "" && aws.ToInt64(head.ContentLength) > maxSize {
+		httpError(w, r, http.StatusRequestEntityTooLarge, "preview_too_large", appConfig.Preview.MaxSizeMB)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", previewContentType(aws.ToString(head.ContentType), objectKey))
+	// HTML and SVG objects must not run scripts in the context of the admin UI
+	header.Set("Content-Security-Policy", "sandbox")
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	disposition := fmt.Sprintf("inline; filename=%q", path.Base(strings.TrimSuffix(objectKey, "/")))
+	streamObject(w, r, &s3.GetObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(objectKey),
+		VersionId: optionalVersionID(r),
+	}, disposition)
+}
@@ -0,0 +1,35 @@
+package main
+
+// defaultStoragePrices are the monthly USD prices per GB of us-east-1
+var defaultStoragePrices = map[string]float64{
+	"STANDARD":            0.023,
+	"INTELLIGENT_TIERING": 0.023,
+	"STANDARD_IA":         0.0125,
+	"ONEZONE_IA":          0.01,
+	"GLACIER_IR":          0.004,
+	"GLACIER":             0.0036,
+	"DEEP_ARCHIVE":        0.00099,
+	"REDUCED_REDUNDANCY":  0.024,
+}
+
+// storagePrice looks the storage class up in the configured price table of
+// the region, then in the configured default table and finally in the
+// built-in us-east-1 prices
+func storagePrice(region, storageClass string) float64 {
+	// Listings leave the storage class empty for objects in STANDARD
+	if storageClass == "" {
+		storageClass = "STANDARD"
+	}
+
+	for _, table := range []string{region, "default"} {
+		if price, ok := appConfig.Pricing.Regions[table][storageClass]; ok {
+			return price
+		}
+	}
+
+	return defaultStoragePrices[storageClass]
+}
+
+func monthlyStorageCost(region, storageClass string, bytes int64) float64 {
+	return float64(bytes) / (1 << 30) * storagePrice(region, storageClass)
+}
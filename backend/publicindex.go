@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+var publicIndexes *recordStore[PublicIndex]
+
+var publicIndexNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// PublicIndex publishes a prefix for anonymous browsing and downloads. The
+// name is part of the public URL and hides bucket and prefix.
+type PublicIndex struct {
+	Name       string    `json:"name"`
+	BucketName string    `json:"bucketName"`
+	Prefix     string    `json:"prefix"`
+	CreatedBy  string    `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type PublicIndexEntry struct {
+	Name         string     `json:"name"`
+	Path         string     `json:"path"`
+	Folder       bool       `json:"folder"`
+	Size         int64      `json:"size,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+}
+
+type PublicIndexListing struct {
+	Name              string             `json:"name"`
+	Path              string             `json:"path"`
+	Entries           []PublicIndexEntry `json:"entries"`
+	ContinuationToken string             `json:"continuationToken,omitempty"`
+}
+
+func newPublicIndexStore(dataDir string) (*recordStore[PublicIndex], error) {
+	return newRecordStore(dataDir, "public-indexes.json", func(index *PublicIndex) string {
+		return index.Name
+	})
+}
+
+type clientAllowance struct {
+	tokens  float64
+	checked time.Time
+}
+
+// clientRateLimiter gives every client IP a bucket of tokens that refills at
+// the configured rate per minute, up to one minute's worth
+type clientRateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*clientAllowance
+}
+
+var publicIndexLimiter = &clientRateLimiter{clients: make(map[string]*clientAllowance)}
+
+func (l *clientRateLimiter) Allow(client string, perMinute int) bool {
+	if perMinute <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	allowance, ok := l.clients[client]
+	if !ok {
+		// Clients idle for a minute are back at a full bucket, so they can
+		// be forgotten instead of refilled
+		for name, idle := range l.clients {
+			if now.Sub(idle.checked) > time.Minute {
+				delete(l.clients, name)
+			}
+		}
+		allowance = &clientAllowance{tokens: float64(perMinute), checked: now}
+		l.clients[client] = allowance
+	}
+
+	allowance.tokens = min(float64(perMinute), allowance.tokens+now.Sub(allowance.checked).Minutes()*float64(perMinute))
+	allowance.checked = now
+	if allowance.tokens < 1 {
+		return false
+	}
+	allowance.tokens--
+
+	return true
+}
+
+// findPublicIndex resolves the index of a public request and applies the
+// rate limit, answering the request itself when it cannot go on
+func findPublicIndex(w http.ResponseWriter, r *http.Request) (PublicIndex, bool) {
+	client := "unknown"
+	if ip := clientIP(r); ip != nil {
+		client = ip.String()
+	}
+	if !publicIndexLimiter.Allow(client, appConfig.PublicIndexes.RequestsPerMinute) {
+		w.Header().Set("Retry-After", "60")
+		httpError(w, r, http.StatusTooManyRequests, "public_index_rate_limited")
+		return PublicIndex{}, false
+	}
+
+	index, found := publicIndexes.Get(mux.Vars(r)["name"])
+	if !found {
+		httpError(w, r, http.StatusNotFound, "public_index_not_found")
+		return PublicIndex{}, false
+	}
+
+	return index, true
+}
+
+func listPublicIndexes(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(publicIndexes.List(func(a, b *PublicIndex) bool {
+		return a.Name < b.Name
+	}))
+}
+
+func createPublicIndex(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		Name       string `json:"name"`
+		BucketName string `json:"bucketName"`
+		Prefix     string `json:"prefix"`
+		CreatedBy  string `json:"createdBy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if !publicIndexNamePattern.MatchString(data.Name) {
+		httpError(w, r, http.StatusBadRequest, "public_index_name_invalid", data.Name)
+		return
+	}
+	if data.BucketName == "" {
+		httpError(w, r, http.StatusBadRequest, "bucket_name_required")
+		return
+	}
+	if _, exists := publicIndexes.Get(data.Name); exists {
+		httpError(w, r, http.StatusConflict, "public_index_exists", data.Name)
+		return
+	}
+
+	prefix := strings.TrimPrefix(data.Prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	index := PublicIndex{
+		Name:       data.Name,
+		BucketName: data.BucketName,
+		Prefix:     prefix,
+		CreatedBy:  data.CreatedBy,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := publicIndexes.Put(index); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "public_index_create_failed", err)
+		return
+	}
+
+	audit.Record(r, "public_index.create", index.BucketName, index.Prefix, map[string]interface{}{"name": index.Name})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(index)
+}
+
+func deletePublicIndex(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	found, err := publicIndexes.Delete(vars["name"])
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "public_index_delete_failed", err)
+		return
+	}
+	if !found {
+		httpError(w, r, http.StatusNotFound, "public_index_not_found")
+		return
+	}
+
+	audit.Record(r, "public_index.delete", "", "", map[string]interface{}{"name": vars["name"]})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// browsePublicIndex lists one folder of a public index. Paths are relative
+// to the published prefix, the bucket is never revealed.
+func browsePublicIndex(w http.ResponseWriter, r *http.Request) {
+	index, ok := findPublicIndex(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+
+	folder := strings.TrimPrefix(query.Get("path"), "/")
+	if folder != "" && !strings.HasSuffix(folder, "/") {
+		folder += "/"
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:       aws.String(index.BucketName),
+		Prefix:       aws.String(index.Prefix + folder),
+		Delimiter:    aws.String("/"),
+		RequestPayer: requestPayer(r.Context(), index.BucketName),
+	}
+	if token := query.Get("continuationToken"); token != "" {
+		input.ContinuationToken = aws.String(token)
+	}
+
+	result, err := bucketClient(r.Context(), index.BucketName).ListObjectsV2(r.Context(), input)
+	if err != nil {
+		log.Printf("failed to list public index %s: %v", index.Name, err)
+		httpError(w, r, http.StatusInternalServerError, "public_index_list_failed")
+		return
+	}
+
+	listing := PublicIndexListing{Name: index.Name, Path: folder, Entries: []PublicIndexEntry{}}
+	for _, p := range result.CommonPrefixes {
+		relative := strings.TrimPrefix(aws.ToString(p.Prefix), index.Prefix)
+		listing.Entries = append(listing.Entries, PublicIndexEntry{
+			Name:   path.Base(relative) + "/",
+			Path:   relative,
+			Folder: true,
+		})
+	}
+	for _, obj := range result.Contents {
+		key := aws.ToString(obj.Key)
+		// Folder placeholders are already listed as folders by their parent
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+		relative := strings.TrimPrefix(key, index.Prefix)
+		listing.Entries = append(listing.Entries, PublicIndexEntry{
+			Name:         path.Base(relative),
+			Path:         relative,
+			Size:         aws.ToInt64(obj.Size),
+			LastModified: obj.LastModified,
+		})
+	}
+	if aws.ToBool(result.IsTruncated) {
+		listing.ContinuationToken = aws.ToString(result.NextContinuationToken)
+	}
+
+	json.NewEncoder(w).Encode(listing)
+}
+
+func downloadPublicIndexFile(w http.ResponseWriter, r *http.Request) {
+	index, ok := findPublicIndex(w, r)
+	if !ok {
+		return
+	}
+
+	// Errors of S3 would name the bucket, so missing files are answered here
+	filePath := mux.Vars(r)["filePath"]
+	_, err := bucketClient(r.Context(), index.BucketName).HeadObject(r.Context(), &s3.HeadObjectInput{
+		Bucket:       aws.String(index.BucketName),
+		Key:          aws.String(index.Prefix + filePath),
+		RequestPayer: requestPayer(r.Context(), index.BucketName),
+	})
+	if strings.HasSuffix(filePath, "/") || err != nil {
+		httpError(w, r, http.StatusNotFound, "public_index_file_not_found", filePath)
+		return
+	}
+
+	streamObject(w, r, &s3.GetObjectInput{
+		Bucket:       aws.String(index.BucketName),
+		Key:          aws.String(index.Prefix + filePath),
+		RequestPayer: requestPayer(r.Context(), index.BucketName),
+	}, attachmentDisposition(path.Base(filePath)))
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultRecommendationWindow = 30
+	// Reading more log files than this would make the report take hours
+	maxAccessLogObjects = 5000
+)
+
+type PrefixRecommendation struct {
+	Prefix                  string  `json:"prefix"`
+	Objects                 int64   `json:"objects"`
+	Bytes                   int64   `json:"bytes"`
+	StandardBytes           int64   `json:"standardBytes"`
+	Accesses                int64   `json:"accesses"`
+	OldestAgeDays           int     `json:"oldestAgeDays"`
+	MedianAgeDays           int     `json:"medianAgeDays"`
+	RecommendedClass        string  `json:"recommendedClass,omitempty"`
+	TransitionAfterDays     int     `json:"transitionAfterDays,omitempty"`
+	EstimatedMonthlySavings float64 `json:"estimatedMonthlySavings"`
+	Reason                  string  `json:"reason"`
+	ages                    []int
+}
+
+type RecommendationReport struct {
+	BucketName              string                  `json:"bucketName"`
+	Prefix                  string                  `json:"prefix"`
+	WindowDays              int                     `json:"windowDays"`
+	AccessLogsAvailable     bool                    `json:"accessLogsAvailable"`
+	AccessLogObjectsRead    int                     `json:"accessLogObjectsRead"`
+	EstimatedMonthlySavings float64                 `json:"estimatedMonthlySavings"`
+	Prefixes                []*PrefixRecommendation `json:"prefixes"`
+}
+
+// logFields splits a server access log line, keeping [bracketed] and "quoted" values together
+func logFields(line string) []string {
+	var fields []string
+	for len(line) > 0 {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+
+		var end int
+		switch line[0] {
+		case '[':
+			end = strings.IndexByte(line, ']') + 1
+		case '"':
+			end = strings.IndexByte(line[1:], '"') + 2
+		default:
+			end = strings.IndexByte(line, ' ')
+		}
+		if end <= 0 || end > len(line) {
+			end = len(line)
+		}
+
+		fields = append(fields, strings.Trim(line[:end], "[]\""))
+		line = line[end:]
+	}
+
+	return fields
+}
+
+// recommendationGroup maps a key to the first folder level below prefix
+func recommendationGroup(prefix, key string) string {
+	rest := strings.TrimPrefix(key, prefix)
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		return prefix + rest[:i+1]
+	}
+
+	return prefix
+}
+
+// countAccesses reads the bucket's server access logs of the last window days and
+// counts object reads per group. It reports false if logging is not enabled.
+func countAccesses(ctx context.Context, bucketName, prefix string, window int) (map[string]int64, int, bool, error) {
+	logging, err := bucketClient(ctx, bucketName).GetBucketLogging(ctx, &s3.GetBucketLoggingInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil || logging.LoggingEnabled == nil {
+		return nil, 0, false, nil
+	}
+
+	target := logging.LoggingEnabled
+	since := time.Now().AddDate(0, 0, -window)
+	accesses := make(map[string]int64)
+	read := 0
+
+	err = walkObjects(ctx, aws.ToString(target.TargetBucket), aws.ToString(target.TargetPrefix), func(obj types.Object) error {
+		if read >= maxAccessLogObjects || aws.ToTime(obj.LastModified).Before(since) {
+			return nil
+		}
+		read++
+
+		result, err := bucketClient(ctx, aws.ToString(target.TargetBucket)).GetObject(ctx, &s3.GetObjectInput{
+			Bucket: target.TargetBucket,
+			Key:    obj.Key,
+		})
+		if err != nil {
+			return err
+		}
+		defer result.Body.Close()
+
+		scanner := bufio.NewScanner(result.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			fields := logFields(scanner.Text())
+			// owner, bucket, time, remote ip, requester, request id, operation, key
+			if len(fields) < 8 || fields[1] != bucketName || fields[6] != "REST.GET.OBJECT" {
+				continue
+			}
+			if !strings.HasPrefix(fields[7], prefix) {
+				continue
+			}
+			accesses[recommendationGroup(prefix, fields[7])]++
+		}
+
+		return scanner.Err()
+	})
+
+	return accesses, read, true, err
+}
+
+func recommend(group *PrefixRecommendation, region string, logsAvailable bool) {
+	if group.StandardBytes == 0 {
+		group.Reason = "Nothing stored in STANDARD"
+		return
+	}
+
+	sort.Ints(group.ages)
+	group.MedianAgeDays = group.ages[len(group.ages)/2]
+	group.OldestAgeDays = group.ages[len(group.ages)-1]
+
+	switch {
+	case logsAvailable && group.Accesses == 0 && group.MedianAgeDays >= 90:
+		group.RecommendedClass = "GLACIER_IR"
+		group.TransitionAfterDays = 90
+		group.Reason = "No reads in the analysed window and most objects are older than 90 days"
+	case logsAvailable && group.Accesses < group.Objects/10 && group.MedianAgeDays >= 30:
+		group.RecommendedClass = "STANDARD_IA"
+		group.TransitionAfterDays = 30
+		group.Reason = "Less than one read per ten objects in the analysed window"
+	case !logsAvailable && group.MedianAgeDays >= 90:
+		group.RecommendedClass = "STANDARD_IA"
+		group.TransitionAfterDays = 90
+		group.Reason = "Most objects are older than 90 days, enable access logging for a more precise recommendation"
+	default:
+		group.Reason = "Data is accessed frequently or too young to transition"
+		return
+	}
+
+	group.EstimatedMonthlySavings = monthlyStorageCost(region, "STANDARD", group.StandardBytes) - monthlyStorageCost(region, group.RecommendedClass, group.StandardBytes)
+}
+
+func buildRecommendations(ctx context.Context, job *jobHandle, bucketName, prefix string, window int) (*RecommendationReport, error) {
+	accesses, read, logsAvailable, err := countAccesses(ctx, bucketName, prefix, window)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	groups := make(map[string]*PrefixRecommendation)
+
+	err = walkObjects(ctx, bucketName, prefix, func(obj types.Object) error {
+		name := recommendationGroup(prefix, *obj.Key)
+		group, ok := groups[name]
+		if !ok {
+			group = &PrefixRecommendation{Prefix: name, Accesses: accesses[name]}
+			groups[name] = group
+		}
+
+		size := aws.ToInt64(obj.Size)
+		group.Objects++
+		group.Bytes += size
+		if obj.StorageClass == "" || obj.StorageClass == types.ObjectStorageClassStandard {
+			group.StandardBytes += size
+			group.ages = append(group.ages, int(now.Sub(aws.ToTime(obj.LastModified)).Hours()/24))
+		}
+
+		job.Advance(1)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RecommendationReport{
+		BucketName:           bucketName,
+		Prefix:               prefix,
+		WindowDays:           window,
+		AccessLogsAvailable:  logsAvailable,
+		AccessLogObjectsRead: read,
+		Prefixes:             []*PrefixRecommendation{},
+	}
+	region := regions.Locate(ctx, bucketName)
+	for _, group := range groups {
+		recommend(group, region, logsAvailable)
+		report.EstimatedMonthlySavings += group.EstimatedMonthlySavings
+		report.Prefixes = append(report.Prefixes, group)
+	}
+	sort.Slice(report.Prefixes, func(i, j int) bool {
+		return report.Prefixes[i].EstimatedMonthlySavings > report.Prefixes[j].EstimatedMonthlySavings
+	})
+
+	return report, nil
+}
+
+func createRecommendations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Prefix     string `json:"prefix"`
+		WindowDays int    `json:"windowDays"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+	if data.WindowDays <= 0 {
+		data.WindowDays = defaultRecommendationWindow
+	}
+
+	job, err := startBucketJob(bucketName, "recommendations", data.Prefix, func(ctx context.Context, job *jobHandle) error {
+		report, err := buildRecommendations(ctx, job, bucketName, data.Prefix, data.WindowDays)
+		if err != nil {
+			return err
+		}
+		job.SetResult(report)
+		return nil
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	writeJobAccepted(w, job)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// A bucket that could not be located is assumed in the configured region
+// for this long before it is asked for again
+const locateRetryInterval = time.Minute
+
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// inRegion sends a single call to another AWS region with the configured
+// credentials, instead of keeping a client per region around
+func inRegion(region string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.Region = region
+	}
+}
+
+// bucketConfiguration builds the location constraint for CreateBucket. AWS
+// rejects us-east-1 as an explicit constraint, it is the default.
+func bucketConfiguration(region string) *types.CreateBucketConfiguration {
+	if region == "" || region == "us-east-1" {
+		return nil
+	}
+
+	return &types.CreateBucketConfiguration{
+		LocationConstraint: types.BucketLocationConstraint(region),
+	}
+}
+
+// regionDirectory remembers where buckets live and keeps a client per region,
+// so with aws.auto_discover one credential serves buckets in every region
+type regionDirectory struct {
+	mu       sync.Mutex
+	clients  map[string]*s3.Client
+	location map[string]string
+	// failed holds when buckets that could not be located may be retried
+	failed map[string]time.Time
+}
+
+var regions = &regionDirectory{
+	clients:  make(map[string]*s3.Client),
+	location: make(map[string]string),
+	failed:   make(map[string]time.Time),
+}
+
+func (d *regionDirectory) remember(bucketName, region string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.location[bucketName] = region
+	delete(d.failed, bucketName)
+}
+
+// forget drops the location of a deleted bucket, its name may be taken again
+// in another region
+func (d *regionDirectory) forget(bucketName string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.location, bucketName)
+	delete(d.failed, bucketName)
+}
+
+// Locate returns the region of bucketName. A location is asked for once and
+// kept until this server deletes or creates the bucket.
+func (d *regionDirectory) Locate(ctx context.Context, bucketName string) string {
+	d.mu.Lock()
+	region, ok := d.location[bucketName]
+	retryAt, failed := d.failed[bucketName]
+	d.mu.Unlock()
+	if ok {
+		return region
+	}
+	if failed && time.Now().Before(retryAt) {
+		return awsRegion
+	}
+
+	region, err := bucketRegion(ctx, bucketName)
+	if err != nil {
+		log.Printf("failed to locate bucket %s, using %s: %v", bucketName, awsRegion, err)
+		d.mu.Lock()
+		d.failed[bucketName] = time.Now().Add(locateRetryInterval)
+		d.mu.Unlock()
+		return awsRegion
+	}
+	d.remember(bucketName, region)
+
+	return region
+}
+
+// Client returns a client for region, sharing the configured one's settings
+func (d *regionDirectory) Client(region string) *s3.Client {
+	if region == "" || region == awsRegion {
+		return s3Client
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	client, ok := d.clients[region]
+	if !ok {
+		client = s3.New(s3Client.Options(), inRegion(region))
+		d.clients[region] = client
+	}
+
+	return client
+}
+
+// Discovered lists the regions of all buckets located so far
+func (d *regionDirectory) Discovered() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	seen := map[string]bool{awsRegion: true}
+	for _, region := range d.location {
+		seen[region] = true
+	}
+
+	list := make([]string, 0, len(seen))
+	for region := range seen {
+		list = append(list, region)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// bucketClient is the client to use for calls addressing bucketName. Without
+// auto discovery, all buckets are expected in the configured region.
+func bucketClient(ctx context.Context, bucketName string) *s3.Client {
+	if !appConfig.AWS.AutoDiscover {
+		return s3Client
+	}
+
+	return regions.Client(regions.Locate(ctx, bucketName))
+}
+
+// annotateBucketRegions fills in the region of every listed bucket. AWS sends
+// it along with the listing, other buckets are located one by one.
+func annotateBucketRegions(ctx context.Context, buckets []types.Bucket) {
+	for i := range buckets {
+		name := aws.ToString(buckets[i].Name)
+		if buckets[i].BucketRegion != nil {
+			regions.remember(name, *buckets[i].BucketRegion)
+			continue
+		}
+		buckets[i].BucketRegion = aws.String(regions.Locate(ctx, name))
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxReplicationRules = 1000
+	bucketARNPrefix     = "arn:aws:s3:::"
+)
+
+type ReplicationDestination struct {
+	// Bucket is the destination ARN, a plain bucket name is turned into one
+	Bucket        string `json:"bucket"`
+	Account       string `json:"account,omitempty"`
+	StorageClass  string `json:"storageClass,omitempty"`
+	OwnerOverride bool   `json:"ownerOverride,omitempty"`
+}
+
+type ReplicationRule struct {
+	ID                      string                 `json:"id"`
+	Enabled                 bool                   `json:"enabled"`
+	Priority                int32                  `json:"priority"`
+	Prefix                  string                 `json:"prefix,omitempty"`
+	Tags                    map[string]string      `json:"tags,omitempty"`
+	DeleteMarkerReplication bool                   `json:"deleteMarkerReplication"`
+	Destination             ReplicationDestination `json:"destination"`
+}
+
+type ReplicationConfiguration struct {
+	Role  string            `json:"role"`
+	Rules []ReplicationRule `json:"rules"`
+}
+
+func (c *ReplicationConfiguration) Validate() *apiError {
+	if !strings.HasPrefix(c.Role, "arn:") {
+		return newAPIError("replication_role_invalid", c.Role)
+	}
+	if len(c.Rules) > maxReplicationRules {
+		return newAPIError("replication_too_many_rules", maxReplicationRules)
+	}
+
+	ids := map[string]bool{}
+	priorities := map[int32]bool{}
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.ID == "" || ids[rule.ID] {
+			return newAPIError("replication_rule_id_invalid", rule.ID)
+		}
+		ids[rule.ID] = true
+
+		// S3 needs distinct priorities to decide between overlapping rules
+		if priorities[rule.Priority] {
+			return newAPIError("replication_priority_duplicate", rule.Priority)
+		}
+		priorities[rule.Priority] = true
+
+		if apiErr := validateTags(rule.Tags, maxObjectTags); apiErr != nil {
+			return apiErr
+		}
+
+		if rule.Destination.Bucket == "" {
+			return newAPIError("replication_destination_required", rule.ID)
+		}
+		if !strings.HasPrefix(rule.Destination.Bucket, bucketARNPrefix) {
+			rule.Destination.Bucket = bucketARNPrefix + rule.Destination.Bucket
+		}
+		if rule.Destination.StorageClass != "" && !isKnownValue(rule.Destination.StorageClass, types.StorageClass("").Values()) {
+			return newAPIError("replication_storage_class_invalid", rule.Destination.StorageClass)
+		}
+		// Changing the owner of replicas needs the account they are handed to
+		if rule.Destination.OwnerOverride && rule.Destination.Account == "" {
+			return newAPIError("replication_account_required", rule.ID)
+		}
+	}
+
+	return nil
+}
+
+func (r *ReplicationRule) toS3() types.ReplicationRule {
+	rule := types.ReplicationRule{
+		ID:       aws.String(r.ID),
+		Priority: aws.Int32(r.Priority),
+		Status:   types.ReplicationRuleStatusDisabled,
+		DeleteMarkerReplication: &types.DeleteMarkerReplication{
+			Status: types.DeleteMarkerReplicationStatusDisabled,
+		},
+		Destination: &types.Destination{Bucket: aws.String(r.Destination.Bucket)},
+	}
+	if r.Enabled {
+		rule.Status = types.ReplicationRuleStatusEnabled
+	}
+	if r.DeleteMarkerReplication {
+		rule.DeleteMarkerReplication.Status = types.DeleteMarkerReplicationStatusEnabled
+	}
+
+	// A prefix and tags together have to be combined with And
+	switch {
+	case len(r.Tags) > 1 || (len(r.Tags) == 1 && r.Prefix != ""):
+		rule.Filter = &types.ReplicationRuleFilter{And: &types.ReplicationRuleAndOperator{
+			Prefix: aws.String(r.Prefix),
+			Tags:   tagsFromMap(r.Tags),
+		}}
+	case len(r.Tags) == 1:
+		rule.Filter = &types.ReplicationRuleFilter{Tag: &tagsFromMap(r.Tags)[0]}
+	default:
+		rule.Filter = &types.ReplicationRuleFilter{Prefix: aws.String(r.Prefix)}
+	}
+
+	if r.Destination.Account != "" {
+		rule.Destination.Account = aws.String(r.Destination.Account)
+	}
+	if r.Destination.StorageClass != "" {
+		rule.Destination.StorageClass = types.StorageClass(r.Destination.StorageClass)
+	}
+	if r.Destination.OwnerOverride {
+		rule.Destination.AccessControlTranslation = &types.AccessControlTranslation{
+			Owner: types.OwnerOverrideDestination,
+		}
+	}
+
+	return rule
+}
+
+func replicationRuleFromS3(rule types.ReplicationRule) ReplicationRule {
+	result := ReplicationRule{
+		ID:       aws.ToString(rule.ID),
+		Enabled:  rule.Status == types.ReplicationRuleStatusEnabled,
+		Priority: aws.ToInt32(rule.Priority),
+		// Rules written with the first schema version carry their prefix directly
+		Prefix: aws.ToString(rule.Prefix),
+	}
+
+	if filter := rule.Filter; filter != nil {
+		switch {
+		case filter.And != nil:
+			result.Prefix = aws.ToString(filter.And.Prefix)
+			result.Tags = tagsToMap(filter.And.Tags)
+		case filter.Tag != nil:
+			result.Tags = tagsToMap([]types.Tag{*filter.Tag})
+		default:
+			result.Prefix = aws.ToString(filter.Prefix)
+		}
+	}
+
+	if rule.DeleteMarkerReplication != nil {
+		result.DeleteMarkerReplication = rule.DeleteMarkerReplication.Status == types.DeleteMarkerReplicationStatusEnabled
+	}
+
+	if destination := rule.Destination; destination != nil {
+		result.Destination = ReplicationDestination{
+			Bucket:        aws.ToString(destination.Bucket),
+			Account:       aws.ToString(destination.Account),
+			StorageClass:  string(destination.StorageClass),
+			OwnerOverride: destination.AccessControlTranslation != nil,
+		}
+	}
+
+	return result
+}
+
+// versioningEnabled reports whether versioning is enabled on bucketName
+func versioningEnabled(ctx context.Context, bucketName string) (bool, error) {
+	result, err := bucketClient(ctx, bucketName).GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.Status == types.BucketVersioningStatusEnabled, nil
+}
+
+func getBucketReplication(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetBucketReplication(r.Context(), &s3.GetBucketReplicationInput{
+		Bucket: aws.String(bucketName),
+	})
+
+	// Buckets without replication answer with an error instead of an empty configuration
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ReplicationConfigurationNotFoundError" {
+		json.NewEncoder(w).Encode(ReplicationConfiguration{Rules: []ReplicationRule{}})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_replication_failed", err)
+		return
+	}
+
+	configuration := ReplicationConfiguration{Rules: []ReplicationRule{}}
+	if result.ReplicationConfiguration != nil {
+		configuration.Role = aws.ToString(result.ReplicationConfiguration.Role)
+		for _, rule := range result.ReplicationConfiguration.Rules {
+			configuration.Rules = append(configuration.Rules, replicationRuleFromS3(rule))
+		}
+	}
+
+	json.NewEncoder(w).Encode(configuration)
+}
+
+func putBucketReplication(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data ReplicationConfiguration
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	// An empty configuration is rejected by PutBucketReplication, clearing means deleting
+	if len(data.Rules) == 0 {
+		deleteBucketReplication(w, r)
+		return
+	}
+
+	if apiErr := data.Validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	ctx := r.Context()
+
+	enabled, err := versioningEnabled(ctx, bucketName)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_versioning_failed", err)
+		return
+	}
+	if !enabled {
+		httpError(w, r, http.StatusConflict, "replication_versioning_required", bucketName)
+		return
+	}
+
+	// Destinations in other accounts cannot be inspected with our credentials,
+	// S3 reports their versioning state when the configuration is saved
+	for _, rule := range data.Rules {
+		destination := strings.TrimPrefix(rule.Destination.Bucket, bucketARNPrefix)
+		if enabled, err := versioningEnabled(ctx, destination); err == nil && !enabled {
+			httpError(w, r, http.StatusConflict, "replication_versioning_required", destination)
+			return
+		}
+	}
+
+	configuration := &types.ReplicationConfiguration{Role: aws.String(data.Role)}
+	for i := range data.Rules {
+		configuration.Rules = append(configuration.Rules, data.Rules[i].toS3())
+	}
+
+	_, err = bucketClient(ctx, bucketName).PutBucketReplication(ctx, &s3.PutBucketReplicationInput{
+		Bucket:                   aws.String(bucketName),
+		ReplicationConfiguration: configuration,
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_replication_failed", err)
+		return
+	}
+
+	audit.Record(r, "replication.put", bucketName, "", map[string]interface{}{
+		"role":  data.Role,
+		"rules": len(data.Rules),
+	})
+
+	json.NewEncoder(w).Encode(data)
+}
+
+func deleteBucketReplication(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	_, err := bucketClient(r.Context(), bucketName).DeleteBucketReplication(r.Context(), &s3.DeleteBucketReplicationInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "delete_replication_failed", err)
+		return
+	}
+
+	audit.Record(r, "replication.delete", bucketName, "", nil)
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// requestPayments remembers which buckets charge the requester, so reads do
+// not need an extra round trip each
+var requestPayments = newBucketFlagCache("request payment", func(ctx context.Context, bucketName string) (bool, error) {
+	result, err := bucketClient(ctx, bucketName).GetBucketRequestPayment(ctx, &s3.GetBucketRequestPaymentInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.Payer == types.PayerRequester, nil
+})
+
+// requestPayer is the RequestPayer value to send along with reads from bucketName
+func requestPayer(ctx context.Context, bucketName string) types.RequestPayer {
+	if requestPayments.lookup(ctx, bucketName) {
+		return types.RequestPayerRequester
+	}
+
+	return ""
+}
+
+func getBucketRequestPayment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetBucketRequestPayment(r.Context(), &s3.GetBucketRequestPaymentInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_request_payment_failed", err)
+		return
+	}
+
+	payer := result.Payer
+	if payer == "" {
+		payer = types.PayerBucketOwner
+	}
+	requestPayments.set(bucketName, payer == types.PayerRequester)
+
+	json.NewEncoder(w).Encode(map[string]string{"payer": string(payer)})
+}
+
+func putBucketRequestPayment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Payer string `json:"payer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if !isKnownValue(data.Payer, types.Payer("").Values()) {
+		httpError(w, r, http.StatusBadRequest, "payer_invalid", data.Payer)
+		return
+	}
+
+	_, err := bucketClient(r.Context(), bucketName).PutBucketRequestPayment(r.Context(), &s3.PutBucketRequestPaymentInput{
+		Bucket: aws.String(bucketName),
+		RequestPaymentConfiguration: &types.RequestPaymentConfiguration{
+			Payer: types.Payer(data.Payer),
+		},
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_request_payment_failed", err)
+		return
+	}
+
+	requestPayments.set(bucketName, data.Payer == string(types.PayerRequester))
+	audit.Record(r, "request-payment.put", bucketName, "", map[string]interface{}{"payer": data.Payer})
+
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+// Deliveries are stored in folders named after their creation time
+var inventoryDeliveryPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}-\d{2}Z/$`)
+
+// InventoryReport is an S3 Inventory configuration of a bucket
+type InventoryReport struct {
+	ID                 string   `json:"id"`
+	Enabled            bool     `json:"enabled"`
+	DestinationBucket  string   `json:"destinationBucket"`
+	DestinationPrefix  string   `json:"destinationPrefix,omitempty"`
+	DestinationAccount string   `json:"destinationAccount,omitempty"`
+	Format             string   `json:"format"`
+	Frequency          string   `json:"frequency"`
+	IncludedVersions   string   `json:"includedVersions"`
+	Prefix             string   `json:"prefix,omitempty"`
+	OptionalFields     []string `json:"optionalFields"`
+}
+
+func (i *InventoryReport) Validate() *apiError {
+	if i.DestinationBucket == "" {
+		return newAPIError("inventory_destination_required")
+	}
+	if !strings.HasPrefix(i.DestinationBucket, bucketARNPrefix) {
+		i.DestinationBucket = bucketARNPrefix + i.DestinationBucket
+	}
+
+	if i.Format == "" {
+		i.Format = string(types.InventoryFormatCsv)
+	}
+	if !isKnownValue(i.Format, types.InventoryFormat("").Values()) {
+		return newAPIError("inventory_format_invalid", i.Format)
+	}
+	if i.Frequency == "" {
+		i.Frequency = string(types.InventoryFrequencyDaily)
+	}
+	if !isKnownValue(i.Frequency, types.InventoryFrequency("").Values()) {
+		return newAPIError("inventory_frequency_invalid", i.Frequency)
+	}
+	if i.IncludedVersions == "" {
+		i.IncludedVersions = string(types.InventoryIncludedObjectVersionsCurrent)
+	}
+	if !isKnownValue(i.IncludedVersions, types.InventoryIncludedObjectVersions("").Values()) {
+		return newAPIError("inventory_versions_invalid", i.IncludedVersions)
+	}
+	for _, field := range i.OptionalFields {
+		if !isKnownValue(field, types.InventoryOptionalField("").Values()) {
+			return newAPIError("inventory_field_invalid", field)
+		}
+	}
+
+	return nil
+}
+
+func (i *InventoryReport) toS3() *types.InventoryConfiguration {
+	destination := &types.InventoryS3BucketDestination{
+		Bucket: aws.String(i.DestinationBucket),
+		Format: types.InventoryFormat(i.Format),
+	}
+	if i.DestinationPrefix != "" {
+		destination.Prefix = aws.String(i.DestinationPrefix)
+	}
+	if i.DestinationAccount != "" {
+		destination.AccountId = aws.String(i.DestinationAccount)
+	}
+
+	configuration := &types.InventoryConfiguration{
+		Id:                     aws.String(i.ID),
+		IsEnabled:              aws.Bool(i.Enabled),
+		Destination:            &types.InventoryDestination{S3BucketDestination: destination},
+		Schedule:               &types.InventorySchedule{Frequency: types.InventoryFrequency(i.Frequency)},
+		IncludedObjectVersions: types.InventoryIncludedObjectVersions(i.IncludedVersions),
+	}
+	if i.Prefix != "" {
+		configuration.Filter = &types.InventoryFilter{Prefix: aws.String(i.Prefix)}
+	}
+	for _, field := range i.OptionalFields {
+		configuration.OptionalFields = append(configuration.OptionalFields, types.InventoryOptionalField(field))
+	}
+
+	return configuration
+}
+
+func inventoryReportFromS3(configuration types.InventoryConfiguration) InventoryReport {
+	report := InventoryReport{
+		ID:               aws.ToString(configuration.Id),
+		Enabled:          aws.ToBool(configuration.IsEnabled),
+		IncludedVersions: string(configuration.IncludedObjectVersions),
+		OptionalFields:   []string{},
+	}
+	if destination := configuration.Destination; destination != nil && destination.S3BucketDestination != nil {
+		report.DestinationBucket = aws.ToString(destination.S3BucketDestination.Bucket)
+		report.DestinationPrefix = aws.ToString(destination.S3BucketDestination.Prefix)
+		report.DestinationAccount = aws.ToString(destination.S3BucketDestination.AccountId)
+		report.Format = string(destination.S3BucketDestination.Format)
+	}
+	if configuration.Schedule != nil {
+		report.Frequency = string(configuration.Schedule.Frequency)
+	}
+	if configuration.Filter != nil {
+		report.Prefix = aws.ToString(configuration.Filter.Prefix)
+	}
+	for _, field := range configuration.OptionalFields {
+		report.OptionalFields = append(report.OptionalFields, string(field))
+	}
+
+	return report
+}
+
+// InventoryManifest is the manifest.json S3 writes next to every delivery
+type InventoryManifest struct {
+	SourceBucket      string `json:"sourceBucket"`
+	DestinationBucket string `json:"destinationBucket"`
+	CreationTimestamp string `json:"creationTimestamp"`
+	FileFormat        string `json:"fileFormat"`
+	FileSchema        string `json:"fileSchema"`
+	Files             []struct {
+		Key  string `json:"key"`
+		Size int64  `json:"size"`
+	} `json:"files"`
+}
+
+type InventoryDelivery struct {
+	ManifestBucket string            `json:"manifestBucket"`
+	ManifestKey    string            `json:"manifestKey"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	Manifest       InventoryManifest `json:"manifest"`
+}
+
+func getInventoryReport(ctx context.Context, bucketName, id string) (InventoryReport, error) {
+	result, err := bucketClient(ctx, bucketName).GetBucketInventoryConfiguration(ctx, &s3.GetBucketInventoryConfigurationInput{
+		Bucket: aws.String(bucketName),
+		Id:     aws.String(id),
+	})
+	if err != nil {
+		return InventoryReport{}, err
+	}
+
+	return inventoryReportFromS3(*result.InventoryConfiguration), nil
+}
+
+// latestInventoryDelivery finds the newest delivered manifest, deliveries are
+// placed in <destination prefix>/<source bucket>/<configuration id>/<time>/
+func latestInventoryDelivery(ctx context.Context, bucketName string, report InventoryReport) (*InventoryDelivery, error) {
+	destinationBucket := strings.TrimPrefix(report.DestinationBucket, bucketARNPrefix)
+	base := bucketName + "/" + report.ID + "/"
+	if report.DestinationPrefix != "" {
+		base = strings.TrimSuffix(report.DestinationPrefix, "/") + "/" + base
+	}
+
+	client := bucketClient(ctx, destinationBucket)
+	latest := ""
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(destinationBucket),
+		Prefix:    aws.String(base),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.CommonPrefixes {
+			folder := strings.TrimPrefix(aws.ToString(p.Prefix), base)
+			// The time format sorts like the times themselves
+			if inventoryDeliveryPattern.MatchString(folder) && folder > latest {
+				latest = folder
+			}
+		}
+	}
+	if latest == "" {
+		return nil, nil
+	}
+
+	delivery := &InventoryDelivery{ManifestBucket: destinationBucket, ManifestKey: base + latest + "manifest.json"}
+	result, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(delivery.ManifestBucket),
+		Key:    aws.String(delivery.ManifestKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	if err := json.NewDecoder(result.Body).Decode(&delivery.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", delivery.ManifestKey, err)
+	}
+	if millis, err := strconv.ParseInt(delivery.Manifest.CreationTimestamp, 10, 64); err == nil {
+		delivery.CreatedAt = time.UnixMilli(millis).UTC()
+	}
+
+	return delivery, nil
+}
+
+// walkInventory calls fn for every current object below prefix listed in a
+// delivery. Only CSV deliveries can be read, ORC and Parquet need decoders
+// this module does not have.
+func walkInventory(ctx context.Context, delivery *InventoryDelivery, prefix string, fn func(obj types.Object) error) error {
+	if delivery.Manifest.FileFormat != string(types.InventoryFormatCsv) {
+		return fmt.Errorf("inventory format %s cannot be read, only CSV", delivery.Manifest.FileFormat)
+	}
+
+	columns := map[string]int{}
+	for i, name := range strings.Split(delivery.Manifest.FileSchema, ",") {
+		columns[strings.TrimSpace(name)] = i
+	}
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	if _, ok := columns["Key"]; !ok {
+		return fmt.Errorf("inventory schema %q has no Key column", delivery.Manifest.FileSchema)
+	}
+
+	client := bucketClient(ctx, delivery.ManifestBucket)
+	for _, file := range delivery.Manifest.Files {
+		err := func() error {
+			result, err := client.GetObject(ctx, &s3.GetObjectInput{
+				Bucket: aws.String(delivery.ManifestBucket),
+				Key:    aws.String(file.Key),
+			})
+			if err != nil {
+				return err
+			}
+			defer result.Body.Close()
+
+			body, err := gzip.NewReader(result.Body)
+			if err != nil {
+				return err
+			}
+			reader := csv.NewReader(body)
+			reader.FieldsPerRecord = -1
+
+			for {
+				record, err := reader.Read()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				// Older versions and delete markers are only listed with all versions
+				if column(record, "IsLatest") == "false" || column(record, "IsDeleteMarker") == "true" {
+					continue
+				}
+				// Keys are URL encoded
+				key, err := url.QueryUnescape(column(record, "Key"))
+				if err != nil || !strings.HasPrefix(key, prefix) {
+					continue
+				}
+
+				obj := types.Object{
+					Key:          aws.String(key),
+					ETag:         aws.String(column(record, "ETag")),
+					StorageClass: types.ObjectStorageClass(column(record, "StorageClass")),
+				}
+				if size, err := strconv.ParseInt(column(record, "Size"), 10, 64); err == nil {
+					obj.Size = aws.Int64(size)
+				}
+				if modified, err := time.Parse(time.RFC3339, column(record, "LastModifiedDate")); err == nil {
+					obj.LastModified = aws.Time(modified)
+				}
+				if err := fn(obj); err != nil {
+					return err
+				}
+			}
+		}()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Key, err)
+		}
+	}
+
+	return nil
+}
+
+func listInventoryReports(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	reports := []InventoryReport{}
+	input := &s3.ListBucketInventoryConfigurationsInput{Bucket: aws.String(bucketName)}
+	for {
+		result, err := bucketClient(r.Context(), bucketName).ListBucketInventoryConfigurations(r.Context(), input)
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "get_inventory_failed", err)
+			return
+		}
+		for _, configuration := range result.InventoryConfigurationList {
+			reports = append(reports, inventoryReportFromS3(configuration))
+		}
+		if !aws.ToBool(result.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = result.NextContinuationToken
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].ID < reports[j].ID
+	})
+
+	json.NewEncoder(w).Encode(reports)
+}
+
+func putInventoryReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var report InventoryReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+	report.ID = vars["inventoryId"]
+	if apiErr := report.Validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	_, err := bucketClient(r.Context(), bucketName).PutBucketInventoryConfiguration(r.Context(), &s3.PutBucketInventoryConfigurationInput{
+		Bucket:                 aws.String(bucketName),
+		Id:                     aws.String(report.ID),
+		InventoryConfiguration: report.toS3(),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_inventory_failed", err)
+		return
+	}
+
+	audit.Record(r, "inventory.put", bucketName, "", map[string]interface{}{
+		"id":          report.ID,
+		"destination": report.DestinationBucket,
+		"enabled":     report.Enabled,
+	})
+
+	json.NewEncoder(w).Encode(report)
+}
+
+func deleteInventoryReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	_, err := bucketClient(r.Context(), bucketName).DeleteBucketInventoryConfiguration(r.Context(), &s3.DeleteBucketInventoryConfigurationInput{
+		Bucket: aws.String(bucketName),
+		Id:     aws.String(vars["inventoryId"]),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "delete_inventory_failed", err)
+		return
+	}
+
+	audit.Record(r, "inventory.delete", bucketName, "", map[string]interface{}{"id": vars["inventoryId"]})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getLatestInventoryDelivery shows what the newest delivery of a report
+// contains, before it is used for analytics
+func getLatestInventoryDelivery(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	report, err := getInventoryReport(r.Context(), bucketName, vars["inventoryId"])
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchConfiguration" {
+		httpError(w, r, http.StatusNotFound, "inventory_not_found", vars["inventoryId"])
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_inventory_failed", err)
+		return
+	}
+
+	delivery, err := latestInventoryDelivery(r.Context(), bucketName, report)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "inventory_read_failed", err)
+		return
+	}
+	if delivery == nil {
+		httpError(w, r, http.StatusNotFound, "inventory_not_delivered", report.ID)
+		return
+	}
+
+	json.NewEncoder(w).Encode(delivery)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var savedSearches *recordStore[SavedSearch]
+
+// SearchFilters are the filters of the object search as they are stored.
+// Relative times like 90d stay relative, so a saved search keeps finding
+// what became old since it was saved.
+type SearchFilters struct {
+	MinSize        string   `json:"minSize,omitempty"`
+	MaxSize        string   `json:"maxSize,omitempty"`
+	ModifiedAfter  string   `json:"modifiedAfter,omitempty"`
+	ModifiedBefore string   `json:"modifiedBefore,omitempty"`
+	Extensions     []string `json:"ext,omitempty"`
+	Patterns       []string `json:"pattern,omitempty"`
+}
+
+func (f SearchFilters) values() url.Values {
+	values := url.Values{}
+	for name, value := range map[string]string{
+		"minSize":        f.MinSize,
+		"maxSize":        f.MaxSize,
+		"modifiedAfter":  f.ModifiedAfter,
+		"modifiedBefore": f.ModifiedBefore,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	values["ext"] = f.Extensions
+	values["pattern"] = f.Patterns
+
+	return values
+}
+
+func (f SearchFilters) isEmpty() bool {
+	return f.MinSize == "" && f.MaxSize == "" && f.ModifiedAfter == "" && f.ModifiedBefore == "" && len(f.Extensions) == 0 && len(f.Patterns) == 0
+}
+
+type SavedSearch struct {
+	ID         string        `json:"id"`
+	Name       string        `json:"name"`
+	BucketName string        `json:"bucketName"`
+	Prefix     string        `json:"prefix,omitempty"`
+	Filters    SearchFilters `json:"filters"`
+	CreatedBy  string        `json:"createdBy,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+}
+
+func newSavedSearchStore(dataDir string) (*recordStore[SavedSearch], error) {
+	return newRecordStore(dataDir, "searches.json", func(search *SavedSearch) string {
+		return search.ID
+	})
+}
+
+type savedSearchInput struct {
+	Name       string        `json:"name"`
+	BucketName string        `json:"bucketName"`
+	Prefix     string        `json:"prefix"`
+	Filters    SearchFilters `json:"filters"`
+	CreatedBy  string        `json:"createdBy"`
+}
+
+func (in savedSearchInput) validate() *apiError {
+	if strings.TrimSpace(in.Name) == "" || in.BucketName == "" {
+		return newAPIError("saved_search_fields_required")
+	}
+
+	_, apiErr := parseObjectFilter(in.Filters.values())
+	return apiErr
+}
+
+func decodeSavedSearch(w http.ResponseWriter, r *http.Request) (savedSearchInput, bool) {
+	var data savedSearchInput
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return savedSearchInput{}, false
+	}
+	if apiErr := data.validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return savedSearchInput{}, false
+	}
+
+	return data, true
+}
+
+func listSavedSearches(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(savedSearches.List(func(a, b *SavedSearch) bool {
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	}))
+}
+
+func createSavedSearch(w http.ResponseWriter, r *http.Request) {
+	data, ok := decodeSavedSearch(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := randomToken(8)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "saved_search_save_failed", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	search := SavedSearch{
+		ID:         id,
+		Name:       strings.TrimSpace(data.Name),
+		BucketName: data.BucketName,
+		Prefix:     data.Prefix,
+		Filters:    data.Filters,
+		CreatedBy:  data.CreatedBy,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := savedSearches.Put(search); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "saved_search_save_failed", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(search)
+}
+
+func getSavedSearch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	search, ok := savedSearches.Get(vars["searchId"])
+	if !ok {
+		httpError(w, r, http.StatusNotFound, "saved_search_not_found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(search)
+}
+
+func updateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	data, ok := decodeSavedSearch(w, r)
+	if !ok {
+		return
+	}
+
+	search, found, err := savedSearches.Update(vars["searchId"], func(search *SavedSearch) error {
+		search.Name = strings.TrimSpace(data.Name)
+		search.BucketName = data.BucketName
+		search.Prefix = data.Prefix
+		search.Filters = data.Filters
+		search.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	if !found {
+		httpError(w, r, http.StatusNotFound, "saved_search_not_found")
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "saved_search_save_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(search)
+}
+
+func deleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	found, err := savedSearches.Delete(vars["searchId"])
+	if !found {
+		httpError(w, r, http.StatusNotFound, "saved_search_not_found")
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "saved_search_save_failed", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runSavedSearch answers like the object search of the saved bucket
+func runSavedSearch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	search, ok := savedSearches.Get(vars["searchId"])
+	if !ok {
+		httpError(w, r, http.StatusNotFound, "saved_search_not_found")
+		return
+	}
+
+	filter, apiErr := parseObjectFilter(search.Filters.values())
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+	limit, apiErr := parseSearchLimit(r.URL.Query().Get("limit"))
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	objects, truncated, err := findObjects(r.Context(), search.BucketName, search.Prefix, filter, limit)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "list_objects_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"search":    search,
+		"objects":   objects,
+		"truncated": truncated,
+	})
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ScheduleSync  = "sync"
+	SchedulePurge = "purge"
+	ScheduleStats = "stats"
+)
+
+var scheduleTasks = []string{ScheduleSync, SchedulePurge, ScheduleStats}
+
+var schedules *recordStore[Schedule]
+
+// scheduleRuns holds the last run of every schedule, including the ones
+// from the config
+var scheduleRuns *documentStore[map[string]ScheduleRun]
+
+// PurgeTask deletes the objects below a prefix, or only those older than
+// OlderThanDays
+type PurgeTask struct {
+	BucketName    string `json:"bucketName" yaml:"bucket"`
+	Prefix        string `json:"prefix" yaml:"prefix"`
+	OlderThanDays int    `json:"olderThanDays,omitempty" yaml:"older_than_days"`
+}
+
+type StatsTask struct {
+	BucketName string `json:"bucketName" yaml:"bucket"`
+}
+
+// ScheduleDefinition is what runs when. Cron expressions have five fields
+// and are evaluated in UTC.
+type ScheduleDefinition struct {
+	Name     string       `json:"name" yaml:"name"`
+	Cron     string       `json:"cron" yaml:"cron"`
+	Task     string       `json:"task" yaml:"task"`
+	Disabled bool         `json:"disabled" yaml:"disabled"`
+	Sync     *SyncRequest `json:"sync,omitempty" yaml:"sync"`
+	Purge    *PurgeTask   `json:"purge,omitempty" yaml:"purge"`
+	Stats    *StatsTask   `json:"stats,omitempty" yaml:"stats"`
+}
+
+// ConfiguredSchedule is a schedule from the config, it cannot be changed
+// through the API
+type ConfiguredSchedule struct {
+	ID                 string `yaml:"id"`
+	ScheduleDefinition `yaml:",inline"`
+}
+
+type Schedule struct {
+	ID string `json:"id"`
+	ScheduleDefinition
+	// Source is config or api
+	Source    string    `json:"source"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ScheduleRun struct {
+	StartedAt time.Time `json:"startedAt"`
+	// JobID is the job, or for stats tasks the stats scan, that was started
+	JobID string `json:"jobId,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Skipped is set when the previous run was still going
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+type ScheduleStatus struct {
+	Schedule
+	LastRun       *ScheduleRun `json:"lastRun,omitempty"`
+	LastJobStatus JobStatus    `json:"lastJobStatus,omitempty"`
+	NextRunAt     *time.Time   `json:"nextRunAt,omitempty"`
+}
+
+func newScheduleStore(dataDir string) (*recordStore[Schedule], error) {
+	return newRecordStore(dataDir, "schedules.json", func(schedule *Schedule) string {
+		return schedule.ID
+	})
+}
+
+func newScheduleRunStore(dataDir string) (*documentStore[map[string]ScheduleRun], error) {
+	return newDocumentStore(dataDir, "schedule-runs.json", map[string]ScheduleRun{})
+}
+
+func (d *ScheduleDefinition) validate() *apiError {
+	if strings.TrimSpace(d.Name) == "" {
+		return newAPIError("schedule_name_required")
+	}
+	if _, err := parseCron(d.Cron); err != nil {
+		return newAPIError("schedule_cron_invalid", d.Cron, err)
+	}
+
+	switch d.Task {
+	case ScheduleSync:
+		if d.Sync == nil {
+			return newAPIError("schedule_task_incomplete", d.Task)
+		}
+		return d.Sync.validate()
+	case SchedulePurge:
+		// Purging a whole bucket on a schedule is too easy to get wrong
+		if d.Purge == nil || d.Purge.BucketName == "" || d.Purge.Prefix == "" {
+			return newAPIError("schedule_task_incomplete", d.Task)
+		}
+		if d.Purge.OlderThanDays < 0 {
+			return newAPIError("schedule_purge_age_invalid", d.Purge.OlderThanDays)
+		}
+	case ScheduleStats:
+		if d.Stats == nil || d.Stats.BucketName == "" {
+			return newAPIError("schedule_task_incomplete", d.Task)
+		}
+	default:
+		return newAPIError("schedule_task_invalid", d.Task, strings.Join(scheduleTasks, ", "))
+	}
+
+	return nil
+}
+
+// configuredSchedules validates the schedules of the config at startup
+func configuredSchedules() ([]Schedule, error) {
+	seen := map[string]bool{}
+	list := []Schedule{}
+	for _, configured := range appConfig.Schedules {
+		if configured.ID == "" || seen[configured.ID] {
+			return nil, newAPIError("schedule_id_invalid", configured.ID)
+		}
+		seen[configured.ID] = true
+
+		if apiErr := configured.validate(); apiErr != nil {
+			return nil, apiErr
+		}
+		list = append(list, Schedule{ID: configured.ID, ScheduleDefinition: configured.ScheduleDefinition, Source: "config"})
+	}
+
+	return list, nil
+}
+
+var configSchedules []Schedule
+
+func allSchedules() []Schedule {
+	stored := schedules.List(func(a, b *Schedule) bool {
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+
+	return append(append([]Schedule{}, configSchedules...), stored...)
+}
+
+func findSchedule(id string) (Schedule, bool) {
+	for _, schedule := range configSchedules {
+		if schedule.ID == id {
+			return schedule, true
+		}
+	}
+
+	return schedules.Get(id)
+}
+
+// purgePrefix deletes what a purge task selects, a listing page at a time
+func purgePrefix(ctx context.Context, job *jobHandle, task PurgeTask) error {
+	var before time.Time
+	if task.OlderThanDays > 0 {
+		before = time.Now().AddDate(0, 0, -task.OlderThanDays)
+	}
+
+	var batch []string
+	flush := func() error {
+		job.AddTotal(len(batch))
+		deleted, failed, err := deleteKeys(ctx, task.BucketName, batch)
+		job.Advance(len(deleted))
+		for _, e := range failed {
+			job.Report(e.Key, newAPIError("sync_delete_failed", e.Code, e.Message))
+		}
+		batch = batch[:0]
+		return err
+	}
+
+	err := walkObjects(ctx, task.BucketName, task.Prefix, func(obj types.Object) error {
+		if !before.IsZero() && !aws.ToTime(obj.LastModified).Before(before) {
+			return nil
+		}
+		batch = append(batch, aws.ToString(obj.Key))
+		if len(batch) == deleteBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	publishObjectEvent(ObjectDeleted, task.BucketName, task.Prefix)
+
+	return err
+}
+
+// startScheduledTask starts the task of a schedule and returns the job or
+// stats scan it runs as
+func startScheduledTask(schedule Schedule) (string, error) {
+	switch schedule.Task {
+	case ScheduleSync:
+		if freeze, frozen := freezes.Get(schedule.Sync.DestinationBucket); frozen {
+			return "", newAPIError("bucket_frozen", freeze.BucketName, freeze.FrozenBy, freeze.Reason)
+		}
+		job, err := startSync(*schedule.Sync)
+		return job.ID, err
+	case SchedulePurge:
+		task := *schedule.Purge
+		if freeze, frozen := freezes.Get(task.BucketName); frozen {
+			return "", newAPIError("bucket_frozen", freeze.BucketName, freeze.FrozenBy, freeze.Reason)
+		}
+		job, err := startBucketJob(task.BucketName, "purge", task.Prefix, func(ctx context.Context, job *jobHandle) error {
+			return purgePrefix(ctx, job, task)
+		})
+		return job.ID, err
+	case ScheduleStats:
+		scan, err := statsScans.Enqueue(schedule.Stats.BucketName, statsPriorityBackground, "schedule")
+		return scan.ID, err
+	}
+
+	return "", newAPIError("schedule_task_invalid", schedule.Task, strings.Join(scheduleTasks, ", "))
+}
+
+// stillRunning reports whether the job or stats scan of the last run has
+// not finished yet
+func stillRunning(run ScheduleRun) bool {
+	if run.JobID == "" {
+		return false
+	}
+	if job, ok := jobs.Get(run.JobID); ok {
+		return !job.Finished()
+	}
+	for _, scan := range statsScans.List() {
+		if scan.ID == run.JobID {
+			return true
+		}
+	}
+
+	return false
+}
+
+var scheduleRunMu sync.Mutex
+
+// runSchedule starts a schedule unless its previous run is still going,
+// and records the outcome as its last run
+func runSchedule(schedule Schedule) ScheduleRun {
+	scheduleRunMu.Lock()
+	defer scheduleRunMu.Unlock()
+
+	runs := scheduleRuns.Get()
+	run := ScheduleRun{StartedAt: time.Now().UTC()}
+	if previous, ok := runs[schedule.ID]; ok && stillRunning(previous) {
+		run.JobID = previous.JobID
+		run.Skipped = true
+	} else {
+		jobID, err := startScheduledTask(schedule)
+		run.JobID = jobID
+		if err != nil {
+			run.Error = err.Error()
+			log.Printf("schedule %s failed to start: %v", schedule.ID, err)
+		}
+	}
+
+	updated := make(map[string]ScheduleRun, len(runs)+1)
+	for id, previous := range runs {
+		updated[id] = previous
+	}
+	updated[schedule.ID] = run
+	if err := scheduleRuns.Set(updated); err != nil {
+		log.Printf("failed to save run of schedule %s: %v", schedule.ID, err)
+	}
+
+	audit.Record(nil, "schedule.run", "", "", map[string]interface{}{
+		"scheduleId": schedule.ID,
+		"task":       schedule.Task,
+		"jobId":      run.JobID,
+		"skipped":    run.Skipped,
+		"error":      run.Error,
+	})
+
+	return run
+}
+
+// runSchedules checks all enabled schedules at the start of every minute
+func runSchedules() {
+	for {
+		now := time.Now().UTC()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(next.Sub(now))
+
+		for _, schedule := range allSchedules() {
+			if schedule.Disabled {
+				continue
+			}
+			cron, err := parseCron(schedule.Cron)
+			if err != nil || !cron.Matches(next) {
+				continue
+			}
+			go runSchedule(schedule)
+		}
+	}
+}
+
+func scheduleStatus(schedule Schedule, runs map[string]ScheduleRun) ScheduleStatus {
+	status := ScheduleStatus{Schedule: schedule}
+	if run, ok := runs[schedule.ID]; ok {
+		status.LastRun = &run
+		if job, ok := jobs.Get(run.JobID); ok {
+			status.LastJobStatus = job.Status
+		}
+	}
+	if cron, err := parseCron(schedule.Cron); err == nil && !schedule.Disabled {
+		if next := cron.Next(time.Now().UTC()); !next.IsZero() {
+			status.NextRunAt = &next
+		}
+	}
+
+	return status
+}
+
+func listSchedules(w http.ResponseWriter, r *http.Request) {
+	runs := scheduleRuns.Get()
+
+	list := []ScheduleStatus{}
+	for _, schedule := range allSchedules() {
+		list = append(list, scheduleStatus(schedule, runs))
+	}
+
+	json.NewEncoder(w).Encode(list)
+}
+
+func getSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	schedule, ok := findSchedule(vars["scheduleId"])
+	if !ok {
+		httpError(w, r, http.StatusNotFound, "schedule_not_found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(scheduleStatus(schedule, scheduleRuns.Get()))
+}
+
+func decodeSchedule(w http.ResponseWriter, r *http.Request) (ScheduleDefinition, string, bool) {
+	var data struct {
+		ScheduleDefinition
+		CreatedBy string `json:"createdBy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return ScheduleDefinition{}, "", false
+	}
+	data.Name = strings.TrimSpace(data.Name)
+	if apiErr := data.validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return ScheduleDefinition{}, "", false
+	}
+
+	return data.ScheduleDefinition, data.CreatedBy, true
+}
+
+func createSchedule(w http.ResponseWriter, r *http.Request) {
+	definition, createdBy, ok := decodeSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := randomToken(8)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "schedule_save_failed", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	schedule := Schedule{
+		ID:                 id,
+		ScheduleDefinition: definition,
+		Source:             "api",
+		CreatedBy:          createdBy,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := schedules.Put(schedule); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "schedule_save_failed", err)
+		return
+	}
+
+	audit.Record(r, "schedule.create", "", "", map[string]interface{}{"scheduleId": id, "task": schedule.Task, "cron": schedule.Cron})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scheduleStatus(schedule, scheduleRuns.Get()))
+}
+
+// checkScheduleEditable rejects changes to schedules from the config
+func checkScheduleEditable(w http.ResponseWriter, r *http.Request, id string) bool {
+	if schedule, ok := findSchedule(id); ok && schedule.Source == "config" {
+		httpError(w, r, http.StatusConflict, "schedule_from_config", id)
+		return false
+	}
+
+	return true
+}
+
+func updateSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !checkScheduleEditable(w, r, vars["scheduleId"]) {
+		return
+	}
+
+	definition, _, ok := decodeSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, found, err := schedules.Update(vars["scheduleId"], func(schedule *Schedule) error {
+		schedule.ScheduleDefinition = definition
+		schedule.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	if !found {
+		httpError(w, r, http.StatusNotFound, "schedule_not_found")
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "schedule_save_failed", err)
+		return
+	}
+
+	audit.Record(r, "schedule.update", "", "", map[string]interface{}{"scheduleId": schedule.ID, "task": schedule.Task, "cron": schedule.Cron})
+
+	json.NewEncoder(w).Encode(scheduleStatus(schedule, scheduleRuns.Get()))
+}
+
+func deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !checkScheduleEditable(w, r, vars["scheduleId"]) {
+		return
+	}
+
+	found, err := schedules.Delete(vars["scheduleId"])
+	if !found {
+		httpError(w, r, http.StatusNotFound, "schedule_not_found")
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "schedule_save_failed", err)
+		return
+	}
+
+	audit.Record(r, "schedule.delete", "", "", map[string]interface{}{"scheduleId": vars["scheduleId"]})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// triggerSchedule runs a schedule right away, e.g. to try it out
+func triggerSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	schedule, ok := findSchedule(vars["scheduleId"])
+	if !ok {
+		httpError(w, r, http.StatusNotFound, "schedule_not_found")
+		return
+	}
+
+	run := runSchedule(schedule)
+	if run.Error != "" {
+		httpError(w, r, http.StatusConflict, "schedule_run_failed", run.Error)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+var shares *recordStore[Share]
+
+type Share struct {
+	ID            string    `json:"id"`
+	Token         string    `json:"token"`
+	BucketName    string    `json:"bucketName"`
+	ObjectKey     string    `json:"objectKey"`
+	CreatedBy     string    `json:"createdBy"`
+	CreatedAt     time.Time `json:"createdAt"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+	DownloadCount int       `json:"downloadCount"`
+
+	// Restrictions presigned URLs cannot express, enforced by downloadShare
+	AllowedCIDRs    []string `json:"allowedCidrs,omitempty"`
+	AllowedReferers []string `json:"allowedReferers,omitempty"`
+}
+
+func (s *Share) Expired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// Permits checks the network and referrer restrictions of the share. Requests
+// without a Referer header are rejected once referrers are restricted.
+func (s *Share) Permits(r *http.Request) bool {
+	if len(s.AllowedCIDRs) > 0 {
+		networks, apiErr := parseCIDRs(s.AllowedCIDRs)
+		ip := clientIP(r)
+		if apiErr != nil || ip == nil || !containsIP(networks, ip) {
+			return false
+		}
+	}
+
+	if len(s.AllowedReferers) > 0 && !matchesAny(s.AllowedReferers, r.Referer(), false) {
+		return false
+	}
+
+	return true
+}
+
+func validateReferers(patterns []string) *apiError {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return newAPIError("referer_pattern_invalid", pattern)
+		}
+	}
+
+	return nil
+}
+
+func newShareStore(dataDir string) (*recordStore[Share], error) {
+	return newRecordStore(dataDir, "shares.json", func(share *Share) string {
+		return share.ID
+	})
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+func listShares(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(shares.List(func(a, b *Share) bool {
+		return a.CreatedAt.After(b.CreatedAt)
+	}))
+}
+
+func createShare(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		BucketName      string   `json:"bucketName"`
+		ObjectKey       string   `json:"objectKey"`
+		CreatedBy       string   `json:"createdBy"`
+		Expires         int      `json:"expires"`
+		AllowedCIDRs    []string `json:"allowedCidrs"`
+		AllowedReferers []string `json:"allowedReferers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.BucketName == "" || data.ObjectKey == "" {
+		httpError(w, r, http.StatusBadRequest, "bucket_and_key_required")
+		return
+	}
+
+	expiry := defaultPresignExpiry
+	if data.Expires != 0 {
+		if data.Expires < 0 {
+			httpError(w, r, http.StatusBadRequest, "expires_invalid")
+			return
+		}
+		expiry = time.Duration(data.Expires) * time.Second
+	}
+
+	if _, apiErr := parseCIDRs(data.AllowedCIDRs); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+	if apiErr := validateReferers(data.AllowedReferers); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	// Make sure we do not hand out links to objects which do not exist
+	_, err := bucketClient(r.Context(), data.BucketName).HeadObject(r.Context(), &s3.HeadObjectInput{
+		Bucket: aws.String(data.BucketName),
+		Key:    aws.String(data.ObjectKey),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusNotFound, "object_not_found", err)
+		return
+	}
+
+	id, err := randomToken(8)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "share_create_failed", err)
+		return
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "share_create_failed", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	share := Share{
+		ID:              id,
+		Token:           token,
+		BucketName:      data.BucketName,
+		ObjectKey:       data.ObjectKey,
+		CreatedBy:       data.CreatedBy,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(expiry),
+		AllowedCIDRs:    data.AllowedCIDRs,
+		AllowedReferers: data.AllowedReferers,
+	}
+
+	if err := shares.Put(share); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "share_create_failed", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(share)
+}
+
+func rotateShare(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	share, found, err := shares.Update(vars["shareId"], func(share *Share) error {
+		token, err := randomToken(32)
+		if err != nil {
+			return err
+		}
+		share.Token = token
+		return nil
+	})
+	if !found {
+		httpError(w, r, http.StatusNotFound, "share_not_found")
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "share_rotate_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(share)
+}
+
+func deleteShare(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	found, err := shares.Delete(vars["shareId"])
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "share_delete_failed", err)
+		return
+	}
+	if !found {
+		httpError(w, r, http.StatusNotFound, "share_not_found")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func downloadShare(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Compared in constant time so the token cannot be guessed from response times
+	share, found := shares.Find(func(share *Share) bool {
+		return subtle.ConstantTimeCompare([]byte(share.Token), []byte(vars["token"])) == 1
+	})
+	if !found {
+		httpError(w, r, http.StatusNotFound, "share_not_found")
+		return
+	}
+	if share.Expired() {
+		httpError(w, r, http.StatusGone, "share_expired")
+		return
+	}
+	if !share.Permits(r) {
+		audit.Record(r, "share.denied", share.BucketName, share.ObjectKey, map[string]interface{}{
+			"shareId": share.ID,
+			"referer": r.Referer(),
+		})
+		httpError(w, r, http.StatusForbidden, "share_access_denied")
+		return
+	}
+
+	result, err := transferClient(r.Context(), share.BucketName).GetObject(r.Context(), &s3.GetObjectInput{
+		Bucket:       aws.String(share.BucketName),
+		Key:          aws.String(share.ObjectKey),
+		RequestPayer: requestPayer(r.Context(), share.BucketName),
+	})
+	if err != nil {
+		log.Printf("failed to download share %s: %v", share.ID, err)
+		httpError(w, r, http.StatusInternalServerError, "share_download_failed")
+		return
+	}
+	defer result.Body.Close()
+
+	_, _, err = shares.Update(share.ID, func(share *Share) error {
+		share.DownloadCount++
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to record download of share %s: %v", share.ID, err)
+	}
+
+	w.Header().Set("Content-Disposition", attachmentDisposition(path.Base(share.ObjectKey)))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	io.Copy(w, result.Body)
+}
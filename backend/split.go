@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+const maxSplitChunks = 10000
+
+func splitChunkKey(sourceKey, destinationPrefix string, index int) string {
+	name := fmt.Sprintf("%s.part%05d", path.Base(sourceKey), index)
+	if destinationPrefix == "" {
+		destinationPrefix = path.Dir(sourceKey)
+		if destinationPrefix == "." {
+			return name
+		}
+	}
+
+	return strings.TrimSuffix(destinationPrefix, "/") + "/" + name
+}
+
+func splitObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		SourceKey         string `json:"sourceKey"`
+		ChunkSize         int64  `json:"chunkSize"`
+		DestinationPrefix string `json:"destinationPrefix"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.SourceKey == "" {
+		httpError(w, r, http.StatusBadRequest, "split_source_required")
+		return
+	}
+	if data.ChunkSize <= 0 {
+		httpError(w, r, http.StatusBadRequest, "split_chunk_size_invalid")
+		return
+	}
+
+	head, err := bucketClient(r.Context(), bucketName).HeadObject(r.Context(), &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(data.SourceKey),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusNotFound, "object_not_found", err)
+		return
+	}
+
+	size := aws.ToInt64(head.ContentLength)
+	chunks := int((size + data.ChunkSize - 1) / data.ChunkSize)
+	if chunks > maxSplitChunks {
+		httpError(w, r, http.StatusBadRequest, "split_too_many_chunks", chunks, maxSplitChunks)
+		return
+	}
+
+	job, err := startBucketJob(bucketName, "split", data.SourceKey, func(ctx context.Context, job *jobHandle) error {
+		job.SetTotal(chunks)
+
+		for i := 0; i < chunks; i++ {
+			start := int64(i) * data.ChunkSize
+			end := min(start+data.ChunkSize, size)
+			chunkKey := splitChunkKey(data.SourceKey, data.DestinationPrefix, i)
+
+			writer, err := newMultipartWriter(ctx, bucketName, chunkKey, &s3.CreateMultipartUploadInput{
+				ContentType: head.ContentType,
+			})
+			if err != nil {
+				job.Report(chunkKey, err)
+				continue
+			}
+
+			err = writer.AddRange(bucketName, data.SourceKey, start, end, true)
+			if err == nil {
+				err = writer.Complete()
+			}
+			if err != nil {
+				writer.Abort()
+			}
+			job.Report(chunkKey, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	writeJobAccepted(w, job)
+}
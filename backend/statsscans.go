@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Scans asked for by someone looking at the UI go before periodic ones
+const (
+	statsPriorityBackground  = 0
+	statsPriorityInteractive = 10
+)
+
+const (
+	StatsScanQueued  = "queued"
+	StatsScanRunning = "running"
+)
+
+// StatsScan is a queued or running count of a bucket. A bucket is never
+// counted twice at the same time, asking again joins the existing scan.
+type StatsScan struct {
+	ID         string     `json:"id"`
+	BucketName string     `json:"bucketName"`
+	Priority   int        `json:"priority"`
+	Source     string     `json:"source"`
+	Status     string     `json:"status"`
+	QueuedAt   time.Time  `json:"queuedAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+}
+
+type statsScan struct {
+	StatsScan
+	cancel  context.CancelFunc
+	requeue bool
+	done    chan struct{}
+	stats   BucketStats
+	err     error
+}
+
+// statsScheduler runs bucket counts with bucket_stats.max_concurrent_scans
+// workers, highest priority first and in order of arrival otherwise
+type statsScheduler struct {
+	mu    sync.Mutex
+	ready *sync.Cond
+	scans map[string]*statsScan
+}
+
+var statsScans = newStatsScheduler()
+
+func newStatsScheduler() *statsScheduler {
+	s := &statsScheduler{scans: make(map[string]*statsScan)}
+	s.ready = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *statsScheduler) Start(workers int) {
+	for i := 0; i < max(workers, 1); i++ {
+		go s.work()
+	}
+}
+
+// next blocks until a queued scan is available and marks it as running. The
+// scan is registered as operation, so it can be cancelled from there as well.
+func (s *statsScheduler) next() (*statsScan, context.Context, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		var next *statsScan
+		for _, scan := range s.scans {
+			if scan.Status != StatsScanQueued {
+				continue
+			}
+			if next == nil || scan.Priority > next.Priority || (scan.Priority == next.Priority && scan.QueuedAt.Before(next.QueuedAt)) {
+				next = scan
+			}
+		}
+		if next == nil {
+			s.ready.Wait()
+			continue
+		}
+
+		ctx, done := operations.Begin(context.Background(), next.BucketName, "stats-scan", "", "")
+		if timeout := jobTimeout("stats-scan"); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			end := done
+			done = func() {
+				cancel()
+				end()
+			}
+		}
+		now := time.Now().UTC()
+		next.Status = StatsScanRunning
+		next.StartedAt = &now
+		next.cancel = done
+		return next, ctx, done
+	}
+}
+
+func (s *statsScheduler) work() {
+	for {
+		scan, ctx, done := s.next()
+		stats, err := scanBucketStats(ctx, scan.BucketName)
+		done()
+
+		s.mu.Lock()
+		if scan.requeue {
+			scan.requeue = false
+			scan.Status = StatsScanQueued
+			scan.StartedAt = nil
+			scan.QueuedAt = time.Now().UTC()
+			s.ready.Signal()
+			s.mu.Unlock()
+			continue
+		}
+
+		delete(s.scans, scan.ID)
+		scan.stats, scan.err = stats, err
+		if err == nil {
+			bucketStats.set(scan.BucketName, stats)
+		} else {
+			log.Printf("failed to count bucket %s: %v", scan.BucketName, err)
+		}
+		close(scan.done)
+		s.mu.Unlock()
+	}
+}
+
+// Enqueue queues a count of the bucket, or raises the priority of the one
+// already queued
+func (s *statsScheduler) Enqueue(bucketName string, priority int, source string) (StatsScan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan, err := s.enqueue(bucketName, priority, source)
+	if err != nil {
+		return StatsScan{}, err
+	}
+
+	return scan.StatsScan, nil
+}
+
+func (s *statsScheduler) enqueue(bucketName string, priority int, source string) (*statsScan, error) {
+	for _, scan := range s.scans {
+		if scan.BucketName == bucketName {
+			if scan.Status == StatsScanQueued && priority > scan.Priority {
+				scan.Priority = priority
+			}
+			return scan, nil
+		}
+	}
+
+	id, err := randomToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	scan := &statsScan{
+		StatsScan: StatsScan{
+			ID:         id,
+			BucketName: bucketName,
+			Priority:   priority,
+			Source:     source,
+			Status:     StatsScanQueued,
+			QueuedAt:   time.Now().UTC(),
+		},
+		done: make(chan struct{}),
+	}
+	s.scans[id] = scan
+	s.ready.Signal()
+
+	return scan, nil
+}
+
+// Scan counts the bucket through the queue and waits for the result
+func (s *statsScheduler) Scan(ctx context.Context, bucketName string, priority int, source string) (BucketStats, error) {
+	s.mu.Lock()
+	scan, err := s.enqueue(bucketName, priority, source)
+	s.mu.Unlock()
+	if err != nil {
+		return BucketStats{}, err
+	}
+
+	select {
+	case <-scan.done:
+		return scan.stats, scan.err
+	case <-ctx.Done():
+		return BucketStats{}, ctx.Err()
+	}
+}
+
+// Cancel drops a queued scan or aborts a running one. Waiting callers get
+// the cancellation as error.
+func (s *statsScheduler) Cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan, ok := s.scans[id]
+	if !ok {
+		return false
+	}
+
+	scan.requeue = false
+	if scan.Status == StatsScanRunning {
+		scan.cancel()
+		return true
+	}
+
+	delete(s.scans, id)
+	scan.err = context.Canceled
+	close(scan.done)
+
+	return true
+}
+
+// Requeue changes the priority of a queued scan. A running scan is aborted
+// and queued again, callers waiting for it keep waiting.
+func (s *statsScheduler) Requeue(id string, priority int) (StatsScan, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan, ok := s.scans[id]
+	if !ok {
+		return StatsScan{}, false
+	}
+
+	scan.Priority = priority
+	if scan.Status == StatsScanRunning {
+		scan.requeue = true
+		scan.cancel()
+	}
+	s.ready.Signal()
+
+	return scan.StatsScan, true
+}
+
+func (s *statsScheduler) Pending(bucketName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, scan := range s.scans {
+		if scan.BucketName == bucketName {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *statsScheduler) List() []StatsScan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]StatsScan, 0, len(s.scans))
+	for _, scan := range s.scans {
+		list = append(list, scan.StatsScan)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Status != list[j].Status {
+			return list[i].Status == StatsScanRunning
+		}
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority > list[j].Priority
+		}
+		return list[i].QueuedAt.Before(list[j].QueuedAt)
+	})
+
+	return list
+}
+
+func listStatsScans(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(statsScans.List())
+}
+
+func createStatsScan(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		BucketName string `json:"bucketName"`
+		Priority   *int   `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+	if data.BucketName == "" {
+		httpError(w, r, http.StatusBadRequest, "bucket_name_required")
+		return
+	}
+
+	priority := statsPriorityInteractive
+	if data.Priority != nil {
+		priority = *data.Priority
+	}
+
+	scan, err := statsScans.Enqueue(data.BucketName, priority, "api")
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "stats_scan_failed", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(scan)
+}
+
+func requeueStatsScan(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var data struct {
+		Priority int `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	scan, ok := statsScans.Requeue(vars["scanId"], data.Priority)
+	if !ok {
+		httpError(w, r, http.StatusNotFound, "stats_scan_not_found")
+		return
+	}
+
+	audit.Record(r, "stats_scan.requeue", scan.BucketName, "", map[string]interface{}{"scanId": scan.ID, "priority": data.Priority})
+
+	json.NewEncoder(w).Encode(scan)
+}
+
+func cancelStatsScan(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if !statsScans.Cancel(vars["scanId"]) {
+		httpError(w, r, http.StatusNotFound, "stats_scan_not_found")
+		return
+	}
+
+	audit.Record(r, "stats_scan.cancel", "", "", map[string]interface{}{"scanId": vars["scanId"]})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+type StorageClassShare struct {
+	Objects     int64   `json:"objects"`
+	Bytes       int64   `json:"bytes"`
+	BytesShare  float64 `json:"bytesShare"`
+	MonthlyCost float64 `json:"monthlyCost"`
+}
+
+type StorageClassReport struct {
+	BucketName     string                       `json:"bucketName"`
+	Prefix         string                       `json:"prefix"`
+	Objects        int64                        `json:"objects"`
+	Bytes          int64                        `json:"bytes"`
+	MonthlyCost    float64                      `json:"monthlyCost"`
+	StorageClasses map[string]StorageClassShare `json:"storageClasses"`
+	// InventoryCreatedAt is set when the report is based on an S3 Inventory
+	// delivery instead of a listing
+	InventoryCreatedAt *time.Time `json:"inventoryCreatedAt,omitempty"`
+}
+
+// buildStorageClassReport adds up the current objects below prefix per
+// storage class. Costs use the price table of the bucket's region. With an
+// inventory delivery, the objects are read from it instead of listed.
+func buildStorageClassReport(ctx context.Context, job *jobHandle, bucketName, prefix string, delivery *InventoryDelivery) (*StorageClassReport, error) {
+	report := &StorageClassReport{
+		BucketName:     bucketName,
+		Prefix:         prefix,
+		StorageClasses: map[string]StorageClassShare{},
+	}
+
+	walk := func(fn func(obj types.Object) error) error {
+		return walkObjects(ctx, bucketName, prefix, fn)
+	}
+	if delivery != nil {
+		report.InventoryCreatedAt = &delivery.CreatedAt
+		walk = func(fn func(obj types.Object) error) error {
+			return walkInventory(ctx, delivery, prefix, fn)
+		}
+	}
+
+	err := walk(func(obj types.Object) error {
+		storageClass := string(obj.StorageClass)
+		if storageClass == "" {
+			storageClass = "STANDARD"
+		}
+
+		share := report.StorageClasses[storageClass]
+		share.Objects++
+		share.Bytes += aws.ToInt64(obj.Size)
+		report.StorageClasses[storageClass] = share
+
+		report.Objects++
+		report.Bytes += aws.ToInt64(obj.Size)
+		job.Advance(1)
+		job.AddBytes(aws.ToInt64(obj.Size))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	region := regions.Locate(ctx, bucketName)
+	for storageClass, share := range report.StorageClasses {
+		if report.Bytes > 0 {
+			share.BytesShare = float64(share.Bytes) / float64(report.Bytes)
+		}
+		share.MonthlyCost = monthlyStorageCost(region, storageClass, share.Bytes)
+		report.MonthlyCost += share.MonthlyCost
+		report.StorageClasses[storageClass] = share
+	}
+
+	return report, nil
+}
+
+func createStorageClassReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Prefix string `json:"prefix"`
+		// InventoryID reads the latest delivery of this S3 Inventory report
+		// instead of listing the bucket
+		InventoryID string `json:"inventoryId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	job, err := startBucketJob(bucketName, "storage-classes", data.Prefix, func(ctx context.Context, job *jobHandle) error {
+		var delivery *InventoryDelivery
+		if data.InventoryID != "" {
+			inventory, err := getInventoryReport(ctx, bucketName, data.InventoryID)
+			if err != nil {
+				return err
+			}
+			delivery, err = latestInventoryDelivery(ctx, bucketName, inventory)
+			if err != nil {
+				return err
+			}
+			if delivery == nil {
+				return fmt.Errorf("inventory %s has not been delivered yet", data.InventoryID)
+			}
+		}
+
+		report, err := buildStorageClassReport(ctx, job, bucketName, data.Prefix, delivery)
+		if err != nil {
+			return err
+		}
+		job.SetResult(report)
+		return nil
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	writeJobAccepted(w, job)
+}
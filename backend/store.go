@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// fileStore persists a single JSON document on disk. Writes go to a temporary
+// file first and are renamed into place so a crash never leaves a truncated file.
+type fileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newFileStore(dataDir, name string) (*fileStore, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{path: filepath.Join(dataDir, name)}, nil
+}
+
+func (s *fileStore) Load(v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (s *fileStore) Save(v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, s.path)
+}
+
+// recordStore keeps a set of records in memory and persists all of them to a
+// fileStore on every change.
+type recordStore[T any] struct {
+	mu      sync.Mutex
+	store   *fileStore
+	records map[string]*T
+	id      func(*T) string
+}
+
+func newRecordStore[T any](dataDir, name string, id func(*T) string) (*recordStore[T], error) {
+	store, err := newFileStore(dataDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*T
+	if err := store.Load(&list); err != nil {
+		return nil, err
+	}
+
+	s := &recordStore[T]{
+		store:   store,
+		records: make(map[string]*T),
+		id:      id,
+	}
+	for _, record := range list {
+		s.records[id(record)] = record
+	}
+
+	return s, nil
+}
+
+// commit saves records and only replaces the records in memory once they
+// are on disk, so a failed write leaves the store as it was. It must be
+// called with s.mu held.
+func (s *recordStore[T]) commit(records map[string]*T) error {
+	list := make([]*T, 0, len(records))
+	for _, record := range records {
+		list = append(list, record)
+	}
+	if err := s.store.Save(list); err != nil {
+		return err
+	}
+	s.records = records
+
+	return nil
+}
+
+// clone copies the record map, must be called with s.mu held
+func (s *recordStore[T]) clone() map[string]*T {
+	records := make(map[string]*T, len(s.records))
+	for id, record := range s.records {
+		records[id] = record
+	}
+
+	return records
+}
+
+func (s *recordStore[T]) List(less func(a, b *T) bool) []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*T, 0, len(s.records))
+	for _, record := range s.records {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return less(list[i], list[j])
+	})
+
+	copies := make([]T, 0, len(list))
+	for _, record := range list {
+		copies = append(copies, *record)
+	}
+
+	return copies
+}
+
+func (s *recordStore[T]) Get(id string) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	return *record, true
+}
+
+func (s *recordStore[T]) Find(match func(*T) bool) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range s.records {
+		if match(record) {
+			return *record, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+func (s *recordStore[T]) Put(record T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.clone()
+	records[s.id(&record)] = &record
+
+	return s.commit(records)
+}
+
+// Update applies fn to the stored record. If fn returns an error or the
+// change cannot be saved, the record is left untouched and the error is
+// passed through.
+func (s *recordStore[T]) Update(id string, fn func(*T) error) (T, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var zero T
+	record, ok := s.records[id]
+	if !ok {
+		return zero, false, nil
+	}
+
+	updated := *record
+	if err := fn(&updated); err != nil {
+		return zero, true, err
+	}
+	records := s.clone()
+	records[id] = &updated
+	if err := s.commit(records); err != nil {
+		return zero, true, err
+	}
+
+	return updated, true, nil
+}
+
+func (s *recordStore[T]) Delete(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[id]; !ok {
+		return false, nil
+	}
+	records := s.clone()
+	delete(records, id)
+
+	return true, s.commit(records)
+}
+
+// DeleteWhere removes all records matching match and returns how many
+func (s *recordStore[T]) DeleteWhere(match func(*T) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.clone()
+	for id, record := range records {
+		if match(record) {
+			delete(records, id)
+		}
+	}
+	deleted := len(s.records) - len(records)
+	if deleted == 0 {
+		return 0, nil
+	}
+	if err := s.commit(records); err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// documentStore keeps a single settings document in memory and persists it to
+// a fileStore on every change. Until something is saved, the initial value is used.
+type documentStore[T any] struct {
+	mu    sync.Mutex
+	store *fileStore
+	value T
+}
+
+func newDocumentStore[T any](dataDir, name string, initial T) (*documentStore[T], error) {
+	store, err := newFileStore(dataDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &documentStore[T]{store: store, value: initial}
+	if err := store.Load(&s.value); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *documentStore[T]) Get() T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.value
+}
+
+func (s *documentStore[T]) Set(value T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.store.Save(value); err != nil {
+		return err
+	}
+	s.value = value
+
+	return nil
+}
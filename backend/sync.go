@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// A dry run lists at most this many keys per action
+const maxSyncPlanKeys = 1000
+
+// SyncRequest is also read from scheduled tasks in the config, copy options
+// can only be given through the API
+type SyncRequest struct {
+	SourceBucket      string `json:"sourceBucket" yaml:"source_bucket"`
+	SourcePrefix      string `json:"sourcePrefix" yaml:"source_prefix"`
+	DestinationBucket string `json:"destinationBucket" yaml:"destination_bucket"`
+	DestinationPrefix string `json:"destinationPrefix" yaml:"destination_prefix"`
+	// Delete removes objects below the destination prefix that are missing
+	// in the source
+	Delete  bool        `json:"delete" yaml:"delete"`
+	DryRun  bool        `json:"dryRun" yaml:"dry_run"`
+	Options CopyOptions `json:"options" yaml:"-"`
+}
+
+type SyncSummary struct {
+	Copied  int `json:"copied"`
+	Skipped int `json:"skipped"`
+	Deleted int `json:"deleted"`
+	Failed  int `json:"failed"`
+	// ToCopy and ToDelete are only filled by dry runs
+	ToCopy   []string `json:"toCopy,omitempty"`
+	ToDelete []string `json:"toDelete,omitempty"`
+}
+
+type syncedObject struct {
+	size         int64
+	etag         string
+	lastModified time.Time
+}
+
+func syncedObjectOf(obj types.Object) syncedObject {
+	return syncedObject{
+		size:         aws.ToInt64(obj.Size),
+		etag:         strings.Trim(aws.ToString(obj.ETag), `"`),
+		lastModified: aws.ToTime(obj.LastModified),
+	}
+}
+
+// upToDate compares size and ETag. ETags of multipart uploads depend on the
+// part size, which a copy does not keep, so those count as up to date when
+// the copy is newer than the source.
+func (dst syncedObject) upToDate(src syncedObject) bool {
+	if dst.size != src.size {
+		return false
+	}
+	if strings.Contains(dst.etag, "-") || strings.Contains(src.etag, "-") {
+		return !dst.lastModified.Before(src.lastModified)
+	}
+
+	return dst.etag == src.etag
+}
+
+func normalizeSyncPrefix(prefix string) string {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return prefix
+}
+
+// validate checks the request and normalizes its prefixes
+func (s *SyncRequest) validate() *apiError {
+	if apiErr := s.Options.validate(); apiErr != nil {
+		return apiErr
+	}
+	if s.SourceBucket == "" || s.DestinationBucket == "" {
+		return newAPIError("sync_buckets_required")
+	}
+	s.SourcePrefix = normalizeSyncPrefix(s.SourcePrefix)
+	s.DestinationPrefix = normalizeSyncPrefix(s.DestinationPrefix)
+
+	// Syncing into a subfolder of itself would keep feeding the listing new keys
+	if s.SourceBucket == s.DestinationBucket &&
+		(strings.HasPrefix(s.DestinationPrefix, s.SourcePrefix) || strings.HasPrefix(s.SourcePrefix, s.DestinationPrefix)) {
+		return newAPIError("sync_prefixes_overlap")
+	}
+
+	return nil
+}
+
+// runSync mirrors the source prefix to the destination prefix. The
+// destination is listed first, so the source can be compared while it is
+// walked.
+func runSync(ctx context.Context, job *jobHandle, request SyncRequest) error {
+	existing := map[string]syncedObject{}
+	err := walkObjects(ctx, request.DestinationBucket, request.DestinationPrefix, func(obj types.Object) error {
+		existing[strings.TrimPrefix(aws.ToString(obj.Key), request.DestinationPrefix)] = syncedObjectOf(obj)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	summary := &SyncSummary{}
+	err = walkObjects(ctx, request.SourceBucket, request.SourcePrefix, func(obj types.Object) error {
+		srcKey := aws.ToString(obj.Key)
+		relative := strings.TrimPrefix(srcKey, request.SourcePrefix)
+
+		dst, exists := existing[relative]
+		delete(existing, relative)
+		if exists && dst.upToDate(syncedObjectOf(obj)) {
+			summary.Skipped++
+			return nil
+		}
+
+		if request.DryRun {
+			if len(summary.ToCopy) < maxSyncPlanKeys {
+				summary.ToCopy = append(summary.ToCopy, srcKey)
+			}
+			summary.Copied++
+			return nil
+		}
+
+		job.AddTotal(1)
+		err := copyObject(ctx, request.SourceBucket, srcKey, request.DestinationBucket, request.DestinationPrefix+relative, request.Options, job.AddBytes)
+		job.Done(srcKey, err)
+		if err != nil {
+			summary.Failed++
+		} else {
+			summary.Copied++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if request.Delete {
+		var extraneous []string
+		for relative := range existing {
+			extraneous = append(extraneous, request.DestinationPrefix+relative)
+		}
+
+		if request.DryRun {
+			summary.Deleted = len(extraneous)
+			summary.ToDelete = extraneous[:min(len(extraneous), maxSyncPlanKeys)]
+		} else if len(extraneous) > 0 {
+			job.AddTotal(len(extraneous))
+			deleted, failed, err := deleteKeys(ctx, request.DestinationBucket, extraneous)
+			job.Advance(len(deleted))
+			for _, e := range failed {
+				job.Report(e.Key, newAPIError("sync_delete_failed", e.Code, e.Message))
+			}
+			summary.Deleted += len(deleted)
+			summary.Failed += len(failed)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !request.DryRun {
+		publishObjectEvent(ObjectUpdated, request.DestinationBucket, request.DestinationPrefix)
+	}
+	job.SetResult(summary)
+
+	return nil
+}
+
+func startSync(request SyncRequest) (Job, error) {
+	return startBucketJob(request.DestinationBucket, "sync", request.DestinationPrefix, func(ctx context.Context, job *jobHandle) error {
+		return runSync(ctx, job, request)
+	})
+}
+
+// createSync starts a job mirroring a bucket or prefix to another bucket or
+// prefix, copying new and changed objects and optionally deleting the ones
+// no longer in the source
+func createSync(w http.ResponseWriter, r *http.Request) {
+	var request SyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+	if apiErr := request.validate(); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+	if !request.DryRun && !checkNotFrozen(w, r, request.DestinationBucket) {
+		return
+	}
+
+	job, err := startSync(request)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	if !request.DryRun {
+		audit.Record(r, "sync.start", request.DestinationBucket, request.DestinationPrefix, map[string]interface{}{
+			"jobId":        job.ID,
+			"sourceBucket": request.SourceBucket,
+			"sourcePrefix": request.SourcePrefix,
+			"delete":       request.Delete,
+		})
+	}
+
+	writeJobAccepted(w, job)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultTablePreviewRows = 100
+	maxTablePreviewRows     = 1000
+	// Only the start of the object is fetched, rows beyond it are not shown
+	tablePreviewBytes = 4 << 20
+)
+
+type TablePreview struct {
+	Columns   []string   `json:"columns"`
+	Rows      [][]string `json:"rows"`
+	Truncated bool       `json:"truncated"`
+}
+
+// tableFormat picks the delimiter from the format parameter, the extension or
+// the content type. Parquet would need a reader this backend does not have.
+func tableFormat(format, key, contentType string) (rune, bool) {
+	if format == "" {
+		switch {
+		case strings.EqualFold(path.Ext(key), ".tsv"), strings.EqualFold(path.Ext(key), ".tab"),
+			strings.HasPrefix(contentType, "text/tab-separated-values"):
+			format = "tsv"
+		case strings.EqualFold(path.Ext(key), ".csv"), strings.HasPrefix(contentType, "text/csv"):
+			format = "csv"
+		}
+	}
+
+	switch format {
+	case "csv":
+		return ',', true
+	case "tsv":
+		return '\t', true
+	default:
+		return 0, false
+	}
+}
+
+// readTable reads the header and up to limit rows. When the object was cut
+// off, the last record read may be incomplete and is dropped.
+func readTable(body io.Reader, delimiter rune, limit int, partial bool) (*TablePreview, error) {
+	reader := csv.NewReader(body)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	preview := &TablePreview{Columns: []string{}, Rows: [][]string{}}
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return preview, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	preview.Columns = header
+
+	for len(preview.Rows) <= limit {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if partial {
+				preview.Truncated = true
+				return preview, nil
+			}
+			return nil, err
+		}
+		preview.Rows = append(preview.Rows, record)
+	}
+
+	if len(preview.Rows) > limit {
+		preview.Rows = preview.Rows[:limit]
+		preview.Truncated = true
+	} else if partial && len(preview.Rows) > 0 {
+		preview.Rows = preview.Rows[:len(preview.Rows)-1]
+		preview.Truncated = true
+	}
+
+	return preview, nil
+}
+
+func previewTable(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+	query := r.URL.Query()
+
+	limit := defaultTablePreviewRows
+	if value := query.Get("rows"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxTablePreviewRows {
+			httpError(w, r, http.StatusBadRequest, "table_rows_invalid", maxTablePreviewRows)
+			return
+		}
+		limit = parsed
+	}
+
+	ctx := r.Context()
+	result, err := transferClient(ctx, bucketName).GetObject(ctx, &s3.GetObjectInput{
+		Bucket:       aws.String(bucketName),
+		Key:          aws.String(objectKey),
+		VersionId:    optionalVersionID(r),
+		Range:        aws.String("bytes=0-" + strconv.Itoa(tablePreviewBytes-1)),
+		RequestPayer: requestPayer(ctx, bucketName),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		httpError(w, r, http.StatusNotFound, "object_not_found", err)
+		return
+	}
+	// Empty objects cannot satisfy any range
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		json.NewEncoder(w).Encode(TablePreview{Columns: []string{}, Rows: [][]string{}})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "download_failed", err)
+		return
+	}
+	defer result.Body.Close()
+
+	delimiter, ok := tableFormat(query.Get("format"), objectKey, aws.ToString(result.ContentType))
+	if !ok {
+		httpError(w, r, http.StatusUnsupportedMediaType, "table_format_unsupported")
+		return
+	}
+
+	// Content-Range is "bytes 0-N/total", the object was cut off when total is larger
+	partial := false
+	if contentRange := aws.ToString(result.ContentRange); contentRange != "" {
+		if total, err := strconv.ParseInt(contentRange[strings.LastIndex(contentRange, "/")+1:], 10, 64); err == nil {
+			partial = total > tablePreviewBytes
+		}
+	}
+
+	preview, err := readTable(result.Body, delimiter, limit, partial)
+	if err != nil {
+		httpError(w, r, http.StatusUnprocessableEntity, "table_parse_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(preview)
+}
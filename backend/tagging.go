@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxObjectTags  = 10
+	maxBucketTags  = 50
+	maxTagKeyLen   = 128
+	maxTagValueLen = 256
+)
+
+func tagsToMap(tagSet []types.Tag) map[string]string {
+	tags := make(map[string]string, len(tagSet))
+	for _, tag := range tagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	return tags
+}
+
+func tagsFromMap(tags map[string]string) []types.Tag {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tagSet := make([]types.Tag, 0, len(tags))
+	for _, key := range keys {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+
+	return tagSet
+}
+
+func validateTags(tags map[string]string, maxTags int) *apiError {
+	if len(tags) > maxTags {
+		return newAPIError("tags_too_many", maxTags)
+	}
+
+	for key, value := range tags {
+		if key == "" || utf8.RuneCountInString(key) > maxTagKeyLen {
+			return newAPIError("tag_key_invalid", key, maxTagKeyLen)
+		}
+		if utf8.RuneCountInString(value) > maxTagValueLen {
+			return newAPIError("tag_value_invalid", key, maxTagValueLen)
+		}
+	}
+
+	return nil
+}
+
+func getObjectTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetObjectTagging(r.Context(), &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_tags_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tagsToMap(result.TagSet))
+}
+
+func putObjectTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	var tags map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&tags); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if apiErr := validateTags(tags, maxObjectTags); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	_, err := bucketClient(r.Context(), bucketName).PutObjectTagging(r.Context(), &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(bucketName),
+		Key:     aws.String(objectKey),
+		Tagging: &types.Tagging{TagSet: tagsFromMap(tags)},
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_tags_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tags)
+}
+
+func deleteObjectTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	_, err := bucketClient(r.Context(), bucketName).DeleteObjectTagging(r.Context(), &s3.DeleteObjectTaggingInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "delete_tags_failed", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func getBucketTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	result, err := bucketClient(r.Context(), bucketName).GetBucketTagging(r.Context(), &s3.GetBucketTaggingInput{
+		Bucket: aws.String(bucketName),
+	})
+
+	// Buckets without tags answer with an error instead of an empty set
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchTagSet" {
+		json.NewEncoder(w).Encode(map[string]string{})
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "get_tags_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tagsToMap(result.TagSet))
+}
+
+func putBucketTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var tags map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&tags); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if apiErr := validateTags(tags, maxBucketTags); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	// An empty tag set is rejected by PutBucketTagging, clearing means deleting
+	if len(tags) == 0 {
+		deleteBucketTags(w, r)
+		return
+	}
+
+	_, err := bucketClient(r.Context(), bucketName).PutBucketTagging(r.Context(), &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucketName),
+		Tagging: &types.Tagging{TagSet: tagsFromMap(tags)},
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "put_tags_failed", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tags)
+}
+
+func deleteBucketTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	_, err := bucketClient(r.Context(), bucketName).DeleteBucketTagging(r.Context(), &s3.DeleteBucketTaggingInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "delete_tags_failed", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+// Decoding allocates about 4 bytes per pixel, this caps it at 256 MB
+const maxThumbnailPixels = 64 << 20
+
+var thumbnails thumbnailCache
+
+// thumbnailName identifies a rendition of one version of an object, so
+// overwriting the object never serves a stale thumbnail
+func thumbnailName(bucketName, key, etag string, size int) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d", bucketName, key, etag, size)))
+	return hex.EncodeToString(hash[:])
+}
+
+// resizeImage scales src down to fit into size x size, averaging the source
+// pixels covered by each target pixel
+func resizeImage(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return src
+	}
+
+	dstWidth, dstHeight := size, size
+	if width > height {
+		dstHeight = max(height*size/width, 1)
+	} else {
+		dstWidth = max(width*size/height, 1)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := max(bounds.Min.Y+(y+1)*height/dstHeight, y0+1)
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := max(bounds.Min.X+(x+1)*width/dstWidth, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+
+	return dst
+}
+
+// renderThumbnail encodes photos as JPEG and everything else as PNG to keep
+// transparency
+func renderThumbnail(source io.Reader, size int) ([]byte, string, error) {
+	data, err := io.ReadAll(source)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Small files can still decode into huge images
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(config.Width)*int64(config.Height) > maxThumbnailPixels {
+		return nil, "", fmt.Errorf("image has %dx%d pixels", config.Width, config.Height)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, resizeImage(img, size), &jpeg.Options{Quality: 80})
+		return buf.Bytes(), "image/jpeg", err
+	}
+
+	err = png.Encode(&buf, resizeImage(img, size))
+	return buf.Bytes(), "image/png", err
+}
+
+// thumbnailCache stores rendered thumbnails either in the data directory or
+// below a prefix of the bucket the image lives in
+type thumbnailCache interface {
+	Get(ctx context.Context, bucketName, name string) ([]byte, string, bool)
+	Put(ctx context.Context, bucketName, name, contentType string, data []byte) error
+}
+
+type localThumbnailCache struct {
+	dir string
+}
+
+func (c *localThumbnailCache) Get(ctx context.Context, bucketName, name string) ([]byte, string, bool) {
+	for _, contentType := range []string{"image/jpeg", "image/png"} {
+		data, err := os.ReadFile(filepath.Join(c.dir, name+thumbnailExtension(contentType)))
+		if err == nil {
+			return data, contentType, true
+		}
+	}
+
+	return nil, "", false
+}
+
+func (c *localThumbnailCache) Put(ctx context.Context, bucketName, name, contentType string, data []byte) error {
+	return os.WriteFile(filepath.Join(c.dir, name+thumbnailExtension(contentType)), data, 0o644)
+}
+
+type bucketThumbnailCache struct {
+	prefix string
+}
+
+func (c *bucketThumbnailCache) Get(ctx context.Context, bucketName, name string) ([]byte, string, bool) {
+	result, err := bucketClient(ctx, bucketName).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(c.prefix + name),
+	})
+	if err != nil {
+		return nil, "", false
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, "", false
+	}
+
+	return data, aws.ToString(result.ContentType), true
+}
+
+func (c *bucketThumbnailCache) Put(ctx context.Context, bucketName, name, contentType string, data []byte) error {
+	// Frozen buckets must not change, not even for a cache
+	if _, frozen := freezes.Get(bucketName); frozen {
+		return nil
+	}
+
+	_, err := bucketClient(ctx, bucketName).PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(c.prefix + name),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+
+	return err
+}
+
+func thumbnailExtension(contentType string) string {
+	if contentType == "image/jpeg" {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+func newThumbnailCache() (thumbnailCache, error) {
+	cfg := appConfig.Thumbnails
+
+	switch cfg.Storage {
+	case "local":
+		dir := filepath.Join(appConfig.Storage.DataDir, "thumbnails")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		return &localThumbnailCache{dir: dir}, nil
+	case "bucket":
+		return &bucketThumbnailCache{prefix: strings.TrimSuffix(cfg.Prefix, "/") + "/"}, nil
+	default:
+		return nil, fmt.Errorf("unknown thumbnails storage %q, expected local or bucket", cfg.Storage)
+	}
+}
+
+func getThumbnail(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+	cfg := appConfig.Thumbnails
+
+	size := cfg.MaxSize
+	if value := r.URL.Query().Get("size"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			httpError(w, r, http.StatusBadRequest, "thumbnail_size_invalid", value)
+			return
+		}
+		size = min(parsed, cfg.MaxSize)
+	}
+
+	ctx := r.Context()
+	head, err := bucketClient(ctx, bucketName).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		httpError(w, r, http.StatusNotFound, "object_not_found", err)
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "download_failed", err)
+		return
+	}
+
+	etag := aws.ToString(head.ETag)
+	name := thumbnailName(bucketName, objectKey, etag, size)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", `"`+name+`"`)
+	if r.Header.Get("If-None-Match") == `"`+name+`"` {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, contentType, cached := thumbnails.Get(ctx, bucketName, name)
+	if !cached {
+		if aws.ToInt64(head.ContentLength) > int64(cfg.MaxSourceMB)<<20 {
+			httpError(w, r, http.StatusRequestEntityTooLarge, "thumbnail_source_too_large", cfg.MaxSourceMB)
+			return
+		}
+
+		result, err := bucketClient(ctx, bucketName).GetObject(ctx, &s3.GetObjectInput{
+			Bucket:  aws.String(bucketName),
+			Key:     aws.String(objectKey),
+			IfMatch: head.ETag,
+		})
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "download_failed", err)
+			return
+		}
+		defer result.Body.Close()
+
+		data, contentType, err = renderThumbnail(result.Body, size)
+		if err != nil {
+			httpError(w, r, http.StatusUnsupportedMediaType, "thumbnail_unsupported", err)
+			return
+		}
+
+		// A failing cache only costs rendering the thumbnail again
+		if err := thumbnails.Put(ctx, bucketName, name, contentType, data); err != nil {
+			log.Printf("failed to cache thumbnail of %s/%s: %v", bucketName, objectKey, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
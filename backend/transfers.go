@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var transfers *recordStore[TransferRequest]
+
+// TransferRequest asks an external recipient to upload files through an inbox
+type TransferRequest struct {
+	ID             string    `json:"id"`
+	InboxID        string    `json:"inboxId"`
+	BucketName     string    `json:"bucketName"`
+	Prefix         string    `json:"prefix"`
+	RecipientEmail string    `json:"recipientEmail"`
+	RequestedBy    string    `json:"requestedBy"`
+	Message        string    `json:"message,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	ReceivedFiles  []string  `json:"receivedFiles"`
+}
+
+func newTransferStore(dataDir string) (*recordStore[TransferRequest], error) {
+	return newRecordStore(dataDir, "transfers.json", func(transfer *TransferRequest) string {
+		return transfer.ID
+	})
+}
+
+func inboxURL(inbox Inbox) string {
+	return strings.TrimSuffix(appConfig.Server.PublicURL, "/") + "/inbox/" + inbox.Token
+}
+
+// notifyTransferUpload tells the requester of a transfer that a file arrived in its inbox
+func notifyTransferUpload(inbox Inbox, key string, size int64) {
+	transfer, found := transfers.Find(func(transfer *TransferRequest) bool {
+		return transfer.InboxID == inbox.ID
+	})
+	if !found {
+		return
+	}
+
+	_, _, err := transfers.Update(transfer.ID, func(transfer *TransferRequest) error {
+		transfer.ReceivedFiles = append(transfer.ReceivedFiles, key)
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to record upload for transfer %s: %v", transfer.ID, err)
+	}
+
+	go func() {
+		subject := fmt.Sprintf("New file from %s", transfer.RecipientEmail)
+		body := fmt.Sprintf("%s uploaded a file for your transfer request.\n\nBucket: %s\nKey: %s\nSize: %d bytes\n",
+			transfer.RecipientEmail, inbox.BucketName, key, size)
+
+		if err := sendMail([]string{transfer.RequestedBy}, subject, body); err != nil {
+			log.Printf("failed to notify %s about transfer %s: %v", transfer.RequestedBy, transfer.ID, err)
+		}
+	}()
+}
+
+func listTransfers(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(transfers.List(func(a, b *TransferRequest) bool {
+		return a.CreatedAt.After(b.CreatedAt)
+	}))
+}
+
+func createTransfer(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		BucketName     string `json:"bucketName"`
+		Prefix         string `json:"prefix"`
+		RecipientEmail string `json:"recipientEmail"`
+		RequestedBy    string `json:"requestedBy"`
+		Message        string `json:"message"`
+		Expires        int    `json:"expires"`
+		MaxFileSize    int64  `json:"maxFileSize"`
+		MaxFiles       int    `json:"maxFiles"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	if data.BucketName == "" {
+		httpError(w, r, http.StatusBadRequest, "bucket_name_required")
+		return
+	}
+	if data.Expires <= 0 || data.MaxFileSize <= 0 || data.MaxFiles <= 0 {
+		httpError(w, r, http.StatusBadRequest, "inbox_limits_required")
+		return
+	}
+	if _, err := mail.ParseAddress(data.RecipientEmail); err != nil {
+		httpError(w, r, http.StatusBadRequest, "email_invalid", data.RecipientEmail)
+		return
+	}
+	if _, err := mail.ParseAddress(data.RequestedBy); err != nil {
+		httpError(w, r, http.StatusBadRequest, "email_invalid", data.RequestedBy)
+		return
+	}
+	if !mailConfigured() || appConfig.Server.PublicURL == "" {
+		httpError(w, r, http.StatusServiceUnavailable, "transfer_mail_not_configured")
+		return
+	}
+
+	note := fmt.Sprintf("Requested by %s", data.RequestedBy)
+	if data.Message != "" {
+		note = data.Message
+	}
+
+	inbox, err := newInbox(data.BucketName, data.Prefix, note, data.RequestedBy, time.Duration(data.Expires)*time.Second, data.MaxFileSize, data.MaxFiles)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "transfer_create_failed", err)
+		return
+	}
+
+	id, err := randomToken(8)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "transfer_create_failed", err)
+		return
+	}
+
+	transfer := TransferRequest{
+		ID:             id,
+		InboxID:        inbox.ID,
+		BucketName:     inbox.BucketName,
+		Prefix:         inbox.Prefix,
+		RecipientEmail: data.RecipientEmail,
+		RequestedBy:    data.RequestedBy,
+		Message:        data.Message,
+		CreatedAt:      time.Now().UTC(),
+		ReceivedFiles:  []string{},
+	}
+	if err := transfers.Put(transfer); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "transfer_create_failed", err)
+		return
+	}
+
+	subject := fmt.Sprintf("%s requests files from you", data.RequestedBy)
+	body := fmt.Sprintf("%s\n\nPlease upload your files here:\n%s\n\nThe link expires on %s and accepts up to %d files of at most %d bytes each.\n",
+		note, inboxURL(inbox), inbox.ExpiresAt.Format(time.RFC1123), inbox.MaxFiles, inbox.MaxFileSize)
+
+	if err := sendMail([]string{data.RecipientEmail}, subject, body); err != nil {
+		httpError(w, r, http.StatusBadGateway, "transfer_mail_failed", err)
+		return
+	}
+
+	audit.Record(r, "transfer.create", transfer.BucketName, transfer.Prefix, map[string]interface{}{
+		"transferId":     transfer.ID,
+		"inboxId":        inbox.ID,
+		"recipientEmail": transfer.RecipientEmail,
+		"requestedBy":    transfer.RequestedBy,
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transfer)
+}
+
+func deleteTransfer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	transfer, found := transfers.Get(vars["transferId"])
+	if !found {
+		httpError(w, r, http.StatusNotFound, "transfer_not_found")
+		return
+	}
+
+	// Without its inbox the upload link of the transfer stops working as well
+	if _, err := inboxes.Delete(transfer.InboxID); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "transfer_delete_failed", err)
+		return
+	}
+	if _, err := transfers.Delete(transfer.ID); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "transfer_delete_failed", err)
+		return
+	}
+
+	audit.Record(r, "transfer.delete", transfer.BucketName, transfer.Prefix, map[string]interface{}{
+		"transferId": transfer.ID,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/smithy-go"
+)
+
+// What to do when an upload targets a key that already exists
+const (
+	conflictOverwrite = "overwrite"
+	conflictFail      = "fail"
+	conflictKeepBoth  = "keepBoth"
+)
+
+var conflictPolicies = []string{conflictOverwrite, conflictFail, conflictKeepBoth}
+
+// Give up on keepBoth after "report (99).pdf"
+const maxKeepBothAttempts = 100
+
+var errObjectExists = errors.New("object already exists")
+
+// numberedKey inserts a counter before the extension, "a/report.pdf" becomes
+// "a/report (1).pdf"
+func numberedKey(key string, n int) string {
+	ext := path.Ext(path.Base(key))
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(key, ext), n, ext)
+}
+
+// resolveUploadKey returns the key to upload to under the given policy, or
+// errObjectExists when the upload has to be rejected
+func resolveUploadKey(ctx context.Context, bucketName, key, policy string) (string, error) {
+	if policy == conflictOverwrite {
+		return key, nil
+	}
+
+	for n := 0; n < maxKeepBothAttempts; n++ {
+		candidate := key
+		if n > 0 {
+			candidate = numberedKey(key, n)
+		}
+
+		exists, err := objectExists(ctx, bucketName, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		if policy == conflictFail {
+			return "", errObjectExists
+		}
+	}
+
+	return "", errObjectExists
+}
+
+// isPreconditionFailed detects a conditional put losing against a concurrent
+// upload to the same key
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed"
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	versionHeader       = "X-S3Admin-Version"
+	clientVersionHeader = "X-S3Admin-Client-Version"
+)
+
+// version is set at build time with -ldflags "-X main.version=<version>"
+var version = "dev"
+
+type BuildInfo struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commitTime,omitempty"`
+	Modified   bool   `json:"modified"`
+	GoVersion  string `json:"goVersion"`
+}
+
+var (
+	buildInfoOnce sync.Once
+	currentBuild  BuildInfo
+)
+
+func buildInfo() BuildInfo {
+	buildInfoOnce.Do(func() {
+		currentBuild = BuildInfo{Version: version, GoVersion: runtime.Version()}
+
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				currentBuild.Commit = setting.Value
+			case "vcs.time":
+				currentBuild.CommitTime = setting.Value
+			case "vcs.modified":
+				currentBuild.Modified = setting.Value == "true"
+			}
+		}
+
+		// Development builds are still told apart by their commit
+		if currentBuild.Version == "dev" && len(currentBuild.Commit) >= 12 {
+			currentBuild.Version = "dev-" + currentBuild.Commit[:12]
+		}
+	})
+
+	return currentBuild
+}
+
+// versionMiddleware announces the backend version on every API response. A
+// client sending the version it was built for gets a conflict when it does not
+// match, so a stale cached SPA can prompt for a reload instead of failing on
+// changed payloads.
+func versionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := buildInfo().Version
+		w.Header().Set(versionHeader, current)
+
+		// The version endpoint itself always answers, it is how clients find out what to expect
+		client := r.Header.Get(clientVersionHeader)
+		if client != "" && client != current && r.URL.Path != appConfig.Server.BasePath+"/api/version" {
+			httpError(w, r, http.StatusConflict, "client_version_mismatch", client, current)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getVersion(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(buildInfo())
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+)
+
+type VersionSummary struct {
+	Key                string     `json:"key"`
+	Size               int64      `json:"size"`
+	LastModified       *time.Time `json:"lastModified,omitempty"`
+	IsDeleted          bool       `json:"isDeleted"`
+	VersionCount       int        `json:"versionCount"`
+	DeleteMarkerCount  int        `json:"deleteMarkerCount"`
+	TotalVersionBytes  int64      `json:"totalVersionBytes"`
+	NoncurrentVersions int        `json:"noncurrentVersions"`
+	NoncurrentBytes    int64      `json:"noncurrentBytes"`
+}
+
+func listVersionSummary(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	prefix := r.URL.Query().Get("prefix")
+
+	// Ensure the prefix, if not empty, ends with a slash
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	summaries := make(map[string]*VersionSummary)
+	summaryFor := func(key string) *VersionSummary {
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &VersionSummary{Key: key}
+			summaries[key] = summary
+		}
+		return summary
+	}
+
+	var folders []string
+	paginator := s3.NewListObjectVersionsPaginator(bucketClient(r.Context(), bucketName), &s3.ListObjectVersionsInput{
+		Bucket:    aws.String(bucketName),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(r.Context())
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "list_versions_failed", err)
+			return
+		}
+
+		for _, version := range page.Versions {
+			// Do not include the folder itself in the list of objects
+			if *version.Key == prefix {
+				continue
+			}
+
+			summary := summaryFor(*version.Key)
+			size := aws.ToInt64(version.Size)
+			summary.VersionCount++
+			summary.TotalVersionBytes += size
+
+			if aws.ToBool(version.IsLatest) {
+				summary.Size = size
+				summary.LastModified = version.LastModified
+			} else {
+				summary.NoncurrentVersions++
+				summary.NoncurrentBytes += size
+			}
+		}
+
+		for _, marker := range page.DeleteMarkers {
+			if *marker.Key == prefix {
+				continue
+			}
+
+			summary := summaryFor(*marker.Key)
+			summary.DeleteMarkerCount++
+			if aws.ToBool(marker.IsLatest) {
+				summary.IsDeleted = true
+				summary.LastModified = marker.LastModified
+			}
+		}
+
+		for _, p := range page.CommonPrefixes {
+			folders = append(folders, *p.Prefix)
+		}
+	}
+
+	list := make([]*VersionSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		list = append(list, summary)
+	}
+
+	switch r.URL.Query().Get("sort") {
+	case "versions":
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].VersionCount > list[j].VersionCount
+		})
+	case "bytes":
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].NoncurrentBytes > list[j].NoncurrentBytes
+		})
+	default:
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Key < list[j].Key
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"objects": list,
+		"folders": folders,
+	})
+}
+
+type ObjectVersion struct {
+	Key            string     `json:"key"`
+	VersionID      string     `json:"versionId"`
+	IsLatest       bool       `json:"isLatest"`
+	IsDeleteMarker bool       `json:"isDeleteMarker"`
+	Size           int64      `json:"size"`
+	ETag           string     `json:"etag,omitempty"`
+	StorageClass   string     `json:"storageClass,omitempty"`
+	LastModified   *time.Time `json:"lastModified,omitempty"`
+}
+
+func listVersions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	query := r.URL.Query()
+
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(query.Get("prefix")),
+	}
+	if keyMarker := query.Get("keyMarker"); keyMarker != "" {
+		input.KeyMarker = aws.String(keyMarker)
+	}
+	if versionIDMarker := query.Get("versionIdMarker"); versionIDMarker != "" {
+		input.VersionIdMarker = aws.String(versionIDMarker)
+	}
+	if maxKeys, err := strconv.Atoi(query.Get("maxKeys")); err == nil && maxKeys > 0 {
+		input.MaxKeys = aws.Int32(int32(min(maxKeys, 1000)))
+	}
+
+	result, err := bucketClient(r.Context(), bucketName).ListObjectVersions(r.Context(), input)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "list_versions_failed", err)
+		return
+	}
+
+	versions := make([]ObjectVersion, 0, len(result.Versions)+len(result.DeleteMarkers))
+	for _, version := range result.Versions {
+		versions = append(versions, ObjectVersion{
+			Key:          aws.ToString(version.Key),
+			VersionID:    aws.ToString(version.VersionId),
+			IsLatest:     aws.ToBool(version.IsLatest),
+			Size:         aws.ToInt64(version.Size),
+			ETag:         aws.ToString(version.ETag),
+			StorageClass: string(version.StorageClass),
+			LastModified: version.LastModified,
+		})
+	}
+	for _, marker := range result.DeleteMarkers {
+		versions = append(versions, ObjectVersion{
+			Key:            aws.ToString(marker.Key),
+			VersionID:      aws.ToString(marker.VersionId),
+			IsLatest:       aws.ToBool(marker.IsLatest),
+			IsDeleteMarker: true,
+			LastModified:   marker.LastModified,
+		})
+	}
+
+	// S3 returns versions and delete markers separately, merge them back
+	// into key order with the newest version first
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].Key != versions[j].Key {
+			return versions[i].Key < versions[j].Key
+		}
+		return aws.ToTime(versions[i].LastModified).After(aws.ToTime(versions[j].LastModified))
+	})
+
+	response := map[string]interface{}{
+		"versions":    versions,
+		"isTruncated": aws.ToBool(result.IsTruncated),
+	}
+	if aws.ToBool(result.IsTruncated) {
+		response["nextKeyMarker"] = aws.ToString(result.NextKeyMarker)
+		response["nextVersionIdMarker"] = aws.ToString(result.NextVersionIdMarker)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// latestDeleteMarker returns the version id of the delete marker hiding key, if any
+func latestDeleteMarker(ctx context.Context, bucketName, key string) (string, error) {
+	var versionID string
+
+	err := walkVersions(ctx, bucketName, key, func(page *s3.ListObjectVersionsOutput) error {
+		for _, marker := range page.DeleteMarkers {
+			if aws.ToString(marker.Key) == key && aws.ToBool(marker.IsLatest) {
+				versionID = aws.ToString(marker.VersionId)
+			}
+		}
+		return nil
+	})
+
+	return versionID, err
+}
+
+func deleteVersion(ctx context.Context, bucketName, key, versionID string) error {
+	_, err := bucketClient(ctx, bucketName).DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	})
+
+	return err
+}
+
+func undeleteObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	objectKey := vars["objectKey"]
+
+	ctx := r.Context()
+
+	versionID, err := latestDeleteMarker(ctx, bucketName, objectKey)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "list_versions_failed", err)
+		return
+	}
+	if versionID == "" {
+		httpError(w, r, http.StatusConflict, "object_not_deleted", objectKey)
+		return
+	}
+
+	if err := deleteVersion(ctx, bucketName, objectKey, versionID); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "undelete_failed", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func undeletePrefix(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Prefix string `json:"prefix"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	job, err := startBucketJob(bucketName, "undelete", data.Prefix, func(ctx context.Context, job *jobHandle) error {
+		return walkVersions(ctx, bucketName, data.Prefix, func(page *s3.ListObjectVersionsOutput) error {
+			for _, marker := range page.DeleteMarkers {
+				if !aws.ToBool(marker.IsLatest) {
+					continue
+				}
+
+				job.AddTotal(1)
+				key := aws.ToString(marker.Key)
+				job.Report(key, deleteVersion(ctx, bucketName, key, aws.ToString(marker.VersionId)))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	writeJobAccepted(w, job)
+}
+
+// purgeNoncurrentVersions deletes all versions under prefix that are not the
+// current one, including delete markers that no longer hide an object.
+func purgeNoncurrentVersions(ctx context.Context, job *jobHandle, bucketName, prefix string) error {
+	return walkVersions(ctx, bucketName, prefix, func(page *s3.ListObjectVersionsOutput) error {
+		var objectsToDelete []types.ObjectIdentifier
+		sizes := make(map[string]int64)
+
+		for _, version := range page.Versions {
+			if aws.ToBool(version.IsLatest) {
+				continue
+			}
+			objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+			sizes[aws.ToString(version.VersionId)] = aws.ToInt64(version.Size)
+		}
+		for _, marker := range page.DeleteMarkers {
+			if aws.ToBool(marker.IsLatest) {
+				continue
+			}
+			objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+		}
+
+		if len(objectsToDelete) == 0 {
+			return nil
+		}
+		job.AddTotal(len(objectsToDelete))
+
+		// A listing page holds at most 1000 entries, which fits a single DeleteObjects call
+		result, err := bucketClient(ctx, bucketName).DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{Objects: objectsToDelete},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range result.Deleted {
+			job.AddBytes(sizes[aws.ToString(obj.VersionId)])
+		}
+		job.Advance(len(result.Deleted))
+		for _, e := range result.Errors {
+			job.Report(aws.ToString(e.Key)+"@"+aws.ToString(e.VersionId), fmt.Errorf("%s: %s", aws.ToString(e.Code), aws.ToString(e.Message)))
+		}
+
+		return nil
+	})
+}
+
+func purgeVersions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+
+	var data struct {
+		Prefix string `json:"prefix"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid_request_body")
+		return
+	}
+
+	job, err := startBucketJob(bucketName, "purge-versions", data.Prefix, func(ctx context.Context, job *jobHandle) error {
+		return purgeNoncurrentVersions(ctx, job, bucketName, data.Prefix)
+	})
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "job_start_failed", err)
+		return
+	}
+
+	audit.Record(r, "versions.purge", bucketName, data.Prefix, map[string]interface{}{"jobId": job.ID})
+
+	writeJobAccepted(w, job)
+}
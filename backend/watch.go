@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ObjectCreated = "object.created"
+	ObjectUpdated = "object.updated"
+	ObjectDeleted = "object.deleted"
+)
+
+// ObjectEvent tells about an object written or deleted through the backend,
+// or found changed by comparing listings. Keys ending in / stand for a whole
+// folder.
+type ObjectEvent struct {
+	BucketName string `json:"bucketName"`
+	Key        string `json:"key"`
+	Source     string `json:"source"`
+}
+
+type watchMessage struct {
+	Type string `json:"type"`
+	ObjectEvent
+}
+
+func publishObjectEvent(eventType, bucketName, key string) {
+	events.Publish(eventType, ObjectEvent{BucketName: bucketName, Key: key, Source: "backend"})
+}
+
+// watched reports whether an event concerns the watched folder, events of
+// a parent folder concern all folders below it
+func (e ObjectEvent) watched(bucketName, prefix string) bool {
+	if e.BucketName != bucketName {
+		return false
+	}
+
+	return strings.HasPrefix(e.Key, prefix) || (strings.HasSuffix(e.Key, "/") && strings.HasPrefix(prefix, e.Key))
+}
+
+// listFolderETags lists the first page of a folder, which is what the UI
+// shows without scrolling
+func listFolderETags(ctx context.Context, bucketName, prefix string) (map[string]string, error) {
+	result, err := bucketClient(ctx, bucketName).ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:       aws.String(bucketName),
+		Prefix:       aws.String(prefix),
+		Delimiter:    aws.String("/"),
+		RequestPayer: requestPayer(ctx, bucketName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	etags := make(map[string]string, len(result.Contents))
+	for _, obj := range result.Contents {
+		etags[aws.ToString(obj.Key)] = aws.ToString(obj.ETag)
+	}
+	for _, p := range result.CommonPrefixes {
+		etags[aws.ToString(p.Prefix)] = ""
+	}
+
+	return etags, nil
+}
+
+// watchBucket is a WebSocket sending object events below prefix, so several
+// admins looking at the same folder see each other's changes. With
+// watch.diff_interval_seconds, the folder is also listed periodically to
+// catch changes made outside the backend.
+func watchBucket(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucketName"]
+	prefix := r.URL.Query().Get("prefix")
+
+	conn, ok := upgradeWebSocket(w, r)
+	if !ok {
+		return
+	}
+	defer conn.Close()
+
+	ch := events.Subscribe()
+	defer events.Unsubscribe(ch)
+
+	closed := make(chan struct{})
+	go func() {
+		conn.ReadLoop()
+		close(closed)
+	}()
+
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	var diff <-chan time.Time
+	var etags map[string]string
+	// Keys already announced by the backend are not announced again by the
+	// next comparison
+	announced := map[string]bool{}
+	if interval := appConfig.Watch.DiffIntervalSeconds; interval > 0 {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		diff = ticker.C
+		etags, _ = listFolderETags(r.Context(), bucketName, prefix)
+	}
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-keepAlive.C:
+			if conn.Ping() != nil {
+				return
+			}
+		case event := <-ch:
+			objectEvent, ok := event.Data.(ObjectEvent)
+			if !ok || !objectEvent.watched(bucketName, prefix) {
+				continue
+			}
+			announced[objectEvent.Key] = true
+			if conn.WriteJSON(watchMessage{Type: event.Type, ObjectEvent: objectEvent}) != nil {
+				return
+			}
+		case <-diff:
+			current, err := listFolderETags(r.Context(), bucketName, prefix)
+			if err != nil {
+				continue
+			}
+
+			var messages []watchMessage
+			for key, etag := range current {
+				previous, existed := etags[key]
+				switch {
+				case etags == nil || announced[key]:
+				case !existed:
+					messages = append(messages, watchMessage{Type: ObjectCreated, ObjectEvent: ObjectEvent{BucketName: bucketName, Key: key, Source: "diff"}})
+				case previous != etag:
+					messages = append(messages, watchMessage{Type: ObjectUpdated, ObjectEvent: ObjectEvent{BucketName: bucketName, Key: key, Source: "diff"}})
+				}
+			}
+			for key := range etags {
+				if _, exists := current[key]; !exists && !announced[key] {
+					messages = append(messages, watchMessage{Type: ObjectDeleted, ObjectEvent: ObjectEvent{BucketName: bucketName, Key: key, Source: "diff"}})
+				}
+			}
+			etags = current
+			announced = map[string]bool{}
+
+			for _, message := range messages {
+				if conn.WriteJSON(message) != nil {
+					return
+				}
+			}
+		}
+	}
+}
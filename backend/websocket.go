@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The GUID every WebSocket handshake hashes with the client's key (RFC 6455)
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Clients only send control frames and small messages, larger frames close
+// the connection
+const maxWebSocketFrameSize = 64 * 1024
+
+const webSocketWriteTimeout = 10 * time.Second
+
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+// webSocketConn is the server side of a WebSocket connection. It only sends
+// text messages, messages of the client are read and dropped.
+type webSocketConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// upgradeWebSocket answers the opening handshake and takes over the
+// connection. Failed handshakes are answered with an error.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocketConn, bool) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		httpError(w, r, http.StatusBadRequest, "websocket_required")
+		return nil, false
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		httpError(w, r, http.StatusUpgradeRequired, "websocket_version_unsupported")
+		return nil, false
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "websocket_upgrade_failed", err)
+		return nil, false
+	}
+
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, false
+	}
+
+	return &webSocketConn{conn: conn, rw: rw}, true
+}
+
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+
+	return c.rw.Flush()
+}
+
+func (c *webSocketConn) WriteJSON(v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.writeFrame(wsOpText, payload)
+}
+
+// ReadLoop answers pings and returns once the client closes the connection
+// or sends something invalid
+func (c *webSocketConn) ReadLoop() error {
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+			return err
+		}
+
+		opcode := head[0] & 0x0F
+		masked := head[1]&0x80 != 0
+		size := uint64(head[1] & 0x7F)
+		switch size {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+				return err
+			}
+			size = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+				return err
+			}
+			size = binary.BigEndian.Uint64(ext[:])
+		}
+		// Clients have to mask every frame
+		if !masked || size > maxWebSocketFrameSize {
+			c.writeFrame(wsOpClose, binary.BigEndian.AppendUint16(nil, 1002))
+			return errWebSocketClosed
+		}
+
+		var mask [4]byte
+		if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+			return err
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(c.rw, payload); err != nil {
+			return err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case wsOpClose:
+			c.writeFrame(wsOpClose, nil)
+			return errWebSocketClosed
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *webSocketConn) Ping() error {
+	return c.writeFrame(wsOpPing, nil)
+}
+
+func (c *webSocketConn) Close() error {
+	return c.conn.Close()
+}
@@ -73,7 +73,7 @@ function App() {
   useEffect(() => {
     const fetchConfig = async () => {
       try {
-        const response = await fetch('/config.json');
+        const response = await fetch('config.json');
         const config = await response.json();
         setApiUrl(config.apiHost);
       } catch (error) {
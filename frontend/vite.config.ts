@@ -3,5 +3,7 @@ import react from '@vitejs/plugin-react'
 
 // https://vite.dev/config/
 export default defineConfig({
+  // Relative asset paths let the build be served below any base path
+  base: './',
   plugins: [react()],
 })